@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -41,19 +43,39 @@ func main() {
 	router := gin.Default()
 
 	// Initialize handlers
-	fileHandler := handlers.NewFileHandler(fileStoringClient)
+	fileHandler := handlers.NewFileHandler(fileStoringClient, fileAnalysisClient)
 	analysisHandler := handlers.NewAnalysisHandler(fileAnalysisClient)
+	batchLimits, err := newBatchLimits()
+	if err != nil {
+		log.Fatalf("Failed to configure batch analysis: %v", err)
+	}
+	batchHandler := handlers.NewBatchHandler(fileStoringClient, fileAnalysisClient, batchLimits)
 
 	// Setup API routes
 	v1 := router.Group("/api/v1")
 	{
 		// File routes
+		v1.GET("/files", fileHandler.ListFiles)
 		v1.POST("/files", fileHandler.UploadFile)
 		v1.GET("/files/:file_id", fileHandler.GetFile)
+		v1.HEAD("/files/:file_id", fileHandler.HeadFile)
+		v1.GET("/files/:file_id/metadata", fileHandler.GetFileMetadata)
+		v1.DELETE("/files/:file_id", fileHandler.DeleteFile)
 
 		// Analysis routes
-		v1.POST("/analysis", analysisHandler.AnalyzeFile)
+		v1.POST("/analysis", analysisHandler.SubmitAnalysis)
+		v1.GET("/analysis/jobs/:job_id", analysisHandler.GetAnalysisJob)
+		v1.GET("/analysis/:file_id/report", analysisHandler.GetPlagiarismReport)
 		v1.GET("/wordcloud/:location", analysisHandler.GetWordCloud)
+		v1.GET("/compare/:file_a/:file_b", analysisHandler.CompareFiles)
+
+		// Plagiarism routes
+		v1.GET("/plagiarism/clusters", analysisHandler.GetPlagiarismClusters)
+		v1.GET("/plagiarism/graph", analysisHandler.ExportSimilarityGraph)
+
+		// Batch routes
+		v1.POST("/batches", batchHandler.SubmitBatch)
+		v1.GET("/batches/:batch_id", batchHandler.GetBatchReport)
 	}
 
 	// Setup Swagger
@@ -76,3 +98,25 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// newBatchLimits reads the limits of uploaded archives from MAX_BATCH_SIZE (in bytes) and MAX_BATCH_FILES
+func newBatchLimits() (handlers.BatchLimits, error) {
+	limits := handlers.BatchLimits{MaxArchiveSize: 100 << 20, MaxFiles: 200}
+	if value, ok := os.LookupEnv("MAX_BATCH_SIZE"); ok {
+		var err error
+		if limits.MaxArchiveSize, err = strconv.ParseInt(value, 10, 64); err != nil || limits.MaxArchiveSize <= 0 {
+			return handlers.BatchLimits{}, fmt.Errorf("invalid MAX_BATCH_SIZE %q", value)
+		}
+	} else {
+		log.Println("MAX_BATCH_SIZE not set, using default:", limits.MaxArchiveSize)
+	}
+	if value, ok := os.LookupEnv("MAX_BATCH_FILES"); ok {
+		var err error
+		if limits.MaxFiles, err = strconv.Atoi(value); err != nil || limits.MaxFiles <= 0 {
+			return handlers.BatchLimits{}, fmt.Errorf("invalid MAX_BATCH_FILES %q", value)
+		}
+	} else {
+		log.Println("MAX_BATCH_FILES not set, using default:", limits.MaxFiles)
+	}
+	return limits, nil
+}
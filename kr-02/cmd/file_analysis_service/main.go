@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
 
 	"kr-02/cmd/file_analysis_service/server"
+	"kr-02/internal/pkg/encryption"
 	"kr-02/internal/pkg/file_analysis/analyzer"
 	"kr-02/internal/pkg/file_analysis/clients"
+	"kr-02/internal/pkg/file_analysis/repository"
 	"kr-02/internal/pkg/file_analysis/repository/postgres"
 	"kr-02/internal/pkg/file_analysis/service"
+	"kr-02/internal/pkg/file_analysis/storage/encrypted"
 	"kr-02/internal/pkg/file_analysis/storage/local"
+	"kr-02/internal/pkg/migrate"
 	pb "kr-02/internal/proto/file_analysis_service"
 )
 
@@ -41,28 +49,27 @@ func main() {
 	}
 	log.Println("Connected to the database")
 
-	// Create the analysis_results and similar_files tables if they don't exist
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS analysis_results (
-			file_id TEXT PRIMARY KEY,
-			paragraph_count INT NOT NULL,
-			word_count INT NOT NULL,
-			character_count INT NOT NULL,
-			is_plagiarism BOOLEAN NOT NULL,
-			word_cloud_location TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-		
-		CREATE TABLE IF NOT EXISTS similar_files (
-			file_id TEXT,
-			similar_file_id TEXT,
-			PRIMARY KEY (file_id, similar_file_id)
-		);
-	`)
+	// Load the schema migrations
+	migrator, err := migrate.NewMigrator(db, postgres.Migrations(), postgres.MigrationsComponent)
 	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
 
+	// Run the migrate subcommand instead of the service if requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Apply pending migrations
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	log.Printf("Applied %d migrations", applied)
+
 	// Initialize storage
 	storagePath := os.Getenv("STORAGE_PATH")
 	if storagePath == "" {
@@ -75,8 +82,38 @@ func main() {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	// Initialize repository
+	// Encrypt word clouds at rest if a key file is configured
+	var encryptedStorage *encrypted.EncryptedStorage
+	if keyFile := os.Getenv("ENCRYPTION_KEY_FILE"); keyFile != "" {
+		keyring, err := encryption.LoadKeyring(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load encryption keys: %v", err)
+		}
+		encryptedStorage = encrypted.NewEncryptedStorage(storage, keyring)
+		storage = encryptedStorage
+		log.Println("Encrypting stored word clouds with key", keyring.PrimaryKeyID())
+	} else {
+		log.Println("ENCRYPTION_KEY_FILE not set, storing word clouds unencrypted")
+	}
+
+	// Initialize repositories
 	repo := postgres.NewAnalysisRepo(db)
+	jobRepo := postgres.NewJobRepo(db)
+
+	// Run the reencrypt subcommand instead of the service if requested
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		if encryptedStorage == nil {
+			log.Fatalf("ENCRYPTION_KEY_FILE must be set to re-encrypt word clouds")
+		}
+		locations, err := repo.GetWordCloudLocations(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to list word clouds: %v", err)
+		}
+		if err := encryption.RunReEncrypt(context.Background(), encryptedStorage, locations, os.Stdout); err != nil {
+			log.Fatalf("Re-encryption failed: %v", err)
+		}
+		return
+	}
 
 	// Initialize File Storing Service client
 	fileStoringAddress := os.Getenv("FILE_STORING_SERVICE_ADDRESS")
@@ -93,25 +130,65 @@ func main() {
 
 	// Initialize analyzers
 	textAnalyzer := analyzer.NewTextAnalyzer()
+	if value := os.Getenv("TEXT_STEMMING"); value != "" {
+		textAnalyzer.Stem, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid TEXT_STEMMING %q: %v", value, err)
+		}
+	} else {
+		log.Println("TEXT_STEMMING not set, using default:", textAnalyzer.Stem)
+	}
+
+	// All detectors normalize words the same way as the statistics
 	plagiarismChecker := analyzer.NewPlagiarismChecker()
+	plagiarismChecker.TextAnalyzer = textAnalyzer
+	winnowingDetector := analyzer.NewWinnowingDetector()
+	winnowingDetector.TextAnalyzer = textAnalyzer
+
+	// The Jaccard detector is the plagiarism checker itself
+	defaultDetector := os.Getenv("PLAGIARISM_DETECTOR")
+	if defaultDetector == "" {
+		defaultDetector = plagiarismChecker.Name()
+		log.Println("PLAGIARISM_DETECTOR not set, using default:", defaultDetector)
+	}
+	detectors, err := analyzer.NewDetectors(defaultDetector, plagiarismChecker, winnowingDetector)
+	if err != nil {
+		log.Fatalf("Invalid PLAGIARISM_DETECTOR: %v", err)
+	}
 	
-	wordCloudAPIURL := os.Getenv("WORDCLOUD_API_URL")
-	if wordCloudAPIURL == "" {
-		wordCloudAPIURL = "https://quickchart.io/wordcloud"
-		log.Println("WORDCLOUD_API_URL not set, using default:", wordCloudAPIURL)
+	wordCloudGenerator, err := newWordCloudRenderer(textAnalyzer)
+	if err != nil {
+		log.Fatalf("Invalid word cloud configuration: %v", err)
+	}
+
+	comparisonConfig, err := newComparisonConfig()
+	if err != nil {
+		log.Fatalf("Invalid comparison configuration: %v", err)
 	}
-	wordCloudGenerator := analyzer.NewWordCloudGenerator(wordCloudAPIURL)
 
 	// Initialize service
 	analysisService := service.NewAnalysisService(
 		repo,
+		jobRepo,
 		storage,
 		fileStoringClient,
 		textAnalyzer,
 		plagiarismChecker,
+		detectors,
 		wordCloudGenerator,
+		comparisonConfig,
 	)
 
+	// Process submitted analyses in the background, recording the files uploaded while the service was down
+	// or since the last sync with their tags and computing the fingerprints missing since the index existed
+	// or the comparison settings changed
+	workerConfig, err := newWorkerConfig()
+	if err != nil {
+		log.Fatalf("Invalid analysis worker configuration: %v", err)
+	}
+	go analysisService.RunWorkers(context.Background(), workerConfig)
+	log.Printf("Started %d analysis workers", workerConfig.Workers)
+
 	// Initialize server
 	grpcServer := grpc.NewServer()
 	analysisServer := server.NewServer(analysisService)
@@ -133,4 +210,153 @@ func main() {
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// newWorkerConfig builds the analysis worker configuration from the environment
+func newWorkerConfig() (service.WorkerConfig, error) {
+	config := service.WorkerConfig{
+		Workers:      4,
+		PollInterval: 5 * time.Second,
+		Lease:        5 * time.Minute,
+		MaxAttempts:  3,
+		SyncInterval: time.Minute,
+	}
+
+	if value := os.Getenv("ANALYSIS_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers <= 0 {
+			return service.WorkerConfig{}, fmt.Errorf("invalid ANALYSIS_WORKERS %q", value)
+		}
+		config.Workers = workers
+	} else {
+		log.Println("ANALYSIS_WORKERS not set, using default:", config.Workers)
+	}
+
+	if value := os.Getenv("ANALYSIS_JOB_MAX_ATTEMPTS"); value != "" {
+		maxAttempts, err := strconv.Atoi(value)
+		if err != nil || maxAttempts <= 0 {
+			return service.WorkerConfig{}, fmt.Errorf("invalid ANALYSIS_JOB_MAX_ATTEMPTS %q", value)
+		}
+		config.MaxAttempts = maxAttempts
+	}
+
+	if value := os.Getenv("ANALYSIS_POLL_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return service.WorkerConfig{}, fmt.Errorf("invalid ANALYSIS_POLL_INTERVAL %q", value)
+		}
+		config.PollInterval = interval
+	}
+
+	if value := os.Getenv("ANALYSIS_JOB_LEASE"); value != "" {
+		lease, err := time.ParseDuration(value)
+		if err != nil || lease <= 0 {
+			return service.WorkerConfig{}, fmt.Errorf("invalid ANALYSIS_JOB_LEASE %q", value)
+		}
+		config.Lease = lease
+	}
+
+	if value := os.Getenv("ANALYSIS_SYNC_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return service.WorkerConfig{}, fmt.Errorf("invalid ANALYSIS_SYNC_INTERVAL %q", value)
+		}
+		config.SyncInterval = interval
+	}
+
+	return config, nil
+}
+
+// newComparisonConfig builds the comparison scope configuration from the environment.
+// Thresholds that are not set fall back to the threshold of the detector.
+func newComparisonConfig() (service.ComparisonConfig, error) {
+	config := service.ComparisonConfig{
+		DefaultScope: repository.ScopeGlobal,
+		Thresholds:   make(map[repository.ComparisonScope]float64),
+	}
+
+	if value := os.Getenv("COMPARISON_SCOPE"); value != "" {
+		config.DefaultScope = repository.ComparisonScope(value)
+	} else {
+		log.Println("COMPARISON_SCOPE not set, using default:", config.DefaultScope)
+	}
+
+	for scope, key := range map[repository.ComparisonScope]string{
+		repository.ScopeAssignment: "PLAGIARISM_THRESHOLD_ASSIGNMENT",
+		repository.ScopeCourse:     "PLAGIARISM_THRESHOLD_COURSE",
+		repository.ScopeGlobal:     "PLAGIARISM_THRESHOLD_GLOBAL",
+	} {
+		if value := os.Getenv(key); value != "" {
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return service.ComparisonConfig{}, fmt.Errorf("invalid %s %q", key, value)
+			}
+			config.Thresholds[scope] = threshold
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return service.ComparisonConfig{}, err
+	}
+	return config, nil
+}
+
+// newWordCloudRenderer builds the word cloud renderer from the environment
+func newWordCloudRenderer(textAnalyzer *analyzer.TextAnalyzer) (analyzer.WordCloudRenderer, error) {
+	backend := os.Getenv("WORDCLOUD_RENDERER")
+	if backend == "" {
+		backend = "local"
+		log.Println("WORDCLOUD_RENDERER not set, using default:", backend)
+	}
+
+	switch backend {
+	case "local":
+	case "http":
+		wordCloudAPIURL := os.Getenv("WORDCLOUD_API_URL")
+		if wordCloudAPIURL == "" {
+			wordCloudAPIURL = "https://quickchart.io/wordcloud"
+			log.Println("WORDCLOUD_API_URL not set, using default:", wordCloudAPIURL)
+		}
+		return analyzer.NewWordCloudGenerator(wordCloudAPIURL, textAnalyzer), nil
+	default:
+		return nil, fmt.Errorf("invalid WORDCLOUD_RENDERER %q, want local or http", backend)
+	}
+
+	renderer, err := analyzer.NewLocalWordCloudRenderer(textAnalyzer)
+	if err != nil {
+		return nil, err
+	}
+
+	if value := os.Getenv("WORDCLOUD_FORMAT"); value != "" {
+		if value != analyzer.WordCloudPNG && value != analyzer.WordCloudSVG {
+			return nil, fmt.Errorf("invalid WORDCLOUD_FORMAT %q, want png or svg", value)
+		}
+		renderer.Format = value
+	}
+
+	if value := os.Getenv("WORDCLOUD_WIDTH"); value != "" {
+		width, err := strconv.Atoi(value)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid WORDCLOUD_WIDTH %q", value)
+		}
+		renderer.Width = width
+	}
+
+	if value := os.Getenv("WORDCLOUD_HEIGHT"); value != "" {
+		height, err := strconv.Atoi(value)
+		if err != nil || height <= 0 {
+			return nil, fmt.Errorf("invalid WORDCLOUD_HEIGHT %q", value)
+		}
+		renderer.Height = height
+	}
+
+	if value := os.Getenv("WORDCLOUD_PALETTE"); value != "" {
+		palette, err := analyzer.ParsePalette(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WORDCLOUD_PALETTE: %w", err)
+		}
+		renderer.Palette = palette
+	}
+
+	return renderer, nil
+}
@@ -2,9 +2,17 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	pb "kr-02/internal/proto/file_analysis_service"
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
 	"kr-02/internal/pkg/file_analysis/service"
 )
 
@@ -25,27 +33,315 @@ func NewServer(analysisService *service.AnalysisService) *Server {
 func (s *Server) AnalyzeFile(ctx context.Context, req *pb.AnalyzeFileRequest) (*pb.AnalyzeFileResponse, error) {
 	log.Printf("Received analysis request for file ID: %s", req.FileId)
 
-	paragraphCount, wordCount, characterCount, isPlagiarism, similarFileIDs, wordCloudLocation, err := s.analysisService.AnalyzeFile(
+	analysis, err := s.analysisService.AnalyzeFile(
 		ctx,
 		req.FileId,
 		req.GenerateWordCloud,
+		toWordCloudOptions(req.WordCloudOptions),
+		req.Detector,
+		toComparisonScope(req.Scope),
 	)
 	if err != nil {
 		log.Printf("Failed to analyze file: %v", err)
+		if errors.Is(err, analyzer.ErrUnknownDetector) || errors.Is(err, analyzer.ErrInvalidWordCloudOptions) ||
+			errors.Is(err, service.ErrUnknownScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return nil, err
 	}
 
 	log.Printf("File analyzed successfully: %s", req.FileId)
-	return &pb.AnalyzeFileResponse{
-		ParagraphCount:    paragraphCount,
-		WordCount:         wordCount,
-		CharacterCount:    characterCount,
-		IsPlagiarism:      isPlagiarism,
-		SimilarFileIds:    similarFileIDs,
-		WordCloudLocation: wordCloudLocation,
+	return toAnalyzeFileResponse(analysis), nil
+}
+
+// SubmitAnalysis handles requests to queue a file analysis
+func (s *Server) SubmitAnalysis(ctx context.Context, req *pb.SubmitAnalysisRequest) (*pb.AnalysisJob, error) {
+	log.Printf("Received analysis submission for file ID: %s", req.FileId)
+
+	if req.FileId == "" {
+		return nil, status.Error(codes.InvalidArgument, "file ID is required")
+	}
+
+	job, err := s.analysisService.SubmitAnalysis(
+		ctx,
+		req.FileId,
+		req.GenerateWordCloud,
+		toWordCloudOptions(req.WordCloudOptions),
+		req.Detector,
+		toComparisonScope(req.Scope),
+	)
+	if err != nil {
+		log.Printf("Failed to submit analysis: %v", err)
+		if errors.Is(err, analyzer.ErrUnknownDetector) || errors.Is(err, analyzer.ErrInvalidWordCloudOptions) ||
+			errors.Is(err, service.ErrUnknownScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+
+	log.Printf("Analysis of file %s queued as job %s", req.FileId, job.ID)
+	return toAnalysisJob(job), nil
+}
+
+// GetAnalysisJob handles analysis job status requests
+func (s *Server) GetAnalysisJob(ctx context.Context, req *pb.GetAnalysisJobRequest) (*pb.AnalysisJob, error) {
+	job, err := s.analysisService.GetAnalysisJob(ctx, req.JobId)
+	if err != nil {
+		log.Printf("Failed to get analysis job: %v", err)
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+
+	resp := toAnalysisJob(job)
+	if job.Status == repository.JobSucceeded {
+		analysis, err := s.analysisService.GetAnalysisResult(ctx, job.FileID)
+		if err != nil {
+			// The results may have been deleted together with the file since
+			log.Printf("Failed to get results of analysis job %s: %v", job.ID, err)
+		} else {
+			resp.Result = toAnalyzeFileResponse(analysis)
+		}
+	}
+	return resp, nil
+}
+
+// SubmitBatch handles requests to queue the analyses of a batch
+func (s *Server) SubmitBatch(ctx context.Context, req *pb.SubmitBatchRequest) (*pb.BatchReport, error) {
+	log.Printf("Received batch submission %q with %d entries", req.Name, len(req.Entries))
+
+	entries := make([]repository.BatchEntry, len(req.Entries))
+	for i, entry := range req.Entries {
+		if entry.FileName == "" || (entry.FileId == "") == (entry.Error == "") {
+			return nil, status.Errorf(codes.InvalidArgument, "batch entry %d needs a file name and either a file ID or an error", i)
+		}
+		entries[i] = repository.BatchEntry{FileName: entry.FileName, FileID: entry.FileId, Error: entry.Error}
+	}
+
+	report, err := s.analysisService.SubmitBatch(
+		ctx,
+		req.Name,
+		entries,
+		req.GenerateWordCloud,
+		toWordCloudOptions(req.WordCloudOptions),
+		req.Detector,
+		toComparisonScope(req.Scope),
+	)
+	if err != nil {
+		log.Printf("Failed to submit batch: %v", err)
+		if errors.Is(err, service.ErrEmptyBatch) || errors.Is(err, analyzer.ErrUnknownDetector) ||
+			errors.Is(err, analyzer.ErrInvalidWordCloudOptions) || errors.Is(err, service.ErrUnknownScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+
+	log.Printf("Batch %s queued with %d entries", report.ID, len(report.Files))
+	return toBatchReport(report), nil
+}
+
+// GetBatchReport handles batch report requests
+func (s *Server) GetBatchReport(ctx context.Context, req *pb.GetBatchReportRequest) (*pb.BatchReport, error) {
+	report, err := s.analysisService.GetBatchReport(ctx, req.BatchId)
+	if err != nil {
+		log.Printf("Failed to get batch report: %v", err)
+		if errors.Is(err, repository.ErrBatchNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+	return toBatchReport(report), nil
+}
+
+// GetPlagiarismReport handles plagiarism report requests
+func (s *Server) GetPlagiarismReport(ctx context.Context, req *pb.GetPlagiarismReportRequest) (*pb.PlagiarismReport, error) {
+	log.Printf("Received plagiarism report request for file ID: %s", req.FileId)
+
+	report, err := s.analysisService.GetPlagiarismReport(ctx, req.FileId)
+	if err != nil {
+		log.Printf("Failed to get plagiarism report: %v", err)
+		if errors.Is(err, repository.ErrAnalysisNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+
+	resp := &pb.PlagiarismReport{
+		FileId:         report.FileID,
+		IsPlagiarism:   report.IsPlagiarism,
+		OriginalFileId: report.OriginalFileID,
+	}
+	for _, match := range report.Matches {
+		pbMatch := &pb.PlagiarismMatch{
+			FileId:      match.FileID,
+			Similarity:  match.Similarity,
+			UploadedAt:  toTimestamp(match.UploadedAt),
+			Attribution: attributions[match.Attribution],
+		}
+		pbMatch.Passages = toMatchedPassages(match.Passages)
+		resp.Matches = append(resp.Matches, pbMatch)
+	}
+
+	log.Printf("Plagiarism report built for file %s with %d matches", req.FileId, len(resp.Matches))
+	return resp, nil
+}
+
+// CompareFiles handles file comparison requests
+func (s *Server) CompareFiles(ctx context.Context, req *pb.CompareFilesRequest) (*pb.FileComparison, error) {
+	log.Printf("Received comparison request for files %s and %s", req.FileId, req.OtherFileId)
+
+	comparison, err := s.analysisService.CompareFiles(ctx, req.FileId, req.OtherFileId)
+	if err != nil {
+		log.Printf("Failed to compare files: %v", err)
+		return nil, err
+	}
+
+	return &pb.FileComparison{
+		File: &pb.ComparedFile{
+			FileId:   comparison.File.FileID,
+			FileName: comparison.File.FileName,
+			Content:  comparison.File.Content,
+		},
+		OtherFile: &pb.ComparedFile{
+			FileId:   comparison.OtherFile.FileID,
+			FileName: comparison.OtherFile.FileName,
+			Content:  comparison.OtherFile.Content,
+		},
+		Similarity:       comparison.Similarity,
+		NgramCount:       int32(comparison.NGrams),
+		OtherNgramCount:  int32(comparison.OtherNGrams),
+		SharedNgramCount: int32(comparison.SharedNGrams),
+		Passages:         toMatchedPassages(comparison.Passages),
 	}, nil
 }
 
+// GetPlagiarismClusters handles requests for clusters of similar files
+func (s *Server) GetPlagiarismClusters(ctx context.Context, req *pb.GetPlagiarismClustersRequest) (*pb.PlagiarismClusters, error) {
+	log.Printf("Received plagiarism clusters request with method %s", req.Method)
+
+	method, ok := clusterMethods[req.Method]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown cluster method %s", req.Method)
+	}
+
+	clusters, err := s.analysisService.GetPlagiarismClusters(ctx, toSimilarityEdgeFilter(req.Filter), method)
+	if err != nil {
+		log.Printf("Failed to get plagiarism clusters: %v", err)
+		if errors.Is(err, service.ErrInvalidGraphFilter) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+
+	resp := &pb.PlagiarismClusters{}
+	for _, cluster := range clusters {
+		resp.Clusters = append(resp.Clusters, &pb.PlagiarismCluster{
+			FileIds:           cluster.FileIDs,
+			OriginalFileId:    cluster.OriginalFileID,
+			EdgeCount:         int32(cluster.EdgeCount),
+			MaxSimilarity:     cluster.MaxSimilarity,
+			AverageSimilarity: cluster.AverageSimilarity,
+		})
+	}
+
+	log.Printf("Found %d plagiarism clusters", len(resp.Clusters))
+	return resp, nil
+}
+
+// GetSimilarityGraph handles similarity graph requests
+func (s *Server) GetSimilarityGraph(ctx context.Context, req *pb.GetSimilarityGraphRequest) (*pb.SimilarityGraph, error) {
+	log.Printf("Received similarity graph request")
+
+	graph, err := s.analysisService.GetSimilarityGraph(ctx, toSimilarityEdgeFilter(req.Filter))
+	if err != nil {
+		log.Printf("Failed to get similarity graph: %v", err)
+		if errors.Is(err, service.ErrInvalidGraphFilter) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+
+	resp := &pb.SimilarityGraph{}
+	for _, file := range graph.Files {
+		resp.Files = append(resp.Files, &pb.GraphFile{
+			FileId:     file.FileID,
+			UploadedAt: toTimestamp(file.UploadedAt),
+			Component:  int32(file.Component),
+		})
+	}
+	for _, edge := range graph.Edges {
+		resp.Edges = append(resp.Edges, &pb.SimilarityEdge{
+			FileId:      edge.FileID,
+			OtherFileId: edge.OtherFileID,
+			Similarity:  edge.Similarity,
+		})
+	}
+
+	log.Printf("Similarity graph built with %d files and %d edges", len(resp.Files), len(resp.Edges))
+	return resp, nil
+}
+
+// UploadReferenceDocument handles reference document uploads
+func (s *Server) UploadReferenceDocument(ctx context.Context, req *pb.UploadReferenceDocumentRequest) (*pb.ReferenceDocument, error) {
+	log.Printf("Received reference document %q for course %q, assignment %q", req.Name, req.Course, req.Assignment)
+
+	tags := repository.FileTags{Course: req.Course, Assignment: req.Assignment}
+	document, err := s.analysisService.UploadReferenceDocument(ctx, req.Name, tags, req.Content)
+	if err != nil {
+		log.Printf("Failed to upload reference document: %v", err)
+		if errors.Is(err, service.ErrInvalidReference) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+
+	log.Printf("Reference document uploaded successfully: %s", document.ID)
+	return toReferenceDocument(document), nil
+}
+
+// ListReferenceDocuments handles reference document listing requests
+func (s *Server) ListReferenceDocuments(ctx context.Context, req *pb.ListReferenceDocumentsRequest) (*pb.ReferenceDocuments, error) {
+	documents, err := s.analysisService.ListReferenceDocuments(ctx, repository.FileTags{Course: req.Course, Assignment: req.Assignment})
+	if err != nil {
+		log.Printf("Failed to list reference documents: %v", err)
+		return nil, err
+	}
+
+	resp := &pb.ReferenceDocuments{}
+	for _, document := range documents {
+		resp.Documents = append(resp.Documents, toReferenceDocument(document))
+	}
+	return resp, nil
+}
+
+// DeleteReferenceDocument handles reference document deletion requests
+func (s *Server) DeleteReferenceDocument(ctx context.Context, req *pb.DeleteReferenceDocumentRequest) (*pb.DeleteReferenceDocumentResponse, error) {
+	log.Printf("Received delete reference document request for ID: %s", req.ReferenceId)
+
+	if err := s.analysisService.DeleteReferenceDocument(ctx, req.ReferenceId); err != nil {
+		log.Printf("Failed to delete reference document: %v", err)
+		if errors.Is(err, repository.ErrReferenceNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+
+	log.Printf("Reference document deleted successfully: %s", req.ReferenceId)
+	return &pb.DeleteReferenceDocumentResponse{}, nil
+}
+
+// toReferenceDocument converts a reference document to its protobuf representation, without its text
+func toReferenceDocument(document repository.ReferenceDocument) *pb.ReferenceDocument {
+	return &pb.ReferenceDocument{
+		ReferenceId: document.ID,
+		Name:        document.Name,
+		Course:      document.Tags.Course,
+		Assignment:  document.Tags.Assignment,
+		CreatedAt:   timestamppb.New(document.CreatedAt),
+	}
+}
+
 // GetWordCloud handles word cloud retrieval requests
 func (s *Server) GetWordCloud(ctx context.Context, req *pb.GetWordCloudRequest) (*pb.GetWordCloudResponse, error) {
 	log.Printf("Received word cloud request for location: %s", req.Location)
@@ -60,4 +356,202 @@ func (s *Server) GetWordCloud(ctx context.Context, req *pb.GetWordCloudRequest)
 	return &pb.GetWordCloudResponse{
 		Image: image,
 	}, nil
-}
\ No newline at end of file
+}
+
+// DeleteAnalysis handles analysis deletion requests
+func (s *Server) DeleteAnalysis(ctx context.Context, req *pb.DeleteAnalysisRequest) (*pb.DeleteAnalysisResponse, error) {
+	log.Printf("Received delete analysis request for file ID: %s", req.FileId)
+
+	if err := s.analysisService.DeleteAnalysis(ctx, req.FileId); err != nil {
+		log.Printf("Failed to delete analysis: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Analysis deleted successfully: %s", req.FileId)
+	return &pb.DeleteAnalysisResponse{}, nil
+}
+
+// toWordCloudOptions converts word cloud options from their protobuf representation; nil selects the defaults
+func toWordCloudOptions(options *pb.WordCloudOptions) analyzer.WordCloudOptions {
+	if options == nil {
+		return analyzer.WordCloudOptions{}
+	}
+	return analyzer.WordCloudOptions{
+		Width:          int(options.Width),
+		Height:         int(options.Height),
+		Format:         options.Format,
+		ColorScheme:    options.ColorScheme,
+		MaxWords:       int(options.MaxWords),
+		PreserveCase:   options.PreserveCase,
+		ExtraStopWords: options.ExtraStopWords,
+	}
+}
+
+// toAnalyzeFileResponse converts an analysis to its protobuf representation
+func toAnalyzeFileResponse(analysis service.Analysis) *pb.AnalyzeFileResponse {
+	resp := &pb.AnalyzeFileResponse{
+		ParagraphCount:        analysis.ParagraphCount,
+		WordCount:             analysis.WordCount,
+		CharacterCount:        analysis.CharacterCount,
+		IsPlagiarism:          analysis.IsPlagiarism,
+		SimilarFileIds:        analysis.SimilarFileIDs,
+		WordCloudLocation:     analysis.WordCloudLocation,
+		SentenceCount:         analysis.SentenceCount,
+		AverageSentenceLength: analysis.AverageSentenceLength,
+		UniqueWordCount:       analysis.UniqueWordCount,
+		LexicalDensity:        analysis.LexicalDensity,
+		Readability:           analysis.Readability,
+		OriginalFileId:        analysis.OriginalFileID,
+		UploadedAt:            toTimestamp(analysis.UploadedAt),
+		Scope:                 comparisonScopes[analysis.Scope],
+	}
+	for _, term := range analysis.TopTerms {
+		resp.TopTerms = append(resp.TopTerms, &pb.TermCount{Term: term.Term, Count: term.Count})
+	}
+	for _, similarFile := range analysis.SimilarFiles {
+		resp.SimilarFiles = append(resp.SimilarFiles, &pb.SimilarFile{
+			FileId:      similarFile.FileID,
+			Similarity:  similarFile.Similarity,
+			UploadedAt:  toTimestamp(similarFile.UploadedAt),
+			Attribution: attributions[similarFile.Attribution],
+		})
+	}
+	return resp
+}
+
+// attributions maps attributions of similar files to their protobuf representation
+var attributions = map[service.Attribution]pb.Attribution{
+	service.AttributionUnknown: pb.Attribution_ATTRIBUTION_UNKNOWN,
+	service.AttributionEarlier: pb.Attribution_ATTRIBUTION_EARLIER,
+	service.AttributionLater:   pb.Attribution_ATTRIBUTION_LATER,
+}
+
+// comparisonScopes maps comparison scopes to their protobuf representation
+var comparisonScopes = map[repository.ComparisonScope]pb.ComparisonScope{
+	repository.ScopeGlobal:     pb.ComparisonScope_COMPARISON_SCOPE_GLOBAL,
+	repository.ScopeCourse:     pb.ComparisonScope_COMPARISON_SCOPE_COURSE,
+	repository.ScopeAssignment: pb.ComparisonScope_COMPARISON_SCOPE_ASSIGNMENT,
+}
+
+// toComparisonScope converts a comparison scope from its protobuf representation; unspecified selects the default
+// and unknown values are rejected by the service
+func toComparisonScope(scope pb.ComparisonScope) repository.ComparisonScope {
+	if scope == pb.ComparisonScope_COMPARISON_SCOPE_UNSPECIFIED {
+		return ""
+	}
+	for s, pbScope := range comparisonScopes {
+		if pbScope == scope {
+			return s
+		}
+	}
+	return repository.ComparisonScope(scope.String())
+}
+
+// clusterMethods maps cluster methods from their protobuf representation
+var clusterMethods = map[pb.ClusterMethod]service.ClusterMethod{
+	pb.ClusterMethod_CLUSTER_METHOD_COMPONENTS: service.ClusterComponents,
+	pb.ClusterMethod_CLUSTER_METHOD_CLIQUES:    service.ClusterCliques,
+}
+
+// toSimilarityEdgeFilter converts a similarity graph filter from its protobuf representation; nil selects every pair
+func toSimilarityEdgeFilter(filter *pb.SimilarityGraphFilter) repository.SimilarityEdgeFilter {
+	if filter == nil {
+		return repository.SimilarityEdgeFilter{}
+	}
+
+	result := repository.SimilarityEdgeFilter{MinSimilarity: filter.MinSimilarity}
+	if filter.UploadedAfter != nil {
+		result.UploadedAfter = filter.UploadedAfter.AsTime()
+	}
+	if filter.UploadedBefore != nil {
+		result.UploadedBefore = filter.UploadedBefore.AsTime()
+	}
+	return result
+}
+
+// toMatchedPassages converts matching passages to their protobuf representation
+func toMatchedPassages(passages []analyzer.Passage) []*pb.MatchedPassage {
+	var matched []*pb.MatchedPassage
+	for _, passage := range passages {
+		matched = append(matched, &pb.MatchedPassage{
+			SourceStart: int32(passage.SourceStart),
+			SourceEnd:   int32(passage.SourceEnd),
+			MatchStart:  int32(passage.MatchStart),
+			MatchEnd:    int32(passage.MatchEnd),
+			Text:        passage.Text,
+			WordCount:   int32(passage.WordCount),
+		})
+	}
+	return matched
+}
+
+// jobStatuses maps job states to their protobuf representation
+var jobStatuses = map[repository.JobStatus]pb.AnalysisJobStatus{
+	repository.JobQueued:    pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_QUEUED,
+	repository.JobRunning:   pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_RUNNING,
+	repository.JobSucceeded: pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_SUCCEEDED,
+	repository.JobFailed:    pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_FAILED,
+}
+
+// toAnalysisJob converts an analysis job to its protobuf representation, without results
+func toAnalysisJob(job repository.AnalysisJob) *pb.AnalysisJob {
+	return &pb.AnalysisJob{
+		JobId:      job.ID,
+		FileId:     job.FileID,
+		Status:     jobStatuses[job.Status],
+		Progress:   int32(job.Progress),
+		Error:      job.Error,
+		CreatedAt:  toTimestamp(job.CreatedAt),
+		StartedAt:  toTimestamp(job.StartedAt),
+		FinishedAt: toTimestamp(job.FinishedAt),
+		Detector:   job.Detector,
+		Scope:      comparisonScopes[job.Scope],
+	}
+}
+
+// toBatchReport converts a batch report to its protobuf representation
+func toBatchReport(report service.BatchReport) *pb.BatchReport {
+	resp := &pb.BatchReport{
+		BatchId:   report.ID,
+		Name:      report.Name,
+		Status:    pb.BatchStatus_BATCH_STATUS_RUNNING,
+		CreatedAt: toTimestamp(report.CreatedAt),
+	}
+	if report.Finished {
+		resp.Status = pb.BatchStatus_BATCH_STATUS_FINISHED
+	}
+
+	for _, file := range report.Files {
+		pbFile := &pb.BatchFileReport{
+			FileName:            file.FileName,
+			FileId:              file.FileID,
+			Error:               file.Error,
+			SimilarBatchFileIds: file.SimilarBatchFileIDs,
+			SimilarOtherFileIds: file.SimilarOtherFileIDs,
+			MaxSimilarity:       file.MaxSimilarity,
+		}
+		if file.Job != nil {
+			pbFile.Job = toAnalysisJob(*file.Job)
+			if file.Analysis != nil {
+				pbFile.Job.Result = toAnalyzeFileResponse(*file.Analysis)
+			}
+		}
+		resp.Files = append(resp.Files, pbFile)
+	}
+	for _, pair := range report.Pairs {
+		resp.Pairs = append(resp.Pairs, &pb.BatchPair{
+			FileId:      pair.FileID,
+			OtherFileId: pair.OtherFileID,
+			Similarity:  pair.Similarity,
+		})
+	}
+	return resp
+}
+
+// toTimestamp converts a time to a protobuf timestamp, leaving zero times unset
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
 
 	"kr-02/cmd/file_storing_service/server"
+	"kr-02/internal/pkg/encryption"
 	"kr-02/internal/pkg/file_storing/repository/postgres"
+	"kr-02/internal/pkg/migrate"
 	"kr-02/internal/pkg/file_storing/service"
+	"kr-02/internal/pkg/file_storing/storage"
+	"kr-02/internal/pkg/file_storing/storage/encrypted"
 	"kr-02/internal/pkg/file_storing/storage/local"
+	"kr-02/internal/pkg/file_storing/storage/s3"
+	"kr-02/internal/pkg/file_storing/validation"
 	pb "kr-02/internal/proto/file_storing_service"
 )
 
@@ -39,37 +50,96 @@ func main() {
 	}
 	log.Println("Connected to the database")
 
-	// Create the files table if it doesn't exist
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS files (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			hash TEXT NOT NULL,
-			location TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+	// Load the schema migrations
+	migrator, err := migrate.NewMigrator(db, postgres.Migrations(), postgres.MigrationsComponent)
 	if err != nil {
-		log.Fatalf("Failed to create files table: %v", err)
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
 
-	// Initialize storage
-	storagePath := os.Getenv("STORAGE_PATH")
-	if storagePath == "" {
-		storagePath = "./storage/files"
-		log.Println("STORAGE_PATH not set, using default:", storagePath)
+	// Run the migrate subcommand instead of the service if requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Apply pending migrations
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	log.Printf("Applied %d migrations", applied)
 
-	storage, err := local.NewLocalStorage(storagePath)
+	// Initialize storage
+	fileStorage, err := newFileStorage()
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
+	// Encrypt contents at rest if a key file is configured
+	var encryptedStorage *encrypted.EncryptedStorage
+	if keyFile := os.Getenv("ENCRYPTION_KEY_FILE"); keyFile != "" {
+		keyring, err := encryption.LoadKeyring(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load encryption keys: %v", err)
+		}
+		encryptedStorage = encrypted.NewEncryptedStorage(fileStorage, keyring)
+		fileStorage = encryptedStorage
+		log.Println("Encrypting stored files with key", keyring.PrimaryKeyID())
+	} else {
+		log.Println("ENCRYPTION_KEY_FILE not set, storing files unencrypted")
+	}
+
 	// Initialize repository
 	repo := postgres.NewFileRepo(db)
 
+	// Run the reencrypt subcommand instead of the service if requested
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		if encryptedStorage == nil {
+			log.Fatalf("ENCRYPTION_KEY_FILE must be set to re-encrypt files")
+		}
+		locations, err := repo.ListBlobLocations(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to list files: %v", err)
+		}
+		if err := encryption.RunReEncrypt(context.Background(), encryptedStorage, locations, os.Stdout); err != nil {
+			log.Fatalf("Re-encryption failed: %v", err)
+		}
+		return
+	}
+
+	// Initialize upload validation
+	validator, err := newValidator()
+	if err != nil {
+		log.Fatalf("Failed to initialize upload validation: %v", err)
+	}
+
 	// Initialize service
-	fileService := service.NewFileService(repo, storage)
+	fileService := service.NewFileService(repo, fileStorage, validator)
+
+	// Uploads pending for longer than this are considered interrupted
+	pendingTimeout := time.Hour
+	if value := os.Getenv("PENDING_UPLOAD_TIMEOUT"); value != "" {
+		pendingTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid PENDING_UPLOAD_TIMEOUT: %v", err)
+		}
+	} else {
+		log.Println("PENDING_UPLOAD_TIMEOUT not set, using default:", pendingTimeout)
+	}
+
+	// Periodically remove contents no file refers to anymore
+	gcInterval := time.Hour
+	if value := os.Getenv("GC_INTERVAL"); value != "" {
+		gcInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid GC_INTERVAL: %v", err)
+		}
+	} else {
+		log.Println("GC_INTERVAL not set, using default:", gcInterval)
+	}
+	go runGarbageCollector(fileService, gcInterval, pendingTimeout)
 
 	// Initialize server
 	grpcServer := grpc.NewServer()
@@ -92,4 +162,120 @@ func main() {
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// newFileStorage creates the file storage backend selected by the STORAGE_BACKEND environment variable
+func newFileStorage() (storage.FileStorage, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "local"
+		log.Println("STORAGE_BACKEND not set, using default:", backend)
+	}
+
+	switch backend {
+	case "local":
+		storagePath := os.Getenv("STORAGE_PATH")
+		if storagePath == "" {
+			storagePath = "./storage/files"
+			log.Println("STORAGE_PATH not set, using default:", storagePath)
+		}
+		return local.NewLocalStorage(storagePath)
+	case "s3":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		log.Println("Using S3 storage at", os.Getenv("S3_ENDPOINT"))
+		return s3.NewS3Storage(ctx, s3.Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// newValidator creates the upload validation chain configured by the environment.
+// List variables are comma-separated; setting one to an empty string disables its check.
+func newValidator() (validation.Validator, error) {
+	maxSize := int64(10 << 20)
+	if value, ok := os.LookupEnv("MAX_UPLOAD_SIZE"); ok {
+		var err error
+		if maxSize, err = strconv.ParseInt(value, 10, 64); err != nil || maxSize <= 0 {
+			return nil, fmt.Errorf("invalid MAX_UPLOAD_SIZE %q", value)
+		}
+	} else {
+		log.Println("MAX_UPLOAD_SIZE not set, using default:", maxSize)
+	}
+
+	chain := validation.Chain{validation.NotEmpty{}, validation.MaxSize(maxSize)}
+	if extensions := envList("ALLOWED_EXTENSIONS", ".txt"); len(extensions) > 0 {
+		chain = append(chain, validation.AllowedExtensions(extensions))
+	}
+	if mimeTypes := envList("ALLOWED_MIME_TYPES", "text/plain"); len(mimeTypes) > 0 {
+		chain = append(chain, validation.AllowedMIMETypes(mimeTypes))
+	}
+	if encodings := envList("ALLOWED_ENCODINGS", "utf-8"); len(encodings) > 0 {
+		chain = append(chain, validation.AllowedEncodings(encodings))
+	}
+	return chain, nil
+}
+
+// envList returns the comma-separated values of an environment variable, or the default if it is not set
+func envList(key, def string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		value = def
+		log.Printf("%s not set, using default: %s", key, def)
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// runGarbageCollector reconciles file records with storage on startup, checking that all contents exist,
+// and then every interval cleans up interrupted uploads and removes unreferenced file contents
+func runGarbageCollector(fileService *service.FileService, interval, pendingTimeout time.Duration) {
+	reconcile(fileService, pendingTimeout, true)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reconcile(fileService, pendingTimeout, false)
+
+		deleted, err := fileService.CollectGarbage(context.Background())
+		if err != nil {
+			log.Printf("Garbage collection failed: %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("Garbage collection removed %d unreferenced blobs", deleted)
+		}
+	}
+}
+
+// reconcile repairs inconsistencies between file records and storage and reports those it cannot repair
+func reconcile(fileService *service.FileService, pendingTimeout time.Duration, checkContent bool) {
+	report, err := fileService.Reconcile(context.Background(), pendingTimeout, checkContent)
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		return
+	}
+
+	if checkContent || report.Abandoned+report.Completed+len(report.Lost)+report.RefCountsRepaired > 0 {
+		log.Printf("Reconciliation finished: %s", report)
+	}
+	for _, fileID := range report.Lost {
+		log.Printf("Removed upload %s whose content was lost", fileID)
+	}
+	for _, location := range report.MissingContent {
+		log.Printf("Content of blob %s is missing from storage", location)
+	}
+}
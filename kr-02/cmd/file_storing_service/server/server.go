@@ -1,11 +1,21 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
 	pb "kr-02/internal/proto/file_storing_service"
+	"kr-02/internal/pkg/file_storing/repository"
 	"kr-02/internal/pkg/file_storing/service"
+	"kr-02/internal/pkg/file_storing/validation"
 )
 
 // Server implements the FileStoringServiceServer interface
@@ -25,10 +35,10 @@ func NewServer(fileService *service.FileService) *Server {
 func (s *Server) UploadFile(ctx context.Context, req *pb.UploadFileRequest) (*pb.UploadFileResponse, error) {
 	log.Printf("Received upload request for file: %s", req.FileName)
 
-	fileID, err := s.fileService.UploadFile(ctx, req.FileName, req.Content)
+	fileID, err := s.fileService.UploadFile(ctx, req.FileName, fromFileTags(req.Tags), bytes.NewReader(req.Content))
 	if err != nil {
 		log.Printf("Failed to upload file: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
 	log.Printf("File uploaded successfully with ID: %s", fileID)
@@ -41,15 +51,209 @@ func (s *Server) UploadFile(ctx context.Context, req *pb.UploadFileRequest) (*pb
 func (s *Server) GetFile(ctx context.Context, req *pb.GetFileRequest) (*pb.GetFileResponse, error) {
 	log.Printf("Received get file request for ID: %s", req.FileId)
 
-	fileName, content, err := s.fileService.GetFile(ctx, req.FileId)
+	file, content, err := s.fileService.GetFile(ctx, req.FileId)
 	if err != nil {
 		log.Printf("Failed to get file: %v", err)
+		return nil, toStatusError(err)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		log.Printf("Failed to read file: %v", err)
 		return nil, err
 	}
 
-	log.Printf("File retrieved successfully: %s", fileName)
+	log.Printf("File retrieved successfully: %s", file.Name)
 	return &pb.GetFileResponse{
-		FileName: fileName,
-		Content:  content,
+		FileName: file.Name,
+		Content:  data,
+	}, nil
+}
+
+// DeleteFile handles file deletion requests
+func (s *Server) DeleteFile(ctx context.Context, req *pb.DeleteFileRequest) (*pb.DeleteFileResponse, error) {
+	log.Printf("Received delete file request for ID: %s", req.FileId)
+
+	if err := s.fileService.DeleteFile(ctx, req.FileId); err != nil {
+		log.Printf("Failed to delete file: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	log.Printf("File deleted successfully: %s", req.FileId)
+	return &pb.DeleteFileResponse{}, nil
+}
+
+// ListFiles handles file listing requests
+func (s *Server) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (*pb.ListFilesResponse, error) {
+	log.Printf("Received list files request")
+
+	params := repository.ListFilesParams{
+		NameContains: req.NameContains,
+		MinSize:      req.MinSize,
+		MaxSize:      req.MaxSize,
+		Descending:   req.Descending,
+	}
+	if req.UploadedAfter != nil {
+		params.UploadedAfter = req.UploadedAfter.AsTime()
+	}
+	if req.UploadedBefore != nil {
+		params.UploadedBefore = req.UploadedBefore.AsTime()
+	}
+
+	switch req.SortBy {
+	case pb.FileSortField_FILE_SORT_FIELD_CREATED_AT:
+		params.SortBy = repository.SortByCreatedAt
+	case pb.FileSortField_FILE_SORT_FIELD_NAME:
+		params.SortBy = repository.SortByName
+	case pb.FileSortField_FILE_SORT_FIELD_SIZE:
+		params.SortBy = repository.SortBySize
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown sort field %v", req.SortBy)
+	}
+
+	files, nextPageToken, err := s.fileService.ListFiles(ctx, params, int(req.PageSize), req.PageToken)
+	if err != nil {
+		log.Printf("Failed to list files: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	resp := &pb.ListFilesResponse{
+		NextPageToken: nextPageToken,
+	}
+	for _, file := range files {
+		resp.Files = append(resp.Files, toFileInfo(file))
+	}
+
+	log.Printf("Listed %d files", len(resp.Files))
+	return resp, nil
+}
+
+// UploadFileStream handles streamed file upload requests
+func (s *Server) UploadFileStream(stream pb.FileStoringService_UploadFileStreamServer) error {
+	// The first frame must describe the file
+	req, err := stream.Recv()
+	if err != nil {
+		log.Printf("Failed to receive upload metadata: %v", err)
+		return status.Error(codes.InvalidArgument, "failed to receive file metadata")
+	}
+	metadata := req.GetMetadata()
+	if metadata == nil {
+		return status.Error(codes.InvalidArgument, "first frame must contain file metadata")
+	}
+
+	log.Printf("Received streamed upload request for file: %s", metadata.FileName)
+
+	fileID, err := s.fileService.UploadFile(stream.Context(), metadata.FileName, fromFileTags(metadata.Tags), newUploadStreamReader(stream))
+	if err != nil {
+		log.Printf("Failed to upload file: %v", err)
+		return toStatusError(err)
+	}
+
+	log.Printf("File uploaded successfully with ID: %s", fileID)
+	return stream.SendAndClose(&pb.UploadFileResponse{
+		FileId: fileID,
+	})
+}
+
+// GetFileStream handles streamed file retrieval requests
+func (s *Server) GetFileStream(req *pb.GetFileRequest, stream pb.FileStoringService_GetFileStreamServer) error {
+	log.Printf("Received streamed get file request for ID: %s", req.FileId)
+
+	file, content, err := s.fileService.GetFile(stream.Context(), req.FileId)
+	if err != nil {
+		log.Printf("Failed to get file: %v", err)
+		return toStatusError(err)
+	}
+	defer content.Close()
+
+	// Send the metadata frame first
+	err = stream.Send(&pb.GetFileStreamResponse{
+		Data: &pb.GetFileStreamResponse_Metadata{
+			Metadata: &pb.FileMetadata{FileName: file.Name, File: toFileInfo(file)},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to send file metadata: %v", err)
+		return err
+	}
+
+	// Then pipe the content in chunks
+	if _, err := io.CopyBuffer(&downloadStreamWriter{stream: stream}, content, make([]byte, chunkSize)); err != nil {
+		log.Printf("Failed to stream file: %v", err)
+		return err
+	}
+
+	log.Printf("File streamed successfully: %s", file.Name)
+	return nil
+}
+
+// GetFileMetadata handles file metadata requests
+func (s *Server) GetFileMetadata(ctx context.Context, req *pb.GetFileMetadataRequest) (*pb.GetFileMetadataResponse, error) {
+	log.Printf("Received get file metadata request for ID: %s", req.FileId)
+
+	file, err := s.fileService.GetFileMetadata(ctx, req.FileId)
+	if err != nil {
+		log.Printf("Failed to get file metadata: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	return &pb.GetFileMetadataResponse{
+		File: toFileInfo(file),
 	}, nil
-}
\ No newline at end of file
+}
+
+// toFileInfo converts file metadata to its protobuf representation
+func toFileInfo(file repository.FileInfo) *pb.FileInfo {
+	return &pb.FileInfo{
+		FileId:    file.ID,
+		FileName:  file.Name,
+		Size:      file.Size,
+		CreatedAt: timestamppb.New(file.CreatedAt),
+		Hash:      file.Hash,
+		MimeType:  file.MIMEType,
+		Encoding:  file.Encoding,
+		Tags: &pb.FileTags{
+			Uploader:   file.Tags.Uploader,
+			Course:     file.Tags.Course,
+			Assignment: file.Tags.Assignment,
+		},
+	}
+}
+
+// fromFileTags converts protobuf file tags, which may be unset
+func fromFileTags(tags *pb.FileTags) repository.FileTags {
+	return repository.FileTags{
+		Uploader:   tags.GetUploader(),
+		Course:     tags.GetCourse(),
+		Assignment: tags.GetAssignment(),
+	}
+}
+
+// ErrorDomain is the domain of the error details attached to rejected uploads
+const ErrorDomain = "file_storing_service"
+
+// toStatusError converts known service errors to gRPC errors with a matching status code.
+// Rejected uploads carry an ErrorInfo detail with the validation reason.
+func toStatusError(err error) error {
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		st, detailErr := status.New(codes.InvalidArgument, validationErr.Message).WithDetails(&errdetails.ErrorInfo{
+			Reason: string(validationErr.Reason),
+			Domain: ErrorDomain,
+		})
+		if detailErr != nil {
+			return status.Error(codes.InvalidArgument, validationErr.Message)
+		}
+		return st.Err()
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrFileNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"errors"
+
+	pb "kr-02/internal/proto/file_storing_service"
+)
+
+// chunkSize is the maximum size of a content chunk sent over a stream
+const chunkSize = 64 * 1024
+
+// uploadStreamReader exposes the content chunks of an upload stream as an io.Reader
+type uploadStreamReader struct {
+	stream pb.FileStoringService_UploadFileStreamServer
+	buf    []byte
+}
+
+// newUploadStreamReader creates a reader over the frames following the metadata frame
+func newUploadStreamReader(stream pb.FileStoringService_UploadFileStreamServer) *uploadStreamReader {
+	return &uploadStreamReader{stream: stream}
+}
+
+// Read receives frames until there is content to return; it returns io.EOF when the client closes the stream
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if req.GetMetadata() != nil {
+			return 0, errors.New("unexpected metadata frame in the middle of an upload")
+		}
+		r.buf = req.GetChunk()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// downloadStreamWriter sends everything written to it as content chunks of a download stream
+type downloadStreamWriter struct {
+	stream pb.FileStoringService_GetFileStreamServer
+}
+
+// Write sends p as a single chunk frame
+func (w *downloadStreamWriter) Write(p []byte) (int, error) {
+	err := w.stream.Send(&pb.GetFileStreamResponse{
+		Data: &pb.GetFileStreamResponse_Chunk{Chunk: p},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -49,22 +49,172 @@ func (c *FileAnalysisClient) Close() error {
 	return nil
 }
 
-// AnalyzeFile sends a request to analyze a file
-func (c *FileAnalysisClient) AnalyzeFile(ctx context.Context, fileID string, generateWordCloud bool) (*pb.AnalyzeFileResponse, error) {
+// SubmitAnalysis queues an analysis of a file with a plagiarism detector and returns the job that processes it.
+// An empty detector, nil word cloud options and an unspecified scope select the defaults of the service.
+func (c *FileAnalysisClient) SubmitAnalysis(
+	ctx context.Context,
+	fileID string,
+	generateWordCloud bool,
+	wordCloudOptions *pb.WordCloudOptions,
+	detector string,
+	scope pb.ComparisonScope,
+) (*pb.AnalysisJob, error) {
 	// Set a timeout for the request
-	ctx, cancel := context.WithTimeout(ctx, 60*time.Second) // Analysis might take longer
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	
+
 	// Make the request
-	resp, err := c.client.AnalyzeFile(ctx, &pb.AnalyzeFileRequest{
+	job, err := c.client.SubmitAnalysis(ctx, &pb.SubmitAnalysisRequest{
 		FileId:            fileID,
 		GenerateWordCloud: generateWordCloud,
+		Detector:          detector,
+		WordCloudOptions:  wordCloudOptions,
+		Scope:             scope,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze file: %w", err)
+		return nil, fmt.Errorf("failed to submit analysis: %w", err)
 	}
-	
-	return resp, nil
+
+	return job, nil
+}
+
+// GetAnalysisJob retrieves the status of an analysis job, and its results once it has succeeded
+func (c *FileAnalysisClient) GetAnalysisJob(ctx context.Context, jobID string) (*pb.AnalysisJob, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make the request
+	job, err := c.client.GetAnalysisJob(ctx, &pb.GetAnalysisJobRequest{
+		JobId: jobID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis job: %w", err)
+	}
+
+	return job, nil
+}
+
+// SubmitBatch queues analyses of the stored entries of a batch and returns the initial batch report.
+// An empty detector, nil word cloud options and an unspecified scope select the defaults of the service.
+func (c *FileAnalysisClient) SubmitBatch(
+	ctx context.Context,
+	name string,
+	entries []*pb.BatchEntry,
+	generateWordCloud bool,
+	wordCloudOptions *pb.WordCloudOptions,
+	detector string,
+	scope pb.ComparisonScope,
+) (*pb.BatchReport, error) {
+	// Set a timeout for the request; a job is queued for every entry
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	report, err := c.client.SubmitBatch(ctx, &pb.SubmitBatchRequest{
+		Name:              name,
+		Entries:           entries,
+		GenerateWordCloud: generateWordCloud,
+		Detector:          detector,
+		WordCloudOptions:  wordCloudOptions,
+		Scope:             scope,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit batch: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetBatchReport retrieves the consolidated report of the analyses of a batch
+func (c *FileAnalysisClient) GetBatchReport(ctx context.Context, batchID string) (*pb.BatchReport, error) {
+	// Set a timeout for the request; the report reads the results of every entry
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	report, err := c.client.GetBatchReport(ctx, &pb.GetBatchReportRequest{
+		BatchId: batchID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetPlagiarismReport retrieves the plagiarism report of an analyzed file
+func (c *FileAnalysisClient) GetPlagiarismReport(ctx context.Context, fileID string) (*pb.PlagiarismReport, error) {
+	// Set a timeout for the request; the report compares the contents of all similar files
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	report, err := c.client.GetPlagiarismReport(ctx, &pb.GetPlagiarismReportRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plagiarism report: %w", err)
+	}
+
+	return report, nil
+}
+
+// CompareFiles compares two stored files and finds the passages they share
+func (c *FileAnalysisClient) CompareFiles(ctx context.Context, fileID, otherFileID string) (*pb.FileComparison, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	comparison, err := c.client.CompareFiles(ctx, &pb.CompareFilesRequest{
+		FileId:      fileID,
+		OtherFileId: otherFileID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare files: %w", err)
+	}
+
+	return comparison, nil
+}
+
+// GetPlagiarismClusters groups similar files matching the filter into clusters of files sharing text
+func (c *FileAnalysisClient) GetPlagiarismClusters(
+	ctx context.Context,
+	filter *pb.SimilarityGraphFilter,
+	method pb.ClusterMethod,
+) (*pb.PlagiarismClusters, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	clusters, err := c.client.GetPlagiarismClusters(ctx, &pb.GetPlagiarismClustersRequest{
+		Filter: filter,
+		Method: method,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plagiarism clusters: %w", err)
+	}
+
+	return clusters, nil
+}
+
+// GetSimilarityGraph retrieves the graph of similar files matching the filter
+func (c *FileAnalysisClient) GetSimilarityGraph(ctx context.Context, filter *pb.SimilarityGraphFilter) (*pb.SimilarityGraph, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Make the request
+	graph, err := c.client.GetSimilarityGraph(ctx, &pb.GetSimilarityGraphRequest{
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get similarity graph: %w", err)
+	}
+
+	return graph, nil
 }
 
 // GetWordCloud retrieves a word cloud image
@@ -82,4 +232,21 @@ func (c *FileAnalysisClient) GetWordCloud(ctx context.Context, location string)
 	}
 	
 	return resp.Image, nil
-}
\ No newline at end of file
+}
+
+// DeleteAnalysis deletes analysis results, similarity records and the word cloud of a file
+func (c *FileAnalysisClient) DeleteAnalysis(ctx context.Context, fileID string) error {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make the request
+	_, err := c.client.DeleteAnalysis(ctx, &pb.DeleteAnalysisRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete analysis: %w", err)
+	}
+
+	return nil
+}
@@ -2,7 +2,9 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"google.golang.org/grpc"
@@ -82,4 +84,166 @@ func (c *FileStoringClient) GetFile(ctx context.Context, fileID string) (string,
 	}
 	
 	return resp.FileName, resp.Content, nil
-}
\ No newline at end of file
+}
+
+// DeleteFile deletes a file from the File Storing Service
+func (c *FileStoringClient) DeleteFile(ctx context.Context, fileID string) error {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make the request
+	_, err := c.client.DeleteFile(ctx, &pb.DeleteFileRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+// ListFiles retrieves a page of file metadata from the File Storing Service
+func (c *FileStoringClient) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (*pb.ListFilesResponse, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make the request
+	resp, err := c.client.ListFiles(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return resp, nil
+}
+
+// UploadFileStream uploads a file to the File Storing Service in chunks, reading the content as it goes
+func (c *FileStoringClient) UploadFileStream(ctx context.Context, fileName string, tags *pb.FileTags, content io.Reader) (string, error) {
+	// Set a timeout for the request; streamed files may be large
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	stream, err := c.client.UploadFileStream(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload stream: %w", err)
+	}
+
+	// Send the metadata frame first
+	err = stream.Send(&pb.UploadFileStreamRequest{
+		Data: &pb.UploadFileStreamRequest_Metadata{
+			Metadata: &pb.FileMetadata{FileName: fileName, Tags: tags},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send file metadata: %w", err)
+	}
+
+	// Then send the content in chunks
+	buf := make([]byte, chunkSize)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			err = stream.Send(&pb.UploadFileStreamRequest{
+				Data: &pb.UploadFileStreamRequest_Chunk{Chunk: buf[:n]},
+			})
+			if err != nil {
+				// The server aborted the stream; the real error comes from CloseAndRecv
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return "", fmt.Errorf("failed to send file chunk: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", fmt.Errorf("failed to read file content: %w", readErr)
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	return resp.FileId, nil
+}
+
+// GetFileStream retrieves a file from the File Storing Service in chunks.
+// The returned reader streams the content and must be closed by the caller.
+func (c *FileStoringClient) GetFileStream(ctx context.Context, fileID string) (*pb.FileInfo, io.ReadCloser, error) {
+	// Set a timeout for the request; streamed files may be large
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+
+	stream, err := c.client.GetFileStream(ctx, &pb.GetFileRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to open download stream: %w", err)
+	}
+
+	// The first frame describes the file
+	resp, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	file := resp.GetMetadata().GetFile()
+	if file == nil {
+		cancel()
+		return nil, nil, errors.New("failed to get file: first frame does not contain file metadata")
+	}
+
+	return file, &downloadStreamReader{stream: stream, cancel: cancel}, nil
+}
+
+// GetFileMetadata retrieves the metadata of a file from the File Storing Service without its content
+func (c *FileStoringClient) GetFileMetadata(ctx context.Context, fileID string) (*pb.FileInfo, error) {
+	// Set a timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make the request
+	resp, err := c.client.GetFileMetadata(ctx, &pb.GetFileMetadataRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	return resp.File, nil
+}
+
+// chunkSize is the maximum size of a content chunk sent over a stream
+const chunkSize = 64 * 1024
+
+// downloadStreamReader exposes the content chunks of a download stream as an io.ReadCloser
+type downloadStreamReader struct {
+	stream pb.FileStoringService_GetFileStreamClient
+	cancel context.CancelFunc
+	buf    []byte
+}
+
+// Read receives frames until there is content to return; it returns io.EOF when the stream ends
+func (r *downloadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		resp, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = resp.GetChunk()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close releases the stream
+func (r *downloadStreamReader) Close() error {
+	r.cancel()
+	return nil
+}
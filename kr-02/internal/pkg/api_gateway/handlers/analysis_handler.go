@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"kr-02/internal/pkg/api_gateway/clients"
+	pb "kr-02/internal/proto/file_analysis_service"
 )
 
 // AnalysisHandler handles file analysis operations
@@ -20,59 +26,349 @@ func NewAnalysisHandler(client *clients.FileAnalysisClient) *AnalysisHandler {
 
 // AnalyzeFileRequest represents the request body for file analysis
 type AnalyzeFileRequest struct {
-	FileID            string `json:"file_id" binding:"required" example:"file123"`
-	GenerateWordCloud bool   `json:"generate_word_cloud" example:"true"`
+	FileID            string            `json:"file_id" binding:"required" example:"file123"`
+	GenerateWordCloud bool              `json:"generate_word_cloud" example:"true"`
+	Detector          string            `json:"detector,omitempty" example:"winnowing" enums:"jaccard,winnowing"`
+	Scope             string            `json:"scope,omitempty" example:"assignment" enums:"assignment,course,global"`
+	WordCloudOptions  *WordCloudOptions `json:"word_cloud_options,omitempty"`
 }
 
-// AnalyzeFileResponse represents the response for file analysis
+// WordCloudOptions represents how a word cloud is rendered; options left out take the service defaults
+type WordCloudOptions struct {
+	Width          int32    `json:"width,omitempty" binding:"omitempty,min=64,max=4096" example:"800"`
+	Height         int32    `json:"height,omitempty" binding:"omitempty,min=64,max=4096" example:"600"`
+	Format         string   `json:"format,omitempty" binding:"omitempty,oneof=png svg" example:"svg" enums:"png,svg"`
+	ColorScheme    string   `json:"color_scheme,omitempty" example:"cool"`
+	MaxWords       int32    `json:"max_words,omitempty" binding:"omitempty,min=1,max=500" example:"50"`
+	PreserveCase   bool     `json:"preserve_case,omitempty" example:"false"`
+	ExtraStopWords []string `json:"extra_stop_words,omitempty" binding:"max=200" example:"глава,рисунок"`
+}
+
+// toProto converts word cloud options to their protobuf representation; nil selects the defaults
+func (o *WordCloudOptions) toProto() *pb.WordCloudOptions {
+	if o == nil {
+		return nil
+	}
+	return &pb.WordCloudOptions{
+		Width:          o.Width,
+		Height:         o.Height,
+		Format:         o.Format,
+		ColorScheme:    o.ColorScheme,
+		MaxWords:       o.MaxWords,
+		PreserveCase:   o.PreserveCase,
+		ExtraStopWords: o.ExtraStopWords,
+	}
+}
+
+// AnalyzeFileResponse represents the results of a file analysis
 type AnalyzeFileResponse struct {
-	ParagraphCount    int32    `json:"paragraph_count" example:"5"`
-	WordCount         int32    `json:"word_count" example:"100"`
-	CharacterCount    int32    `json:"character_count" example:"500"`
-	IsPlagiarism      bool     `json:"is_plagiarism" example:"false"`
-	SimilarFileIds    []string `json:"similar_file_ids" example:"[]"`
-	WordCloudLocation string   `json:"word_cloud_location" example:"wordclouds/file123.png"`
+	ParagraphCount        int32         `json:"paragraph_count" example:"5"`
+	WordCount             int32         `json:"word_count" example:"100"`
+	CharacterCount        int32         `json:"character_count" example:"500"`
+	SentenceCount         int32         `json:"sentence_count" example:"8"`
+	AverageSentenceLength float64       `json:"average_sentence_length" example:"12.5"`
+	UniqueWordCount       int32         `json:"unique_word_count" example:"70"`
+	LexicalDensity        float64       `json:"lexical_density" example:"0.62"`
+	TopTerms              []TermCount   `json:"top_terms"`
+	Readability           float64       `json:"readability" example:"48.3"`
+	IsPlagiarism          bool          `json:"is_plagiarism" example:"false"`
+	SimilarFileIds        []string      `json:"similar_file_ids" example:"[]"`
+	SimilarFiles          []SimilarFile `json:"similar_files"`
+	OriginalFileID        string        `json:"original_file_id,omitempty" example:"file042"`
+	UploadedAt            *time.Time    `json:"uploaded_at,omitempty" example:"2025-05-20T12:00:00Z"`
+	Scope                 string        `json:"scope" example:"assignment" enums:"assignment,course,global"`
+	WordCloudLocation     string        `json:"word_cloud_location" example:"wordclouds/file123.png"`
+}
+
+// SimilarFile represents a file similar to an analyzed file and which of them was uploaded first
+type SimilarFile struct {
+	FileID      string     `json:"file_id" example:"file042"`
+	Similarity  float64    `json:"similarity" example:"0.42"`
+	UploadedAt  *time.Time `json:"uploaded_at,omitempty" example:"2025-05-19T09:30:00Z"`
+	Attribution string     `json:"attribution" example:"earlier" enums:"earlier,later,unknown"`
+}
+
+// attributions maps attributions of similar files to their names in the API
+var attributions = map[pb.Attribution]string{
+	pb.Attribution_ATTRIBUTION_UNKNOWN: "unknown",
+	pb.Attribution_ATTRIBUTION_EARLIER: "earlier",
+	pb.Attribution_ATTRIBUTION_LATER:   "later",
+}
+
+// comparisonScopes maps the names of comparison scopes in the API to their protobuf representation
+var comparisonScopes = map[string]pb.ComparisonScope{
+	"assignment": pb.ComparisonScope_COMPARISON_SCOPE_ASSIGNMENT,
+	"course":     pb.ComparisonScope_COMPARISON_SCOPE_COURSE,
+	"global":     pb.ComparisonScope_COMPARISON_SCOPE_GLOBAL,
+}
+
+// comparisonScope returns the protobuf representation of a comparison scope in the API.
+// An empty name is the unspecified scope, which selects the default of the service.
+func comparisonScope(name string) (pb.ComparisonScope, bool) {
+	if name == "" {
+		return pb.ComparisonScope_COMPARISON_SCOPE_UNSPECIFIED, true
+	}
+	scope, ok := comparisonScopes[name]
+	return scope, ok
+}
+
+// detectorNames are the plagiarism detectors of the File Analysis Service
+var detectorNames = []string{"jaccard", "winnowing"}
+
+// validDetector reports whether a detector is one of detectorNames.
+// An empty name selects the default of the service.
+func validDetector(name string) bool {
+	return name == "" || slices.Contains(detectorNames, name)
+}
+
+// scopeName returns the name of a comparison scope in the API
+func scopeName(scope pb.ComparisonScope) string {
+	for name, value := range comparisonScopes {
+		if value == scope {
+			return name
+		}
+	}
+	return ""
+}
+
+// optionalTime converts a protobuf timestamp to a time, or nil if it is unset
+func optionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// TermCount represents a frequent significant word and how often it occurs
+type TermCount struct {
+	Term  string `json:"term" example:"сервис"`
+	Count int32  `json:"count" example:"7"`
+}
+
+// AnalysisJob represents a queued file analysis
+type AnalysisJob struct {
+	JobID      string               `json:"job_id" example:"job123"`
+	FileID     string               `json:"file_id" example:"file123"`
+	Status     string               `json:"status" example:"running" enums:"queued,running,succeeded,failed"`
+	Progress   int32                `json:"progress" example:"40"`
+	Detector   string               `json:"detector" example:"jaccard"`
+	Scope      string               `json:"scope" example:"assignment" enums:"assignment,course,global"`
+	Error      string               `json:"error,omitempty"`
+	CreatedAt  time.Time            `json:"created_at" example:"2025-05-20T12:00:00Z"`
+	StartedAt  *time.Time           `json:"started_at,omitempty" example:"2025-05-20T12:00:01Z"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	Result     *AnalyzeFileResponse `json:"result,omitempty"`
+}
+
+// jobStatuses maps job states to their names in the API
+var jobStatuses = map[pb.AnalysisJobStatus]string{
+	pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_QUEUED:    "queued",
+	pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_RUNNING:   "running",
+	pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_SUCCEEDED: "succeeded",
+	pb.AnalysisJobStatus_ANALYSIS_JOB_STATUS_FAILED:    "failed",
+}
+
+// newAnalysisJob converts an analysis job to its JSON representation
+func newAnalysisJob(job *pb.AnalysisJob) AnalysisJob {
+	resp := AnalysisJob{
+		JobID:     job.JobId,
+		FileID:    job.FileId,
+		Status:    jobStatuses[job.Status],
+		Progress:  job.Progress,
+		Detector:  job.Detector,
+		Scope:     scopeName(job.Scope),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt.AsTime(),
+	}
+	resp.StartedAt = optionalTime(job.StartedAt)
+	resp.FinishedAt = optionalTime(job.FinishedAt)
+	if job.Result != nil {
+		resp.Result = newAnalyzeFileResponse(job.Result)
+	}
+	return resp
+}
+
+// newAnalyzeFileResponse converts analysis results to their JSON representation
+func newAnalyzeFileResponse(result *pb.AnalyzeFileResponse) *AnalyzeFileResponse {
+	resp := &AnalyzeFileResponse{
+		ParagraphCount:        result.ParagraphCount,
+		WordCount:             result.WordCount,
+		CharacterCount:        result.CharacterCount,
+		SentenceCount:         result.SentenceCount,
+		AverageSentenceLength: result.AverageSentenceLength,
+		UniqueWordCount:       result.UniqueWordCount,
+		LexicalDensity:        result.LexicalDensity,
+		TopTerms:              []TermCount{},
+		Readability:           result.Readability,
+		IsPlagiarism:          result.IsPlagiarism,
+		SimilarFileIds:        result.SimilarFileIds,
+		SimilarFiles:          []SimilarFile{},
+		OriginalFileID:        result.OriginalFileId,
+		UploadedAt:            optionalTime(result.UploadedAt),
+		Scope:                 scopeName(result.Scope),
+		WordCloudLocation:     result.WordCloudLocation,
+	}
+	for _, term := range result.TopTerms {
+		resp.TopTerms = append(resp.TopTerms, TermCount{Term: term.Term, Count: term.Count})
+	}
+	for _, similarFile := range result.SimilarFiles {
+		resp.SimilarFiles = append(resp.SimilarFiles, SimilarFile{
+			FileID:      similarFile.FileId,
+			Similarity:  similarFile.Similarity,
+			UploadedAt:  optionalTime(similarFile.UploadedAt),
+			Attribution: attributions[similarFile.Attribution],
+		})
+	}
+	return resp
 }
 
-// AnalyzeFile godoc
+// SubmitAnalysis godoc
 // @Summary Analyze a file
-// @Description Analyze a file by its ID
+// @Description Queue an analysis of a file by its ID. Poll the job at the returned Location for its progress and results.
+// @Description A file with an unfinished analysis with the same detector and scope returns that job instead of queuing another one.
+// @Description Similar files are looked for with the requested detector, or with the configured default if none is given.
+// @Description The scope limits the comparison to files of the same assignment, of the same course, or to all files.
+// @Description A word cloud requested with the same options as before is reused.
 // @Tags analysis
 // @Accept json
 // @Produce json
 // @Param request body AnalyzeFileRequest true "Analysis request"
-// @Success 200 {object} AnalyzeFileResponse "Analysis results"
+// @Success 202 {object} AnalysisJob "Queued analysis job"
+// @Header 202 {string} Location "URL of the analysis job"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/analysis [post]
-func (h *AnalysisHandler) AnalyzeFile(c *gin.Context) {
+func (h *AnalysisHandler) SubmitAnalysis(c *gin.Context) {
 	var request AnalyzeFileRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	scope, ok := comparisonScope(request.Scope)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, expected assignment, course or global"})
+		return
+	}
 
-	resp, err := h.client.AnalyzeFile(c.Request.Context(), request.FileID, request.GenerateWordCloud)
+	job, err := h.client.SubmitAnalysis(
+		c.Request.Context(),
+		request.FileID,
+		request.GenerateWordCloud,
+		request.WordCloudOptions.toProto(),
+		request.Detector,
+		scope,
+	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	c.Header("Location", "/api/v1/analysis/jobs/"+url.PathEscape(job.JobId))
+	c.JSON(http.StatusAccepted, newAnalysisJob(job))
+}
+
+// GetAnalysisJob godoc
+// @Summary Get an analysis job
+// @Description Get the status and progress of an analysis job, and its results once it has succeeded
+// @Tags analysis
+// @Produce json
+// @Param job_id path string true "Job ID"
+// @Success 200 {object} AnalysisJob "Analysis job"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/analysis/jobs/{job_id} [get]
+func (h *AnalysisHandler) GetAnalysisJob(c *gin.Context) {
+	job, err := h.client.GetAnalysisJob(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, AnalyzeFileResponse{
-		ParagraphCount:    resp.ParagraphCount,
-		WordCount:         resp.WordCount,
-		CharacterCount:    resp.CharacterCount,
-		IsPlagiarism:      resp.IsPlagiarism,
-		SimilarFileIds:    resp.SimilarFileIds,
-		WordCloudLocation: resp.WordCloudLocation,
-	})
+	c.JSON(http.StatusOK, newAnalysisJob(job))
+}
+
+// PlagiarismReport represents how similar an analyzed file is to each similar file, and where
+type PlagiarismReport struct {
+	FileID         string            `json:"file_id" example:"file123"`
+	IsPlagiarism   bool              `json:"is_plagiarism" example:"true"`
+	OriginalFileID string            `json:"original_file_id,omitempty" example:"file456"`
+	Matches        []PlagiarismMatch `json:"matches"`
+}
+
+// PlagiarismMatch represents a file similar to the reported file
+type PlagiarismMatch struct {
+	FileID      string           `json:"file_id" example:"file456"`
+	Similarity  float64          `json:"similarity" example:"0.42"`
+	UploadedAt  *time.Time       `json:"uploaded_at,omitempty" example:"2025-05-19T09:30:00Z"`
+	Attribution string           `json:"attribution" example:"earlier" enums:"earlier,later,unknown"`
+	Passages    []MatchedPassage `json:"passages"`
+}
+
+// MatchedPassage represents a passage both files have in common.
+// Offsets count characters of the original texts; ends are exclusive.
+type MatchedPassage struct {
+	SourceStart int32  `json:"source_start" example:"120"`
+	SourceEnd   int32  `json:"source_end" example:"188"`
+	MatchStart  int32  `json:"match_start" example:"40"`
+	MatchEnd    int32  `json:"match_end" example:"108"`
+	Text        string `json:"text" example:"the quick brown fox jumps over the lazy dog"`
+	WordCount   int32  `json:"word_count" example:"7"`
+}
+
+// GetPlagiarismReport godoc
+// @Summary Get a plagiarism report
+// @Description Get how similar an analyzed file is to each similar file, with the longest passages they have in common
+// @Tags analysis
+// @Produce json
+// @Param file_id path string true "File ID"
+// @Success 200 {object} PlagiarismReport "Plagiarism report"
+// @Failure 404 {object} map[string]string "File not analyzed"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/analysis/{file_id}/report [get]
+func (h *AnalysisHandler) GetPlagiarismReport(c *gin.Context) {
+	report, err := h.client.GetPlagiarismReport(c.Request.Context(), c.Param("file_id"))
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	resp := PlagiarismReport{
+		FileID:         report.FileId,
+		IsPlagiarism:   report.IsPlagiarism,
+		OriginalFileID: report.OriginalFileId,
+		Matches:        []PlagiarismMatch{},
+	}
+	for _, match := range report.Matches {
+		respMatch := PlagiarismMatch{
+			FileID:      match.FileId,
+			Similarity:  match.Similarity,
+			UploadedAt:  optionalTime(match.UploadedAt),
+			Attribution: attributions[match.Attribution],
+			Passages:    []MatchedPassage{},
+		}
+		for _, passage := range match.Passages {
+			respMatch.Passages = append(respMatch.Passages, MatchedPassage{
+				SourceStart: passage.SourceStart,
+				SourceEnd:   passage.SourceEnd,
+				MatchStart:  passage.MatchStart,
+				MatchEnd:    passage.MatchEnd,
+				Text:        passage.Text,
+				WordCount:   passage.WordCount,
+			})
+		}
+		resp.Matches = append(resp.Matches, respMatch)
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetWordCloud godoc
 // @Summary Get a word cloud
-// @Description Get a word cloud image by its location
+// @Description Get a word cloud image by its location, as PNG or SVG depending on how it was rendered
 // @Tags analysis
 // @Produce image/png
+// @Produce image/svg+xml
 // @Param location path string true "Word cloud location"
 // @Success 200 {file} binary "Word cloud image"
 // @Failure 400 {object} map[string]string "Bad request"
@@ -92,5 +388,9 @@ func (h *AnalysisHandler) GetWordCloud(c *gin.Context) {
 		return
 	}
 
-	c.Data(http.StatusOK, "image/png", image)
+	contentType := "image/png"
+	if path.Ext(location) == ".svg" {
+		contentType = "image/svg+xml"
+	}
+	c.Data(http.StatusOK, contentType, image)
 }
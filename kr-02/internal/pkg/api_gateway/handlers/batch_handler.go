@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/charmap"
+	"google.golang.org/grpc/status"
+
+	"kr-02/internal/pkg/api_gateway/clients"
+	pb "kr-02/internal/proto/file_analysis_service"
+	storingpb "kr-02/internal/proto/file_storing_service"
+)
+
+// BatchLimits bounds the archives accepted for batch analysis
+type BatchLimits struct {
+	MaxArchiveSize int64 // In bytes
+	MaxFiles       int
+}
+
+// BatchHandler handles analyses of assignments uploaded as ZIP archives
+type BatchHandler struct {
+	files    *clients.FileStoringClient
+	analysis *clients.FileAnalysisClient
+	limits   BatchLimits
+}
+
+// NewBatchHandler creates a new BatchHandler instance
+func NewBatchHandler(files *clients.FileStoringClient, analysis *clients.FileAnalysisClient, limits BatchLimits) *BatchHandler {
+	return &BatchHandler{
+		files:    files,
+		analysis: analysis,
+		limits:   limits,
+	}
+}
+
+// BatchReport represents the consolidated report of the analyses of a batch
+type BatchReport struct {
+	BatchID   string      `json:"batch_id" example:"batch123"`
+	Name      string      `json:"name" example:"kr-02"`
+	Status    string      `json:"status" example:"running" enums:"running,finished"`
+	CreatedAt time.Time   `json:"created_at" example:"2025-05-20T12:00:00Z"`
+	Files     []BatchFile `json:"files"`
+	Pairs     []BatchPair `json:"pairs"`
+}
+
+// BatchFile represents an entry of a batch and the analysis of its file
+type BatchFile struct {
+	FileName            string       `json:"file_name" example:"ivanov.txt"`
+	FileID              string       `json:"file_id,omitempty" example:"file123"`
+	Error               string       `json:"error,omitempty" example:"file type is not allowed"`
+	Job                 *AnalysisJob `json:"job,omitempty"`
+	SimilarBatchFileIDs []string     `json:"similar_batch_file_ids" example:"file456"`
+	SimilarOtherFileIDs []string     `json:"similar_other_file_ids" example:"file042"`
+	MaxSimilarity       float64      `json:"max_similarity" example:"0.81"`
+}
+
+// BatchPair represents a pair of similar files of a batch
+type BatchPair struct {
+	FileID      string  `json:"file_id" example:"file123"`
+	OtherFileID string  `json:"other_file_id" example:"file456"`
+	Similarity  float64 `json:"similarity" example:"0.81"`
+}
+
+// batchStatuses maps batch states to their names in the API
+var batchStatuses = map[pb.BatchStatus]string{
+	pb.BatchStatus_BATCH_STATUS_RUNNING:  "running",
+	pb.BatchStatus_BATCH_STATUS_FINISHED: "finished",
+}
+
+// newBatchReport converts a batch report to its JSON representation
+func newBatchReport(report *pb.BatchReport) BatchReport {
+	resp := BatchReport{
+		BatchID:   report.BatchId,
+		Name:      report.Name,
+		Status:    batchStatuses[report.Status],
+		CreatedAt: report.CreatedAt.AsTime(),
+		Files:     []BatchFile{},
+		Pairs:     []BatchPair{},
+	}
+	for _, file := range report.Files {
+		entry := BatchFile{
+			FileName:            file.FileName,
+			FileID:              file.FileId,
+			Error:               file.Error,
+			SimilarBatchFileIDs: append([]string{}, file.SimilarBatchFileIds...),
+			SimilarOtherFileIDs: append([]string{}, file.SimilarOtherFileIds...),
+			MaxSimilarity:       file.MaxSimilarity,
+		}
+		if file.Job != nil {
+			job := newAnalysisJob(file.Job)
+			entry.Job = &job
+		}
+		resp.Files = append(resp.Files, entry)
+	}
+	for _, pair := range report.Pairs {
+		resp.Pairs = append(resp.Pairs, BatchPair{
+			FileID:      pair.FileId,
+			OtherFileID: pair.OtherFileId,
+			Similarity:  pair.Similarity,
+		})
+	}
+	return resp
+}
+
+// SubmitBatch godoc
+// @Summary Analyze an assignment uploaded as a ZIP archive
+// @Description Store every file of a ZIP archive and queue an analysis of each, comparing the files with each other
+// @Description and with earlier submissions. Directories, dotfiles and __MACOSX entries are skipped, and files in
+// @Description subdirectories are named after their path, e.g. ivanov_report.txt. Entries the File Storing Service
+// @Description rejects are reported with their error instead of failing the batch.
+// @Description Poll the batch at the returned Location for the consolidated report.
+// @Tags batches
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "ZIP archive with the files of the assignment"
+// @Param name formData string false "Name of the batch (default the assignment or the archive name)"
+// @Param uploader formData string false "Who uploads the files"
+// @Param course formData string false "Course the files are submitted for"
+// @Param assignment formData string false "Assignment the files are submitted for"
+// @Param detector formData string false "Plagiarism detector" Enums(jaccard, winnowing)
+// @Param scope formData string false "Files to compare with, by default the configured scope" Enums(assignment, course, global)
+// @Param generate_word_cloud formData bool false "Generate a word cloud of every file"
+// @Success 202 {object} BatchReport "Submitted batch"
+// @Header 202 {string} Location "URL of the batch report"
+// @Failure 400 {object} map[string]string "Bad request, e.g. an archive that is not a ZIP file or has too many files"
+// @Failure 413 {object} map[string]string "Archive too large"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/batches [post]
+func (h *BatchHandler) SubmitBatch(c *gin.Context) {
+	// The archive is spooled to memory or disk, since ZIP entries are located through the central directory at its end
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxArchiveSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Archive too large, expected at most %d bytes", h.limits.MaxArchiveSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No archive provided"})
+		return
+	}
+
+	tags := &storingpb.FileTags{}
+	for _, name := range []string{"uploader", "course", "assignment"} {
+		value := strings.TrimSpace(c.PostForm(name))
+		if len(value) > maxTagLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+			return
+		}
+		*tagField(tags, name) = value
+	}
+
+	generateWordCloud := false
+	if value := c.PostForm("generate_word_cloud"); value != "" {
+		if generateWordCloud, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid generate_word_cloud, expected true or false"})
+			return
+		}
+	}
+
+	// The batch is validated before any file is stored, so a rejected batch leaves no files behind
+	detector := c.PostForm("detector")
+	if !validDetector(detector) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid detector, expected " + strings.Join(detectorNames, " or ")})
+		return
+	}
+	scope, ok := comparisonScope(c.PostForm("scope"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, expected assignment, course or global"})
+		return
+	}
+
+	name := cmp.Or(strings.TrimSpace(c.PostForm("name")), tags.Assignment, strings.TrimSuffix(header.Filename, path.Ext(header.Filename)))
+
+	archive, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer archive.Close()
+
+	zr, err := zip.NewReader(archive, header.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid archive, expected a ZIP file"})
+		return
+	}
+	files, err := archiveFiles(zr, h.limits.MaxFiles)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries := make([]*pb.BatchEntry, 0, len(files))
+	for _, file := range files {
+		entry := &pb.BatchEntry{FileName: file.name}
+		if entry.FileId, err = h.uploadEntry(c, file, tags); err != nil {
+			entry.Error = rejection(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	report, err := h.analysis.SubmitBatch(c.Request.Context(), name, entries, generateWordCloud, nil, detector, scope)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	c.Header("Location", "/api/v1/batches/"+url.PathEscape(report.BatchId))
+	c.JSON(http.StatusAccepted, newBatchReport(report))
+}
+
+// uploadEntry stores a file of an archive and returns its ID
+func (h *BatchHandler) uploadEntry(c *gin.Context, file archiveFile, tags *storingpb.FileTags) (string, error) {
+	content, err := file.entry.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive entry: %w", err)
+	}
+	defer content.Close()
+
+	return h.files.UploadFileStream(c.Request.Context(), file.name, tags, content)
+}
+
+// rejection returns why an entry could not be stored, without the wrapping of the client
+func rejection(err error) string {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return grpcErr.GRPCStatus().Message()
+	}
+	return err.Error()
+}
+
+// archiveFile is a file of an uploaded archive
+type archiveFile struct {
+	name  string // Name to store the file under
+	entry *zip.File
+}
+
+// archiveFiles returns the files of an archive in archive order, skipping directories, dotfiles and the resource forks
+// macOS adds. Files in subdirectories are named after their path, without a top directory all files share.
+func archiveFiles(zr *zip.Reader, maxFiles int) ([]archiveFile, error) {
+	var files []archiveFile
+	var paths [][]string
+	for _, entry := range zr.File {
+		name := entry.Name
+		// Archivers on Windows encode names in the OEM code page rather than UTF-8, which is CP866 for Russian
+		if !utf8.ValidString(name) {
+			if decoded, err := charmap.CodePage866.NewDecoder().String(name); err == nil {
+				name = decoded
+			}
+		}
+		name = strings.Trim(strings.ReplaceAll(name, `\`, "/"), "/")
+
+		if entry.FileInfo().IsDir() || name == "" || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if len(files) == maxFiles {
+			return nil, fmt.Errorf("archive has more than %d files", maxFiles)
+		}
+		files = append(files, archiveFile{name: name, entry: entry})
+		paths = append(paths, strings.Split(name, "/"))
+	}
+	if len(files) == 0 {
+		return nil, errors.New("archive has no files")
+	}
+
+	// Archives of a directory put every file under the directory, which says nothing about the files
+	shared := len(paths[0]) > 1
+	for _, p := range paths[1:] {
+		shared = shared && len(p) > 1 && p[0] == paths[0][0]
+	}
+	for i, p := range paths {
+		if shared {
+			p = p[1:]
+		}
+		files[i].name = strings.Join(p, "_")
+	}
+	return files, nil
+}
+
+// GetBatchReport godoc
+// @Summary Get a batch report
+// @Description Get the consolidated report of the analyses of a batch: the analysis job of every file with its results,
+// @Description the files each file is similar to within the batch and outside of it, and the similar pairs of files of
+// @Description the batch, most similar first. The report is final once its status is finished.
+// @Description As CSV, the report has a row per file naming the similar files of the batch.
+// @Tags batches
+// @Produce json
+// @Produce text/csv
+// @Param batch_id path string true "Batch ID"
+// @Param format query string false "Report format" Enums(json, csv) default(json)
+// @Success 200 {object} BatchReport "Batch report"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Batch not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/batches/{batch_id} [get]
+func (h *BatchHandler) GetBatchReport(c *gin.Context) {
+	batchID := c.Param("batch_id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch ID is required"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected json or csv"})
+		return
+	}
+
+	report, err := h.analysis.GetBatchReport(c.Request.Context(), batchID)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	resp := newBatchReport(report)
+	if format == "json" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var b strings.Builder
+	if err := writeBatchCSV(&b, resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "batch-" + batchID + ".csv"}))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(b.String()))
+}
+
+// batchCSVHeader names the columns of a batch report as CSV
+var batchCSVHeader = []string{
+	"file_name", "file_id", "status", "error", "word_count", "is_plagiarism", "original_file",
+	"max_similarity", "similar_in_batch", "similar_outside_batch",
+}
+
+// writeBatchCSV writes a batch report as CSV with a row per file. Files of the batch are referred to by name,
+// other files by ID. The byte order mark makes spreadsheets read the file names as UTF-8, and cells are escaped
+// with csvCell, since file names come from uploaded archives.
+func writeBatchCSV(w io.Writer, report BatchReport) error {
+	names := make(map[string]string, len(report.Files))
+	for _, file := range report.Files {
+		if file.FileID != "" {
+			names[file.FileID] = file.FileName
+		}
+	}
+	fileName := func(fileID string) string {
+		if name, ok := names[fileID]; ok {
+			return name
+		}
+		return fileID
+	}
+
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(batchCSVHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, file := range report.Files {
+		row := make([]string, len(batchCSVHeader))
+		row[0], row[1] = file.FileName, file.FileID
+		row[2], row[3] = "rejected", file.Error
+		if job := file.Job; job != nil {
+			row[2], row[3] = job.Status, job.Error
+			if result := job.Result; result != nil {
+				row[4] = strconv.Itoa(int(result.WordCount))
+				row[5] = strconv.FormatBool(result.IsPlagiarism)
+				if result.OriginalFileID != "" {
+					row[6] = fileName(result.OriginalFileID)
+				}
+				row[7] = strconv.FormatFloat(file.MaxSimilarity, 'f', 2, 64)
+			}
+		}
+		similar := make([]string, 0, len(file.SimilarBatchFileIDs))
+		for _, fileID := range file.SimilarBatchFileIDs {
+			similar = append(similar, fileName(fileID))
+		}
+		row[8] = strings.Join(similar, "; ")
+		row[9] = strings.Join(file.SimilarOtherFileIDs, "; ")
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// csvCell keeps spreadsheets from evaluating a cell as a formula: cells starting with a character
+// that starts a formula get a leading apostrophe, which spreadsheets show the rest of the cell after as text
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// testArchive builds a ZIP archive with empty entries of the given names
+func testArchive(t *testing.T, names ...string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatalf("Create(%q) error = %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	return zr
+}
+
+func TestArchiveFiles(t *testing.T) {
+	cp866, err := charmap.CodePage866.NewEncoder().String("иванов.txt")
+	if err != nil {
+		t.Fatalf("failed to encode name: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "flat archive",
+			entries: []string{"ivanov.txt", "petrov.txt"},
+			want:    []string{"ivanov.txt", "petrov.txt"},
+		},
+		{
+			name:    "skips directories, dotfiles and resource forks",
+			entries: []string{"ivanov/", "ivanov/report.txt", ".DS_Store", "__MACOSX/ivanov/._report.txt", "petrov.txt"},
+			want:    []string{"ivanov_report.txt", "petrov.txt"},
+		},
+		{
+			name:    "drops the shared top directory",
+			entries: []string{"kr-02/ivanov/report.txt", "kr-02/petrov.txt"},
+			want:    []string{"ivanov_report.txt", "petrov.txt"},
+		},
+		{
+			name:    "keeps the top directories that differ",
+			entries: []string{"ivanov/report.txt", "petrov/report.txt"},
+			want:    []string{"ivanov_report.txt", "petrov_report.txt"},
+		},
+		{
+			name:    "decodes names in CP866",
+			entries: []string{cp866},
+			want:    []string{"иванов.txt"},
+		},
+		{
+			name:    "too many files",
+			entries: []string{"a.txt", "b.txt", "c.txt", "d.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "no files",
+			entries: []string{"kr-02/", ".DS_Store"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := archiveFiles(testArchive(t, tt.entries...), 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("archiveFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, file.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archiveFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteBatchCSV(t *testing.T) {
+	report := BatchReport{
+		Files: []BatchFile{
+			{
+				FileName: "ivanov.txt",
+				FileID:   "file-1",
+				Job: &AnalysisJob{
+					Status: "succeeded",
+					Result: &AnalyzeFileResponse{WordCount: 120, IsPlagiarism: false},
+				},
+				SimilarBatchFileIDs: []string{"file-2"},
+				MaxSimilarity:       0.8,
+			},
+			{
+				FileName: "petrov.txt",
+				FileID:   "file-2",
+				Job: &AnalysisJob{
+					Status: "succeeded",
+					Result: &AnalyzeFileResponse{WordCount: 118, IsPlagiarism: true, OriginalFileID: "file-1"},
+				},
+				SimilarBatchFileIDs: []string{"file-1"},
+				SimilarOtherFileIDs: []string{"earlier-1", "earlier-2"},
+				MaxSimilarity:       0.8,
+			},
+			{FileName: "sidorov.txt", FileID: "file-3", Job: &AnalysisJob{Status: "failed", Error: "file not found"}},
+			{FileName: "smirnov, report.pdf", Error: "file type is not allowed"},
+			{FileName: "=HYPERLINK(\"http://example.com\")", Error: "file type is not allowed"},
+			{FileName: "-2+3.txt", FileID: "file-5", Job: &AnalysisJob{Status: "queued"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBatchCSV(&buf, report); err != nil {
+		t.Fatalf("writeBatchCSV() error = %v", err)
+	}
+
+	want := "\uFEFF" + strings.Join([]string{
+		"file_name,file_id,status,error,word_count,is_plagiarism,original_file,max_similarity,similar_in_batch,similar_outside_batch",
+		"ivanov.txt,file-1,succeeded,,120,false,,0.80,petrov.txt,",
+		"petrov.txt,file-2,succeeded,,118,true,ivanov.txt,0.80,ivanov.txt,earlier-1; earlier-2",
+		"sidorov.txt,file-3,failed,file not found,,,,,,",
+		`"smirnov, report.pdf",,rejected,file type is not allowed,,,,,,`,
+		`"'=HYPERLINK(""http://example.com"")",,rejected,file type is not allowed,,,,,,`,
+		"'-2+3.txt,file-5,queued,,,,,,,",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeBatchCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
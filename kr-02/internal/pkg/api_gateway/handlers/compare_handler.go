@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// comparisonTemplate renders a self-contained side-by-side comparison page
+var comparisonTemplate = template.Must(template.New("comparison").Funcs(template.FuncMap{
+	"percent": func(value float64) string { return fmt.Sprintf("%.1f%%", value*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; color: #222; }
+header { padding: 16px 24px; border-bottom: 1px solid #ddd; background: #f7f7f7; }
+h1 { font-size: 20px; margin: 0 0 12px; }
+.stats { display: flex; gap: 32px; flex-wrap: wrap; font-size: 14px; }
+.stats strong { display: block; font-size: 20px; }
+main { display: flex; gap: 16px; padding: 16px 24px; }
+section { flex: 1; min-width: 0; }
+h2 { font-size: 16px; margin: 0 0 8px; overflow-wrap: anywhere; }
+h2 small { font-weight: normal; color: #666; }
+pre { white-space: pre-wrap; overflow-wrap: anywhere; font: 14px/1.6 monospace; border: 1px solid #ddd; padding: 12px; margin: 0; }
+a.passage { color: inherit; text-decoration: none; }
+mark { background: #ffe08a; }
+mark:target { background: #ff9f43; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<div class="stats">
+<div><strong>{{percent .Similarity}}</strong>similarity</div>
+<div><strong>{{.SharedNGrams}}</strong>shared n-grams</div>
+<div><strong>{{.PassageCount}}</strong>shared passages</div>
+</div>
+</header>
+<main>
+{{range .Sides}}<section>
+<h2>{{.FileName}} <small>{{.FileID}} &middot; {{.NGrams}} n-grams</small></h2>
+<pre>{{range .Segments}}{{if .Passage}}<a class="passage" href="#{{.Target}}"><mark id="{{.Anchor}}" title="Passage {{.Passage}}">{{.Text}}</mark></a>{{else}}{{.Text}}{{end}}{{end}}</pre>
+</section>
+{{end}}</main>
+</body>
+</html>
+`))
+
+// comparisonPage is the data of the comparison page
+type comparisonPage struct {
+	Title        string
+	Similarity   float64
+	SharedNGrams int32
+	PassageCount int
+	Sides        []comparisonSide
+}
+
+// comparisonSide is one of the compared texts, split into highlighted and plain segments
+type comparisonSide struct {
+	FileID   string
+	FileName string
+	NGrams   int32
+	Segments []textSegment
+}
+
+// textSegment is a piece of a compared text. Segments of shared passages link to the same passage in the other text.
+type textSegment struct {
+	Text    string
+	Passage int // 1-based number of the shared passage, 0 for text that is not shared
+	Anchor  string
+	Target  string
+}
+
+// textRange is a shared passage in one of the compared texts, in characters
+type textRange struct {
+	start, end int
+	passage    int
+}
+
+// CompareFiles godoc
+// @Summary Compare two files
+// @Description Render a self-contained HTML page showing two files side by side, with the passages they share highlighted and linked to each other.
+// @Description Words are compared the way plagiarism checks compare them, ignoring case, punctuation and stop words.
+// @Tags analysis
+// @Produce html
+// @Param file_a path string true "ID of the first file"
+// @Param file_b path string true "ID of the second file"
+// @Success 200 {string} string "Comparison page"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/compare/{file_a}/{file_b} [get]
+func (h *AnalysisHandler) CompareFiles(c *gin.Context) {
+	comparison, err := h.client.CompareFiles(c.Request.Context(), c.Param("file_a"), c.Param("file_b"))
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	var sourceRanges, matchRanges []textRange
+	for i, passage := range comparison.Passages {
+		sourceRanges = append(sourceRanges, textRange{int(passage.SourceStart), int(passage.SourceEnd), i + 1})
+		matchRanges = append(matchRanges, textRange{int(passage.MatchStart), int(passage.MatchEnd), i + 1})
+	}
+
+	file, otherFile := comparison.GetFile(), comparison.GetOtherFile()
+	page := comparisonPage{
+		Title:        fmt.Sprintf("%s vs %s", file.GetFileName(), otherFile.GetFileName()),
+		Similarity:   comparison.Similarity,
+		SharedNGrams: comparison.SharedNgramCount,
+		PassageCount: len(comparison.Passages),
+		Sides: []comparisonSide{
+			{
+				FileID:   file.GetFileId(),
+				FileName: file.GetFileName(),
+				NGrams:   comparison.NgramCount,
+				Segments: highlightSegments(file.GetContent(), sourceRanges, "a", "b"),
+			},
+			{
+				FileID:   otherFile.GetFileId(),
+				FileName: otherFile.GetFileName(),
+				NGrams:   comparison.OtherNgramCount,
+				Segments: highlightSegments(otherFile.GetContent(), matchRanges, "b", "a"),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := comparisonTemplate.Execute(&buf, page); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// highlightSegments splits a text into plain segments and segments of shared passages.
+// Ranges count characters; a range overlapping an earlier one is cut to the text after it.
+// Anchors of this text start with prefix and link to anchors of the other text starting with otherPrefix.
+func highlightSegments(text string, ranges []textRange, prefix, otherPrefix string) []textSegment {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	runes := []rune(text)
+	var segments []textSegment
+	pos := 0
+	for _, r := range ranges {
+		start, end := max(r.start, pos), min(r.end, len(runes))
+		if start >= end {
+			continue
+		}
+		if start > pos {
+			segments = append(segments, textSegment{Text: string(runes[pos:start])})
+		}
+		segments = append(segments, textSegment{
+			Text:    string(runes[start:end]),
+			Passage: r.passage,
+			Anchor:  fmt.Sprintf("%s-%d", prefix, r.passage),
+			Target:  fmt.Sprintf("%s-%d", otherPrefix, r.passage),
+		})
+		pos = end
+	}
+	if pos < len(runes) {
+		segments = append(segments, textSegment{Text: string(runes[pos:])})
+	}
+	return segments
+}
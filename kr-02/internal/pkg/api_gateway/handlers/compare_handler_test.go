@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHighlightSegments(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		ranges []textRange
+		want   []textSegment
+	}{
+		{
+			name: "No passages",
+			text: "plain text",
+			want: []textSegment{{Text: "plain text"}},
+		},
+		{
+			name:   "Passages out of order with multibyte characters",
+			text:   "Привет мир, hello world",
+			ranges: []textRange{{12, 23, 1}, {0, 6, 2}},
+			want: []textSegment{
+				{Text: "Привет", Passage: 2, Anchor: "a-2", Target: "b-2"},
+				{Text: " мир, "},
+				{Text: "hello world", Passage: 1, Anchor: "a-1", Target: "b-1"},
+			},
+		},
+		{
+			name:   "Overlapping passages",
+			text:   "one two three four",
+			ranges: []textRange{{0, 7, 1}, {4, 13, 2}, {5, 6, 3}},
+			want: []textSegment{
+				{Text: "one two", Passage: 1, Anchor: "a-1", Target: "b-1"},
+				{Text: " three", Passage: 2, Anchor: "a-2", Target: "b-2"},
+				{Text: " four"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlightSegments(tt.text, tt.ranges, "a", "b")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("highlightSegments() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reasonStatuses maps the reasons of rejected uploads that have a more specific HTTP status than 400
+var reasonStatuses = map[string]int{
+	"FILE_TOO_LARGE":        http.StatusRequestEntityTooLarge,
+	"UNSUPPORTED_FILE_TYPE": http.StatusUnsupportedMediaType,
+}
+
+// httpStatusFromError maps the gRPC status of a service error to an HTTP status code
+func httpStatusFromError(err error) int {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.InvalidArgument:
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok {
+				if code, ok := reasonStatuses[info.Reason]; ok {
+					return code
+				}
+			}
+		}
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorResponse returns the JSON body of a failed request, including the reason of a rejected upload
+func errorResponse(err error) map[string]string {
+	resp := map[string]string{"error": err.Error()}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			resp["reason"] = info.Reason
+		}
+	}
+	return resp
+}
@@ -2,55 +2,280 @@ package handlers
 
 import (
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"kr-02/internal/pkg/api_gateway/clients"
+	pb "kr-02/internal/proto/file_storing_service"
 )
 
 // FileHandler handles file-related operations
 type FileHandler struct {
-	client *clients.FileStoringClient
+	client         *clients.FileStoringClient
+	analysisClient *clients.FileAnalysisClient
 }
 
 // NewFileHandler creates a new FileHandler instance
-func NewFileHandler(client *clients.FileStoringClient) *FileHandler {
-	return &FileHandler{client: client}
+func NewFileHandler(client *clients.FileStoringClient, analysisClient *clients.FileAnalysisClient) *FileHandler {
+	return &FileHandler{
+		client:         client,
+		analysisClient: analysisClient,
+	}
+}
+
+// FileInfo represents metadata of an uploaded file
+type FileInfo struct {
+	FileID     string    `json:"file_id" example:"file123"`
+	FileName   string    `json:"file_name" example:"report.txt"`
+	Size       int64     `json:"size" example:"2048"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-05-20T12:00:00Z"`
+	Hash       string    `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	MIMEType   string    `json:"mime_type" example:"text/plain; charset=utf-8"`
+	Encoding   string    `json:"encoding,omitempty" example:"utf-8"`
+	Uploader   string    `json:"uploader,omitempty" example:"ivanov"`
+	Course     string    `json:"course,omitempty" example:"software-design"`
+	Assignment string    `json:"assignment,omitempty" example:"kr-02"`
+}
+
+// newFileInfo converts file metadata received from the File Storing Service
+func newFileInfo(file *pb.FileInfo) FileInfo {
+	return FileInfo{
+		FileID:     file.FileId,
+		FileName:   file.FileName,
+		Size:       file.Size,
+		CreatedAt:  file.CreatedAt.AsTime(),
+		Hash:       file.Hash,
+		MIMEType:   file.MimeType,
+		Encoding:   file.Encoding,
+		Uploader:   file.GetTags().GetUploader(),
+		Course:     file.GetTags().GetCourse(),
+		Assignment: file.GetTags().GetAssignment(),
+	}
+}
+
+// fileHeaders returns the response headers describing a file
+func fileHeaders(file *pb.FileInfo) map[string]string {
+	return map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}),
+		"ETag":                strconv.Quote(file.Hash),
+		"Last-Modified":       file.CreatedAt.AsTime().UTC().Format(http.TimeFormat),
+		"X-File-Id":           file.FileId,
+		"X-File-Encoding":     file.Encoding,
+		"X-File-Uploader":     file.GetTags().GetUploader(),
+		"X-File-Course":       file.GetTags().GetCourse(),
+		"X-File-Assignment":   file.GetTags().GetAssignment(),
+	}
+}
+
+// contentType returns the detected MIME type of a file, falling back to a generic binary type
+func contentType(file *pb.FileInfo) string {
+	if file.MimeType == "" {
+		return "application/octet-stream"
+	}
+	return file.MimeType
+}
+
+// ListFilesResponse represents a page of uploaded files
+type ListFilesResponse struct {
+	Files         []FileInfo `json:"files"`
+	NextPageToken string     `json:"next_page_token,omitempty" example:"eyJzIjowLCJkIjpmYWxzZX0"`
+}
+
+// sortFields maps the sort query parameter to the sort fields of the File Storing Service
+var sortFields = map[string]pb.FileSortField{
+	"created_at": pb.FileSortField_FILE_SORT_FIELD_CREATED_AT,
+	"name":       pb.FileSortField_FILE_SORT_FIELD_NAME,
+	"size":       pb.FileSortField_FILE_SORT_FIELD_SIZE,
+}
+
+// ListFiles godoc
+// @Summary List files
+// @Description List uploaded files with filtering, sorting and cursor pagination
+// @Tags files
+// @Produce json
+// @Param page_size query int false "Number of files per page (default 20, at most 100)"
+// @Param page_token query string false "next_page_token of the previous page"
+// @Param name query string false "Case-insensitive substring of the file name"
+// @Param uploaded_after query string false "Only files uploaded at or after this time (RFC 3339)"
+// @Param uploaded_before query string false "Only files uploaded before this time (RFC 3339)"
+// @Param min_size query int false "Minimum file size in bytes"
+// @Param max_size query int false "Maximum file size in bytes"
+// @Param sort query string false "Sort field: created_at (default), name or size"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Success 200 {object} ListFilesResponse "A page of files"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files [get]
+func (h *FileHandler) ListFiles(c *gin.Context) {
+	request := &pb.ListFilesRequest{
+		PageToken:    c.Query("page_token"),
+		NameContains: c.Query("name"),
+	}
+
+	var err error
+	if value := c.Query("page_size"); value != "" {
+		var pageSize int64
+		if pageSize, err = strconv.ParseInt(value, 10, 32); err != nil || pageSize < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page_size"})
+			return
+		}
+		request.PageSize = int32(pageSize)
+	}
+	if value := c.Query("min_size"); value != "" {
+		if request.MinSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_size"})
+			return
+		}
+	}
+	if value := c.Query("max_size"); value != "" {
+		if request.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_size"})
+			return
+		}
+	}
+	if value := c.Query("uploaded_after"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uploaded_after, expected RFC 3339 time"})
+			return
+		}
+		request.UploadedAfter = timestamppb.New(t)
+	}
+	if value := c.Query("uploaded_before"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uploaded_before, expected RFC 3339 time"})
+			return
+		}
+		request.UploadedBefore = timestamppb.New(t)
+	}
+	if value := c.Query("sort"); value != "" {
+		sortBy, ok := sortFields[value]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected created_at, name or size"})
+			return
+		}
+		request.SortBy = sortBy
+	}
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		request.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order, expected asc or desc"})
+		return
+	}
+
+	resp, err := h.client.ListFiles(c.Request.Context(), request)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	files := make([]FileInfo, 0, len(resp.Files))
+	for _, file := range resp.Files {
+		files = append(files, newFileInfo(file))
+	}
+
+	c.JSON(http.StatusOK, ListFilesResponse{
+		Files:         files,
+		NextPageToken: resp.NextPageToken,
+	})
 }
 
 // UploadFile godoc
 // @Summary Upload a file
-// @Description Upload a file to the storage
+// @Description Upload a file to the storage. The uploader, course and assignment can be given as form fields
+// @Description preceding the file, or as query parameters.
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
+// @Param uploader formData string false "Who uploads the file"
+// @Param course formData string false "Course the file is submitted for"
+// @Param assignment formData string false "Assignment the file is submitted for"
 // @Success 200 {object} map[string]string "Returns the file ID"
-// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 400 {object} map[string]string "Bad request, e.g. an empty file or text that is not UTF-8 (reason EMPTY_FILE or INVALID_ENCODING)"
+// @Failure 413 {object} map[string]string "File too large (reason FILE_TOO_LARGE)"
+// @Failure 415 {object} map[string]string "File extension or content type not allowed (reason UNSUPPORTED_FILE_TYPE)"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/files [post]
 func (h *FileHandler) UploadFile(c *gin.Context) {
-	file, header, err := c.Request.FormFile("file")
+	// Read the multipart body part by part so the file is piped through without buffering
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected multipart/form-data request"})
 		return
 	}
-	defer file.Close()
 
-	content, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
+	tags := &pb.FileTags{
+		Uploader:   c.Query("uploader"),
+		Course:     c.Query("course"),
+		Assignment: c.Query("assignment"),
 	}
 
-	fileID, err := h.client.UploadFile(c.Request.Context(), header.Filename, content)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Malformed multipart body"})
+			return
+		}
+
+		// Tag fields must come before the file, which is sent on as soon as it is reached
+		if field := tagField(tags, part.FormName()); field != nil && part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxTagLength+1))
+			part.Close()
+			if err != nil || len(value) > maxTagLength {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + part.FormName()})
+				return
+			}
+			*field = strings.TrimSpace(string(value))
+			continue
+		}
+
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		fileID, err := h.client.UploadFileStream(c.Request.Context(), part.FileName(), tags, part)
+		part.Close()
+		if err != nil {
+			c.JSON(httpStatusFromError(err), errorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"file_id": fileID})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"file_id": fileID})
+	c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+}
+
+// maxTagLength is the maximum length of an uploader, course or assignment tag
+const maxTagLength = 256
+
+// tagField returns the tag a form field sets, or nil for other fields
+func tagField(tags *pb.FileTags, name string) *string {
+	switch name {
+	case "uploader":
+		return &tags.Uploader
+	case "course":
+		return &tags.Course
+	case "assignment":
+		return &tags.Assignment
+	default:
+		return nil
+	}
 }
 
 // GetFile godoc
@@ -71,12 +296,101 @@ func (h *FileHandler) GetFile(c *gin.Context) {
 		return
 	}
 
-	fileName, content, err := h.client.GetFile(c.Request.Context(), fileID)
+	file, content, err := h.client.GetFileStream(c.Request.Context(), fileID)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, contentType(file), content, fileHeaders(file))
+}
+
+// HeadFile godoc
+// @Summary Describe a file
+// @Description Get the headers of a file download without its content
+// @Tags files
+// @Param file_id path string true "File ID"
+// @Success 200 "File headers: Content-Length, Content-Type, ETag, Last-Modified, Content-Disposition and X-File-*"
+// @Failure 404 "File not found"
+// @Failure 500 "Internal server error"
+// @Router /api/v1/files/{file_id} [head]
+func (h *FileHandler) HeadFile(c *gin.Context) {
+	file, err := h.client.GetFileMetadata(c.Request.Context(), c.Param("file_id"))
+	if err != nil {
+		c.Status(httpStatusFromError(err))
+		return
+	}
+
+	for key, value := range fileHeaders(file) {
+		c.Header(key, value)
+	}
+	c.Header("Content-Type", contentType(file))
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	c.Status(http.StatusOK)
+}
+
+// GetFileMetadata godoc
+// @Summary Get file metadata
+// @Description Get the size, MIME type, encoding and uploader of a file without its content
+// @Tags files
+// @Produce json
+// @Param file_id path string true "File ID"
+// @Success 200 {object} FileInfo "File metadata"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{file_id}/metadata [get]
+func (h *FileHandler) GetFileMetadata(c *gin.Context) {
+	fileID := c.Param("file_id")
+	if fileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		return
+	}
+
+	file, err := h.client.GetFileMetadata(c.Request.Context(), fileID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newFileInfo(file))
+}
+
+// DeleteFile godoc
+// @Summary Delete a file
+// @Description Withdraw a file: delete its content, metadata, analysis results, similarity records and word cloud
+// @Tags files
+// @Param file_id path string true "File ID"
+// @Success 204 "File deleted"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{file_id} [delete]
+func (h *FileHandler) DeleteFile(c *gin.Context) {
+	fileID := c.Param("file_id")
+	if fileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		return
+	}
+
+	// Delete the file first, so analyses no longer find it; deleting the analysis is repeated by a retry
+	// that finds the file deleted already
+	if err := h.client.DeleteFile(c.Request.Context(), fileID); err != nil {
+		if httpStatusFromError(err) == http.StatusNotFound {
+			if err := h.analysisClient.DeleteAnalysis(c.Request.Context(), fileID); err != nil {
+				c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.analysisClient.DeleteAnalysis(c.Request.Context(), fileID); err != nil {
+		c.JSON(httpStatusFromError(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename="+fileName)
-	c.Data(http.StatusOK, "application/octet-stream", content)
+	c.Status(http.StatusNoContent)
 }
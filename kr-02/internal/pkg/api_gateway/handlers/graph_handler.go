@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "kr-02/internal/proto/file_analysis_service"
+)
+
+// clusterMethods maps the names of cluster methods in the API to their protobuf representation
+var clusterMethods = map[string]pb.ClusterMethod{
+	"components": pb.ClusterMethod_CLUSTER_METHOD_COMPONENTS,
+	"cliques":    pb.ClusterMethod_CLUSTER_METHOD_CLIQUES,
+}
+
+// PlagiarismClustersResponse represents the clusters of similar files, largest first
+type PlagiarismClustersResponse struct {
+	Clusters []PlagiarismCluster `json:"clusters"`
+}
+
+// PlagiarismCluster represents a group of files that share text
+type PlagiarismCluster struct {
+	FileIDs           []string `json:"file_ids" example:"file042,file123,file456"`
+	OriginalFileID    string   `json:"original_file_id,omitempty" example:"file042"`
+	EdgeCount         int32    `json:"edge_count" example:"3"`
+	MaxSimilarity     float64  `json:"max_similarity" example:"0.81"`
+	AverageSimilarity float64  `json:"average_similarity" example:"0.57"`
+}
+
+// GetPlagiarismClusters godoc
+// @Summary Get plagiarism clusters
+// @Description Group similar files into clusters of files that share text, largest first.
+// @Description With method components a cluster holds the files connected by a chain of similar pairs, and every file is in one cluster.
+// @Description With method cliques a cluster holds files that are all similar to each other, and a file may be in several clusters.
+// @Tags plagiarism
+// @Produce json
+// @Param method query string false "Clustering method" Enums(components, cliques) default(components)
+// @Param min_similarity query number false "Only pairs of files at least this similar, from 0 to 1"
+// @Param uploaded_after query string false "Only files uploaded at or after this time (RFC 3339)"
+// @Param uploaded_before query string false "Only files uploaded before this time (RFC 3339)"
+// @Success 200 {object} PlagiarismClustersResponse "Plagiarism clusters"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/plagiarism/clusters [get]
+func (h *AnalysisHandler) GetPlagiarismClusters(c *gin.Context) {
+	filter, err := similarityGraphFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	method := pb.ClusterMethod_CLUSTER_METHOD_COMPONENTS
+	if value := c.Query("method"); value != "" {
+		var ok bool
+		if method, ok = clusterMethods[value]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid method, expected components or cliques"})
+			return
+		}
+	}
+
+	clusters, err := h.client.GetPlagiarismClusters(c.Request.Context(), filter, method)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	resp := PlagiarismClustersResponse{Clusters: []PlagiarismCluster{}}
+	for _, cluster := range clusters.Clusters {
+		resp.Clusters = append(resp.Clusters, PlagiarismCluster{
+			FileIDs:           cluster.FileIds,
+			OriginalFileID:    cluster.OriginalFileId,
+			EdgeCount:         cluster.EdgeCount,
+			MaxSimilarity:     cluster.MaxSimilarity,
+			AverageSimilarity: cluster.AverageSimilarity,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// graphFormats are the formats the similarity graph can be exported in, with their content types and file extensions
+var graphFormats = map[string]struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, graph *pb.SimilarityGraph) error
+}{
+	"graphml": {"application/graphml+xml", "graphml", writeGraphML},
+	"dot":     {"text/vnd.graphviz; charset=utf-8", "dot", writeDOT},
+}
+
+// ExportSimilarityGraph godoc
+// @Summary Export the similarity graph
+// @Description Export similar files as nodes and pairs of similar files as undirected edges weighted by their similarity,
+// @Description as GraphML (for Gephi, yEd or Cytoscape) or as DOT (for Graphviz).
+// @Description Nodes carry the upload time and the number of the connected component of the file; DOT groups components into subgraphs.
+// @Tags plagiarism
+// @Produce application/graphml+xml
+// @Produce text/vnd.graphviz
+// @Param format query string false "Export format" Enums(graphml, dot) default(graphml)
+// @Param min_similarity query number false "Only pairs of files at least this similar, from 0 to 1"
+// @Param uploaded_after query string false "Only files uploaded at or after this time (RFC 3339)"
+// @Param uploaded_before query string false "Only files uploaded before this time (RFC 3339)"
+// @Success 200 {file} binary "Similarity graph"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/plagiarism/graph [get]
+func (h *AnalysisHandler) ExportSimilarityGraph(c *gin.Context) {
+	filter, err := similarityGraphFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, ok := graphFormats[c.DefaultQuery("format", "graphml")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected graphml or dot"})
+		return
+	}
+
+	graph, err := h.client.GetSimilarityGraph(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(httpStatusFromError(err), errorResponse(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := format.write(&buf, graph); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="similarity-graph.%s"`, format.extension))
+	c.Data(http.StatusOK, format.contentType, buf.Bytes())
+}
+
+// similarityGraphFilter parses the similarity and upload time filters of a similarity graph request
+func similarityGraphFilter(c *gin.Context) (*pb.SimilarityGraphFilter, error) {
+	filter := &pb.SimilarityGraphFilter{}
+	if value := c.Query("min_similarity"); value != "" {
+		minSimilarity, err := strconv.ParseFloat(value, 64)
+		if err != nil || minSimilarity < 0 || minSimilarity > 1 {
+			return nil, errors.New("invalid min_similarity, expected a number from 0 to 1")
+		}
+		filter.MinSimilarity = minSimilarity
+	}
+	if value := c.Query("uploaded_after"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("invalid uploaded_after, expected RFC 3339 time")
+		}
+		filter.UploadedAfter = timestamppb.New(t)
+	}
+	if value := c.Query("uploaded_before"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("invalid uploaded_before, expected RFC 3339 time")
+		}
+		filter.UploadedBefore = timestamppb.New(t)
+	}
+	return filter, nil
+}
+
+// graphML is the root element of a GraphML document
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// graphMLKey declares an attribute of nodes or edges
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+// graphMLGraph is the graph of a GraphML document
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+// graphMLNode is a file of the similarity graph
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+// graphMLEdge is a pair of similar files
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// graphMLData is the value of an attribute declared by a key
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// writeGraphML writes the similarity graph as an undirected GraphML graph
+func writeGraphML(w io.Writer, graph *pb.SimilarityGraph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "uploaded_at", For: "node", Name: "uploaded_at", Type: "string"},
+			{ID: "component", For: "node", Name: "component", Type: "int"},
+			{ID: "similarity", For: "edge", Name: "similarity", Type: "double"},
+		},
+		Graph: graphMLGraph{ID: "similarity", EdgeDefault: "undirected"},
+	}
+	for _, file := range graph.Files {
+		node := graphMLNode{ID: file.FileId}
+		if file.UploadedAt != nil {
+			node.Data = append(node.Data, graphMLData{Key: "uploaded_at", Value: file.UploadedAt.AsTime().Format(time.RFC3339)})
+		}
+		node.Data = append(node.Data, graphMLData{Key: "component", Value: strconv.Itoa(int(file.Component))})
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: edge.FileId,
+			Target: edge.OtherFileId,
+			Data:   []graphMLData{{Key: "similarity", Value: strconv.FormatFloat(edge.Similarity, 'g', -1, 64)}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write GraphML: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write GraphML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeDOT writes the similarity graph as an undirected Graphviz graph with a subgraph per connected component.
+// Edges are labeled with their similarity and drawn thicker the more similar the files are.
+func writeDOT(w io.Writer, graph *pb.SimilarityGraph) error {
+	var b strings.Builder
+	b.WriteString("graph similarity {\n")
+	b.WriteString("  node [shape=box];\n")
+
+	// Files are in upload order, so files of a component are collected first
+	var components [][]*pb.GraphFile
+	for _, file := range graph.Files {
+		for int(file.Component) > len(components) {
+			components = append(components, nil)
+		}
+		if file.Component > 0 {
+			components[file.Component-1] = append(components[file.Component-1], file)
+		}
+	}
+	for i, files := range components {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i+1)
+		fmt.Fprintf(&b, "    label=\"Cluster %d\";\n", i+1)
+		for _, file := range files {
+			label := file.FileId
+			if file.UploadedAt != nil {
+				label += "\n" + file.UploadedAt.AsTime().Format(time.DateTime)
+			}
+			fmt.Fprintf(&b, "    %s [label=%s];\n", dotQuote(file.FileId), dotQuote(label))
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -- %s [label=\"%.2f\", penwidth=%.1f];\n",
+			dotQuote(edge.FileId), dotQuote(edge.OtherFileId), edge.Similarity, 1+4*edge.Similarity)
+	}
+	b.WriteString("}\n")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write DOT: %w", err)
+	}
+	return nil
+}
+
+// dotQuote quotes a string as a DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
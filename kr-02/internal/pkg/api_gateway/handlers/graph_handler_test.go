@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "kr-02/internal/proto/file_analysis_service"
+)
+
+// testSimilarityGraph has two components; the file "b" of the first one has an unknown upload time
+func testSimilarityGraph() *pb.SimilarityGraph {
+	uploadedAt := timestamppb.New(time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC))
+	return &pb.SimilarityGraph{
+		Files: []*pb.GraphFile{
+			{FileId: "a", UploadedAt: uploadedAt, Component: 1},
+			{FileId: `c "quoted"`, UploadedAt: uploadedAt, Component: 2},
+			{FileId: "d", UploadedAt: uploadedAt, Component: 2},
+			{FileId: "b", Component: 1},
+		},
+		Edges: []*pb.SimilarityEdge{
+			{FileId: `c "quoted"`, OtherFileId: "d", Similarity: 0.9},
+			{FileId: "a", OtherFileId: "b", Similarity: 0.25},
+		},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDOT(&buf, testSimilarityGraph()); err != nil {
+		t.Fatalf("writeDOT() error = %v", err)
+	}
+
+	want := `graph similarity {
+  node [shape=box];
+  subgraph cluster_1 {
+    label="Cluster 1";
+    "a" [label="a\n2025-05-20 12:00:00"];
+    "b" [label="b"];
+  }
+  subgraph cluster_2 {
+    label="Cluster 2";
+    "c \"quoted\"" [label="c \"quoted\"\n2025-05-20 12:00:00"];
+    "d" [label="d\n2025-05-20 12:00:00"];
+  }
+  "c \"quoted\"" -- "d" [label="0.90", penwidth=4.6];
+  "a" -- "b" [label="0.25", penwidth=2.0];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writeDOT() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeGraphML(&buf, testSimilarityGraph()); err != nil {
+		t.Fatalf("writeGraphML() error = %v", err)
+	}
+
+	// The document must read back as the same graph
+	var doc graphML
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("writeGraphML() wrote invalid XML: %v\n%s", err, buf.String())
+	}
+	if doc.Graph.EdgeDefault != "undirected" {
+		t.Errorf("edgedefault = %q, want undirected", doc.Graph.EdgeDefault)
+	}
+	if len(doc.Keys) != 3 {
+		t.Errorf("got %d keys, want 3", len(doc.Keys))
+	}
+
+	data := func(values []graphMLData) map[string]string {
+		m := make(map[string]string)
+		for _, d := range values {
+			m[d.Key] = d.Value
+		}
+		return m
+	}
+	if len(doc.Graph.Nodes) != 4 {
+		t.Fatalf("got %d nodes, want 4", len(doc.Graph.Nodes))
+	}
+	if node := doc.Graph.Nodes[1]; node.ID != `c "quoted"` || data(node.Data)["uploaded_at"] != "2025-05-20T12:00:00Z" || data(node.Data)["component"] != "2" {
+		t.Errorf("node = %+v, want c \"quoted\" uploaded at 2025-05-20T12:00:00Z in component 2", node)
+	}
+	if _, ok := data(doc.Graph.Nodes[3].Data)["uploaded_at"]; ok {
+		t.Errorf("node %s has an upload time, want none", doc.Graph.Nodes[3].ID)
+	}
+	if len(doc.Graph.Edges) != 2 {
+		t.Fatalf("got %d edges, want 2", len(doc.Graph.Edges))
+	}
+	if edge := doc.Graph.Edges[1]; edge.Source != "a" || edge.Target != "b" || data(edge.Data)["similarity"] != "0.25" {
+		t.Errorf("edge = %+v, want a -- b with similarity 0.25", edge)
+	}
+}
@@ -0,0 +1,36 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ReEncrypter rewrites stored content with the primary key of its keyring.
+// It reports whether the content had to be rewritten, i.e. was plaintext or encrypted with another key.
+type ReEncrypter interface {
+	ReEncrypt(ctx context.Context, location string) (bool, error)
+}
+
+// RunReEncrypt executes the reencrypt subcommand for the content at the given locations, writing progress to out.
+// Failures are reported and skipped, so the command can be run again to retry them.
+func RunReEncrypt(ctx context.Context, r ReEncrypter, locations []string, out io.Writer) error {
+	rewritten, failed := 0, 0
+	for _, location := range locations {
+		changed, err := r.ReEncrypt(ctx, location)
+		if err != nil {
+			fmt.Fprintf(out, "%s\tfailed: %v\n", location, err)
+			failed++
+			continue
+		}
+		if changed {
+			rewritten++
+		}
+	}
+
+	fmt.Fprintf(out, "Re-encrypted %d of %d items\n", rewritten, len(locations))
+	if failed > 0 {
+		return fmt.Errorf("failed to re-encrypt %d items", failed)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeySize is the size of key encryption keys and data keys, selecting AES-256
+const KeySize = 32
+
+// ErrUnknownKey is returned for content encrypted with a key the keyring does not hold
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the key encryption keys by ID.
+// New content is encrypted with the primary key; the other keys are kept to decrypt older content.
+type Keyring struct {
+	primaryID string
+	keys      map[string]cipher.AEAD
+}
+
+// keyFile is the JSON format of a key file, e.g.
+// {"primary": "2025-06", "keys": {"2025-06": "<base64>", "2025-01": "<base64>"}}
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LoadKeyring reads a keyring from a JSON key file
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return NewKeyring(file.Primary, keys)
+}
+
+// NewKeyring creates a keyring from raw 32-byte keys
+func NewKeyring(primaryID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primaryID]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primaryID)
+	}
+
+	keyring := &Keyring{primaryID: primaryID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("key ID %q must be 1 to %d bytes long", id, maxKeyIDLength)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes long, got %d", id, KeySize, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize key %q: %w", id, err)
+		}
+		keyring.keys[id] = aead
+	}
+	return keyring, nil
+}
+
+// PrimaryKeyID returns the ID of the key new content is encrypted with
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primaryID
+}
+
+// key returns the key encryption key with the given ID
+func (k *Keyring) key(id string) (cipher.AEAD, error) {
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return aead, nil
+}
+
+// newAEAD creates AES-GCM with a random 12-byte nonce for a key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,249 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted content starts with a header carrying the data key wrapped by a key encryption key:
+//
+//	magic | key ID length (1) | key ID | wrap nonce (12) | wrapped data key (32+16) | nonce prefix (7)
+//
+// followed by the content split into segments of segmentSize bytes, each sealed with AES-GCM under the data key.
+// A segment nonce is the prefix, the big-endian segment number and a final-segment flag,
+// so segments cannot be reordered, dropped or truncated without failing authentication.
+const (
+	segmentSize    = 64 * 1024
+	tagSize        = 16
+	noncePrefixLen = 7
+	maxKeyIDLength = 255
+)
+
+// magic marks encrypted content; content without it is read as plaintext written before encryption was enabled
+var magic = []byte("KRENC\x00\x00\x01")
+
+// ErrCorrupted is returned for encrypted content that fails authentication
+var ErrCorrupted = errors.New("encrypted content is corrupted")
+
+// Encrypt returns a reader of the encrypted content read from r, using a new data key wrapped by the primary key
+func (k *Keyring) Encrypt(r io.Reader) (io.Reader, error) {
+	dataKey := make([]byte, KeySize)
+	noncePrefix := make([]byte, noncePrefixLen)
+	wrapNonce := make([]byte, 12)
+	for _, b := range [][]byte{dataKey, noncePrefix, wrapNonce} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize data key: %w", err)
+	}
+
+	// The key ID is authenticated with the wrapped key, so it cannot be swapped
+	keyID := k.primaryID
+	header := append([]byte{}, magic...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, wrapNonce...)
+	header = k.keys[keyID].Seal(header, wrapNonce, dataKey, []byte(keyID))
+	header = append(header, noncePrefix...)
+
+	return &encryptReader{
+		src:         r,
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		out:         header,
+		buf:         make([]byte, 0, segmentSize+1),
+	}, nil
+}
+
+// Decrypt returns a reader of the plaintext of content read from r.
+// Content without the encryption header is returned as is.
+func (k *Keyring) Decrypt(r io.Reader) (io.Reader, error) {
+	keyID, encrypted, r, err := readKeyID(r)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return r, nil
+	}
+
+	kek, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unwrap the data key
+	wrapped := make([]byte, 12+KeySize+tagSize+noncePrefixLen)
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	dataKey, err := kek.Open(nil, wrapped[:12], wrapped[12:12+KeySize+tagSize], []byte(keyID))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize data key: %w", err)
+	}
+
+	return &decryptReader{
+		src:         r,
+		aead:        aead,
+		noncePrefix: wrapped[12+KeySize+tagSize:],
+		buf:         make([]byte, 0, segmentSize+tagSize+1),
+	}, nil
+}
+
+// KeyID returns the ID of the key content was encrypted with, reading only its header.
+// It returns false for plaintext content.
+func KeyID(r io.Reader) (string, bool, error) {
+	keyID, encrypted, _, err := readKeyID(r)
+	return keyID, encrypted, err
+}
+
+// readKeyID reads the start of the header and returns the reader positioned after the key ID.
+// For plaintext content the returned reader yields the full content.
+func readKeyID(r io.Reader) (string, bool, io.Reader, error) {
+	prefix := make([]byte, len(magic)+1)
+	n, err := io.ReadFull(r, prefix)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (err == nil && !bytes.Equal(prefix[:len(magic)], magic)) {
+		return "", false, io.MultiReader(bytes.NewReader(prefix[:n]), r), nil
+	}
+	if err != nil {
+		return "", false, nil, fmt.Errorf("failed to read content: %w", err)
+	}
+
+	keyID := make([]byte, prefix[len(magic)])
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return "", false, nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	return string(keyID), true, r, nil
+}
+
+// segmentNonce returns the nonce of a segment
+func segmentNonce(prefix []byte, segment uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixLen:], segment)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptReader seals the source segment by segment as it is read
+type encryptReader struct {
+	src         io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	segment     uint32
+	buf         []byte // plaintext read ahead; one byte past a segment tells whether it is the final one
+	out         []byte // sealed output not yet returned
+	done        bool
+}
+
+// Read returns encrypted content, sealing the next segment when needed
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// seal reads and seals the next segment
+func (r *encryptReader) seal() error {
+	n, err := io.ReadFull(r.src, r.buf[len(r.buf):cap(r.buf)])
+	r.buf = r.buf[:len(r.buf)+n]
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	final := len(r.buf) <= segmentSize
+	plaintext := r.buf
+	if !final {
+		plaintext = r.buf[:segmentSize]
+	}
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.noncePrefix, r.segment, final), plaintext, nil)
+
+	if final {
+		r.done = true
+		return nil
+	}
+	if r.segment == ^uint32(0) {
+		return errors.New("content is too large to encrypt")
+	}
+	r.segment++
+	r.buf = append(r.buf[:0], r.buf[segmentSize:]...)
+	return nil
+}
+
+// decryptReader opens the source segment by segment as it is read
+type decryptReader struct {
+	src         io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	segment     uint32
+	buf         []byte // ciphertext read ahead; one byte past a segment tells whether it is the final one
+	out         []byte // plaintext not yet returned
+	done        bool
+}
+
+// Read returns decrypted content, opening the next segment when needed
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// open reads and opens the next segment
+func (r *decryptReader) open() error {
+	n, err := io.ReadFull(r.src, r.buf[len(r.buf):cap(r.buf)])
+	r.buf = r.buf[:len(r.buf)+n]
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	final := len(r.buf) <= segmentSize+tagSize
+	ciphertext := r.buf
+	if !final {
+		ciphertext = r.buf[:segmentSize+tagSize]
+	}
+	out, openErr := r.aead.Open(r.out[:0], segmentNonce(r.noncePrefix, r.segment, final), ciphertext, nil)
+	if openErr != nil {
+		return ErrCorrupted
+	}
+	r.out = out
+
+	if final {
+		r.done = true
+		return nil
+	}
+	r.segment++
+	r.buf = append(r.buf[:0], r.buf[segmentSize+tagSize:]...)
+	return nil
+}
@@ -0,0 +1,137 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, primaryID string, ids ...string) *Keyring {
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), KeySize)
+	}
+	keyring, err := NewKeyring(primaryID, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keyring
+}
+
+func encrypt(t *testing.T, keyring *Keyring, plaintext []byte) []byte {
+	r, err := keyring.Encrypt(bytes.NewReader(plaintext))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading encrypted content error = %v", err)
+	}
+	return ciphertext
+}
+
+func decrypt(keyring *Keyring, ciphertext []byte) ([]byte, error) {
+	r, err := keyring.Decrypt(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	keyring := newTestKeyring(t, "a", "a")
+
+	// Sizes around segment boundaries
+	for _, size := range []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 17} {
+		plaintext := bytes.Repeat([]byte("отчёт "), size/len("отчёт ")+1)[:size]
+
+		ciphertext := encrypt(t, keyring, plaintext)
+		if size >= 16 && bytes.Contains(ciphertext, plaintext) {
+			t.Errorf("size %d: ciphertext contains the plaintext", size)
+		}
+
+		decrypted, err := decrypt(keyring, ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: decrypt error = %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %d: decrypted content differs from the plaintext", size)
+		}
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	oldKeyring := newTestKeyring(t, "a", "a")
+	newKeyring := newTestKeyring(t, "b", "a", "b")
+
+	ciphertext := encrypt(t, oldKeyring, []byte("report"))
+	if keyID, encrypted, err := KeyID(bytes.NewReader(ciphertext)); err != nil || !encrypted || keyID != "a" {
+		t.Errorf("KeyID() = %q, %v, %v; want %q, true, nil", keyID, encrypted, err, "a")
+	}
+
+	// Content encrypted with a retired key is still readable
+	if decrypted, err := decrypt(newKeyring, ciphertext); err != nil || string(decrypted) != "report" {
+		t.Errorf("decrypt with rotated keyring = %q, %v; want %q, nil", decrypted, err, "report")
+	}
+
+	// Content encrypted with a key that is gone is not
+	if _, err := decrypt(newTestKeyring(t, "b", "b"), ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("decrypt without the key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestKeyring_Plaintext(t *testing.T) {
+	keyring := newTestKeyring(t, "a", "a")
+
+	// Content stored before encryption was enabled is read as is
+	for _, plaintext := range []string{"", "short", "a report longer than the header"} {
+		if _, encrypted, _ := KeyID(bytes.NewReader([]byte(plaintext))); encrypted {
+			t.Errorf("KeyID(%q) reports encrypted content", plaintext)
+		}
+		if decrypted, err := decrypt(keyring, []byte(plaintext)); err != nil || string(decrypted) != plaintext {
+			t.Errorf("decrypt(%q) = %q, %v", plaintext, decrypted, err)
+		}
+	}
+}
+
+func TestKeyring_Tampering(t *testing.T) {
+	keyring := newTestKeyring(t, "a", "a")
+	ciphertext := encrypt(t, keyring, bytes.Repeat([]byte("x"), 2*segmentSize+10))
+
+	tests := map[string][]byte{
+		"flipped bit":          append(append([]byte{}, ciphertext[:len(ciphertext)-5]...), append([]byte{ciphertext[len(ciphertext)-5] ^ 1}, ciphertext[len(ciphertext)-4:]...)...),
+		"truncated segment":    ciphertext[:len(ciphertext)-1],
+		"dropped last segment": ciphertext[:len(ciphertext)-(10+tagSize)],
+	}
+	for name, tampered := range tests {
+		if _, err := decrypt(keyring, tampered); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("%s: decrypt error = %v, want %v", name, err, ErrCorrupted)
+		}
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"primary": "2025-06", "keys": {"2025-06": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("LoadKeyring() error = %v", err)
+	}
+	if keyring.PrimaryKeyID() != "2025-06" {
+		t.Errorf("PrimaryKeyID() = %q, want %q", keyring.PrimaryKeyID(), "2025-06")
+	}
+
+	if _, err := NewKeyring("missing", map[string][]byte{"a": make([]byte, KeySize)}); err == nil {
+		t.Errorf("NewKeyring() without the primary key error = nil")
+	}
+	if _, err := NewKeyring("a", map[string][]byte{"a": make([]byte, 16)}); err == nil {
+		t.Errorf("NewKeyring() with a short key error = nil")
+	}
+}
@@ -0,0 +1,43 @@
+package analyzer
+
+// Comparison describes how similar two texts are and which passages they share
+type Comparison struct {
+	Similarity   float64 // Jaccard similarity of the n-gram sets, 1 for exact matches
+	NGrams       int     // Distinct n-grams of the first text
+	OtherNGrams  int     // Distinct n-grams of the second text
+	SharedNGrams int     // Distinct n-grams both texts contain
+	Passages     []Passage
+}
+
+// Compare compares two texts with the same preprocessing as CheckPlagiarism
+// and finds all passages they share, longest first
+func (c *PlagiarismChecker) Compare(content, otherContent string) Comparison {
+	fingerprint := c.Fingerprint(content)
+	otherFingerprint := c.Fingerprint(otherContent)
+
+	return Comparison{
+		Similarity:   c.Similarity(fingerprint, otherFingerprint),
+		NGrams:       len(fingerprint.NGrams),
+		OtherNGrams:  len(otherFingerprint.NGrams),
+		SharedNGrams: countShared(fingerprint.NGrams, otherFingerprint.NGrams),
+		Passages:     c.MatchingPassages(content, otherContent, nil, -1),
+	}
+}
+
+// countShared counts the values two sorted sets have in common
+func countShared(values1, values2 []uint64) int {
+	shared := 0
+	for i, j := 0, 0; i < len(values1) && j < len(values2); {
+		switch {
+		case values1[i] < values2[j]:
+			i++
+		case values1[i] > values2[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return shared
+}
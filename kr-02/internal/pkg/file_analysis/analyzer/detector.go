@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownDetector is returned when a detector is requested by a name that is not registered
+var ErrUnknownDetector = errors.New("unknown plagiarism detector")
+
+// Detector is a plagiarism detection strategy.
+// Fingerprints are only comparable with fingerprints computed by the same detector with the same version.
+type Detector interface {
+	// Name identifies the detector in requests, configuration and stored fingerprints
+	Name() string
+
+	// Version identifies the settings fingerprints are computed with
+	Version() string
+
+	// Fingerprint preprocesses the content and computes its fingerprint
+	Fingerprint(content string) Fingerprint
+
+	// Similarity computes the similarity of two fingerprints, from 0 to 1
+	Similarity(fingerprint1, fingerprint2 Fingerprint) float64
+
+	// Threshold returns the similarity from which a file is considered plagiarized
+	Threshold() float64
+}
+
+// CandidateIndexer is implemented by detectors whose similar files the LSH index of MinHash signatures,
+// which estimates the Jaccard similarity, does not find reliably. Their candidate files are looked up
+// by the keys the detector returns instead: files sharing any key with the fingerprint are candidates.
+type CandidateIndexer interface {
+	// CandidateKeys returns the keys of a fingerprint that similar files share with it
+	CandidateKeys(fingerprint Fingerprint) []uint64
+}
+
+// SimilarFile is a file whose similarity to a checked content reached the threshold
+type SimilarFile struct {
+	FileID     string
+	Similarity float64 // Similarity computed by the detector, 1 for exact matches
+}
+
+// ScoreFingerprints compares the fingerprint with the other fingerprints using the detector
+// and returns the files whose similarity reaches its threshold, most similar first
+func ScoreFingerprints(detector Detector, fingerprint Fingerprint, otherFingerprints map[string]Fingerprint) []SimilarFile {
+	return ScoreFingerprintsWithThresholds(detector, fingerprint, otherFingerprints, nil)
+}
+
+// ScoreFingerprintsWithThresholds is like ScoreFingerprints, but each other file has its own threshold by file ID.
+// Files without a threshold are held to the threshold of the detector.
+func ScoreFingerprintsWithThresholds(
+	detector Detector,
+	fingerprint Fingerprint,
+	otherFingerprints map[string]Fingerprint,
+	thresholds map[string]float64,
+) []SimilarFile {
+	var similarFiles []SimilarFile
+
+	for fileID, other := range otherFingerprints {
+		threshold, ok := thresholds[fileID]
+		if !ok {
+			threshold = detector.Threshold()
+		}
+		// If similarity is above threshold, consider it plagiarism
+		if similarity := detector.Similarity(fingerprint, other); similarity >= threshold {
+			similarFiles = append(similarFiles, SimilarFile{FileID: fileID, Similarity: similarity})
+		}
+	}
+
+	slices.SortFunc(similarFiles, func(a, b SimilarFile) int {
+		if a.Similarity != b.Similarity {
+			return cmp.Compare(b.Similarity, a.Similarity)
+		}
+		return strings.Compare(a.FileID, b.FileID)
+	})
+	return similarFiles
+}
+
+// Detectors holds the available detectors by name and the one used when a request does not choose any
+type Detectors struct {
+	detectors   []Detector
+	defaultName string
+}
+
+// NewDetectors creates a set of detectors with the named default, which must be one of them
+func NewDetectors(defaultName string, detectors ...Detector) (*Detectors, error) {
+	d := &Detectors{detectors: detectors, defaultName: defaultName}
+	if _, err := d.Get(defaultName); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Get returns the detector with the given name, or the default detector if the name is empty
+func (d *Detectors) Get(name string) (Detector, error) {
+	if name == "" {
+		name = d.defaultName
+	}
+	for _, detector := range d.detectors {
+		if detector.Name() == name {
+			return detector, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDetector, name)
+}
+
+// All returns all detectors
+func (d *Detectors) All() []Detector {
+	return d.detectors
+}
@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectors_Get(t *testing.T) {
+	detectors, err := NewDetectors("winnowing", NewPlagiarismChecker(), NewWinnowingDetector())
+	if err != nil {
+		t.Fatalf("NewDetectors() error = %v", err)
+	}
+
+	for name, want := range map[string]string{"": "winnowing", "jaccard": "jaccard", "winnowing": "winnowing"} {
+		detector, err := detectors.Get(name)
+		if err != nil || detector.Name() != want {
+			t.Errorf("Get(%q) = %v, %v, want the %s detector", name, detector, err, want)
+		}
+	}
+
+	if _, err := detectors.Get("moss"); !errors.Is(err, ErrUnknownDetector) {
+		t.Errorf("Get(%q) error = %v, want ErrUnknownDetector", "moss", err)
+	}
+	if _, err := NewDetectors("moss", NewPlagiarismChecker()); !errors.Is(err, ErrUnknownDetector) {
+		t.Errorf("NewDetectors() with an unknown default error = %v, want ErrUnknownDetector", err)
+	}
+}
+
+// readCorpus reads the sample texts of the repository by name, such as "01-02"
+func readCorpus(t *testing.T) map[string]string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("..", "..", "..", "..", "texts", "*.txt"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("failed to find the texts corpus: %v", err)
+	}
+
+	texts := make(map[string]string, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		texts[strings.TrimSuffix(filepath.Base(path), ".txt")] = string(content)
+	}
+	return texts
+}
+
+// Compare the detectors on the texts corpus. The first two digits of a text name its topic:
+// texts of a topic include edited copies and paraphrases of each other, texts of different topics are unrelated.
+// The texts are in Russian, so words are stemmed.
+func TestDetectors_Corpus(t *testing.T) {
+	texts := readCorpus(t)
+	textAnalyzer := NewTextAnalyzer()
+	textAnalyzer.Stem = true
+	jaccard, winnowing := NewPlagiarismChecker(), NewWinnowingDetector()
+	jaccard.TextAnalyzer, winnowing.TextAnalyzer = textAnalyzer, textAnalyzer
+
+	similarity := func(detector Detector, name1, name2 string) float64 {
+		return detector.Similarity(detector.Fingerprint(texts[name1]), detector.Fingerprint(texts[name2]))
+	}
+
+	t.Run("Copies are detected by both", func(t *testing.T) {
+		copies := [][2]string{
+			{"03-01", "03-02"}, // Punctuation and case changes
+			{"01-01", "01-02"}, // Lightly edited
+			{"02-01", "02-02"}, // Lightly edited
+			{"02-01", "02-04"}, // Copied with an added paragraph
+		}
+		for _, pair := range copies {
+			for _, detector := range []Detector{jaccard, winnowing} {
+				if got := similarity(detector, pair[0], pair[1]); got < detector.Threshold() {
+					t.Errorf("%s similarity of %s and %s = %.2f, want at least %.2f",
+						detector.Name(), pair[0], pair[1], got, detector.Threshold())
+				}
+			}
+		}
+	})
+
+	t.Run("Unrelated texts are not detected", func(t *testing.T) {
+		for name1 := range texts {
+			for name2 := range texts {
+				if name1[:2] == name2[:2] {
+					continue
+				}
+				for _, detector := range []Detector{jaccard, winnowing} {
+					if got := similarity(detector, name1, name2); got >= detector.Threshold() {
+						t.Errorf("%s similarity of %s and %s = %.2f, want below %.2f",
+							detector.Name(), name1, name2, got, detector.Threshold())
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Winnowing is less sensitive to insertions and edits", func(t *testing.T) {
+		for _, pair := range [][2]string{{"01-01", "01-02"}, {"02-01", "02-02"}, {"02-01", "02-04"}} {
+			jaccardSimilarity := similarity(jaccard, pair[0], pair[1])
+			winnowingSimilarity := similarity(winnowing, pair[0], pair[1])
+			t.Logf("%s vs %s: jaccard %.2f, winnowing %.2f", pair[0], pair[1], jaccardSimilarity, winnowingSimilarity)
+			if winnowingSimilarity <= jaccardSimilarity {
+				t.Errorf("winnowing similarity of %s and %s = %.2f, want above the jaccard similarity %.2f",
+					pair[0], pair[1], winnowingSimilarity, jaccardSimilarity)
+			}
+		}
+		if got := similarity(winnowing, "02-01", "02-04"); got != 1 {
+			t.Errorf("winnowing similarity of 02-01 and its extension 02-04 = %.2f, want 1", got)
+		}
+	})
+
+	t.Run("Winnowing is less sensitive to the order of paragraphs", func(t *testing.T) {
+		paragraphs := strings.Split(texts["04-02"], "\n\n")
+		for i, j := 0, len(paragraphs)-1; i < j; i, j = i+1, j-1 {
+			paragraphs[i], paragraphs[j] = paragraphs[j], paragraphs[i]
+		}
+		texts["04-02-reversed"] = strings.Join(paragraphs, "\n\n")
+
+		jaccardSimilarity := similarity(jaccard, "04-02", "04-02-reversed")
+		winnowingSimilarity := similarity(winnowing, "04-02", "04-02-reversed")
+		t.Logf("04-02 vs reversed: jaccard %.2f, winnowing %.2f", jaccardSimilarity, winnowingSimilarity)
+		if winnowingSimilarity < 0.7 || winnowingSimilarity <= jaccardSimilarity {
+			t.Errorf("winnowing similarity of reordered paragraphs = %.2f, want at least 0.7 and above jaccard %.2f",
+				winnowingSimilarity, jaccardSimilarity)
+		}
+	})
+}
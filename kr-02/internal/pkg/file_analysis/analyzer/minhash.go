@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// MinHash computes fixed-size signatures of n-gram sets whose agreement estimates their Jaccard similarity.
+// Signatures are split into bands for locality-sensitive hashing (LSH): two sets share at least one band key
+// with probability 1 - (1 - J^RowsPerBand)^(NumHashes/RowsPerBand), so only sets likely to be similar
+// have to be compared exactly.
+type MinHash struct {
+	// Number of hash functions, and values in a signature
+	NumHashes int
+
+	// Number of signature values hashed into each band key
+	// Fewer rows find less similar sets at the cost of more candidates
+	RowsPerBand int
+
+	seeds []uint64
+}
+
+// NewMinHash creates a new MinHash instance.
+// Signatures are only comparable between instances with the same number of hashes.
+func NewMinHash(numHashes, rowsPerBand int) *MinHash {
+	seeds := make([]uint64, numHashes)
+	state := uint64(0x5eed)
+	for i := range seeds {
+		state = splitMix64(state)
+		seeds[i] = state
+	}
+	return &MinHash{
+		NumHashes:   numHashes,
+		RowsPerBand: rowsPerBand,
+		seeds:       seeds,
+	}
+}
+
+// Signature computes the MinHash signature of a set of hashed n-grams.
+// An empty set has no signature and returns nil.
+func (m *MinHash) Signature(ngrams []uint64) []uint64 {
+	if len(ngrams) == 0 {
+		return nil
+	}
+
+	signature := make([]uint64, m.NumHashes)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for _, value := range ngrams {
+		for i, seed := range m.seeds {
+			if hashed := splitMix64(value ^ seed); hashed < signature[i] {
+				signature[i] = hashed
+			}
+		}
+	}
+	return signature
+}
+
+// BandKeys hashes each band of a signature into a key. Keys include the band number,
+// so two signatures share a key only if they agree on all rows of the same band.
+func (m *MinHash) BandKeys(signature []uint64) []uint64 {
+	var keys []uint64
+	buf := make([]byte, 8)
+	for band := 0; (band+1)*m.RowsPerBand <= len(signature); band++ {
+		h := fnv.New64a()
+		binary.BigEndian.PutUint64(buf, uint64(band))
+		h.Write(buf)
+		for _, value := range signature[band*m.RowsPerBand : (band+1)*m.RowsPerBand] {
+			binary.BigEndian.PutUint64(buf, value)
+			h.Write(buf)
+		}
+		keys = append(keys, h.Sum64())
+	}
+	return keys
+}
+
+// EstimateSimilarity estimates the Jaccard similarity of two sets from their signatures
+func EstimateSimilarity(signature1, signature2 []uint64) float64 {
+	if len(signature1) == 0 || len(signature1) != len(signature2) {
+		return 0
+	}
+
+	matching := 0
+	for i := range signature1 {
+		if signature1[i] == signature2[i] {
+			matching++
+		}
+	}
+	return float64(matching) / float64(len(signature1))
+}
+
+// splitMix64 scrambles a 64-bit value into a well-distributed hash
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// ngramSet builds a set of hashed n-grams numbered from first to last, inclusive
+func ngramSet(first, last int) []uint64 {
+	var ngrams []uint64
+	for i := first; i <= last; i++ {
+		ngrams = append(ngrams, hashNGram(fmt.Sprintf("ngram %d", i)))
+	}
+	return ngrams
+}
+
+// sharesKey reports whether two lists of band keys have a key in common
+func sharesKey(keys1, keys2 []uint64) bool {
+	set := make(map[uint64]bool)
+	for _, key := range keys1 {
+		set[key] = true
+	}
+	for _, key := range keys2 {
+		if set[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMinHash_EstimateSimilarity(t *testing.T) {
+	minHash := NewMinHash(128, 2)
+
+	tests := []struct {
+		name       string
+		set1, set2 []uint64
+		similarity float64
+	}{
+		{"Identical", ngramSet(1, 100), ngramSet(1, 100), 1},
+		{"Half overlapping", ngramSet(1, 150), ngramSet(51, 200), 0.5},
+		{"Slightly overlapping", ngramSet(1, 100), ngramSet(91, 190), 10.0 / 190},
+		{"Disjoint", ngramSet(1, 100), ngramSet(101, 200), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateSimilarity(minHash.Signature(tt.set1), minHash.Signature(tt.set2))
+			if math.Abs(estimate-tt.similarity) > 0.15 {
+				t.Errorf("EstimateSimilarity() = %.2f, want about %.2f", estimate, tt.similarity)
+			}
+		})
+	}
+}
+
+func TestMinHash_BandKeys(t *testing.T) {
+	minHash := NewMinHash(128, 2)
+	base := minHash.BandKeys(minHash.Signature(ngramSet(1, 100)))
+
+	if len(base) != 64 {
+		t.Fatalf("BandKeys() returned %d keys, want 64", len(base))
+	}
+
+	// Sets with 30% similarity are candidates of each other
+	similar := minHash.BandKeys(minHash.Signature(ngramSet(55, 154)))
+	if !sharesKey(base, similar) {
+		t.Error("BandKeys() of similar sets have no key in common")
+	}
+
+	// Disjoint sets are not
+	disjoint := minHash.BandKeys(minHash.Signature(ngramSet(1001, 1100)))
+	if sharesKey(base, disjoint) {
+		t.Error("BandKeys() of disjoint sets have a key in common")
+	}
+
+	// The same values in different bands do not collide
+	repeated := make([]uint64, 4)
+	keys := minHash.BandKeys(repeated)
+	if keys[0] == keys[1] {
+		t.Error("BandKeys() returned the same key for different bands")
+	}
+
+	if signature := minHash.Signature(nil); signature != nil {
+		t.Errorf("Signature() of an empty set = %v, want nil", signature)
+	}
+}
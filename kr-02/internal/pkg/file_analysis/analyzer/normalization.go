@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Language is the language of a text, as an ISO 639-1 code
+type Language string
+
+const (
+	// LanguageUnknown is the language of texts without letters
+	LanguageUnknown Language = ""
+	// LanguageRussian texts are mostly written in Cyrillic letters
+	LanguageRussian Language = "ru"
+	// LanguageEnglish texts are mostly written in Latin letters
+	LanguageEnglish Language = "en"
+)
+
+// token is a normalized word with its character offsets in the original text
+type token struct {
+	word       string
+	start, end int
+	stopWord   bool
+}
+
+// normalizationFormat is incremented whenever the way significant words are normalized changes
+const normalizationFormat = 1
+
+// Lowercase Latin letters and the Cyrillic letters that look the same
+var (
+	latinToCyrillic = map[rune]rune{
+		'a': 'а', 'b': 'в', 'c': 'с', 'e': 'е', 'h': 'н', 'k': 'к',
+		'm': 'м', 'o': 'о', 'p': 'р', 't': 'т', 'x': 'х', 'y': 'у',
+	}
+	cyrillicToLatin = invertRunes(latinToCyrillic)
+)
+
+// invertRunes swaps the keys and values of a rune mapping
+func invertRunes(mapping map[rune]rune) map[rune]rune {
+	inverted := make(map[rune]rune, len(mapping))
+	for from, to := range mapping {
+		inverted[to] = from
+	}
+	return inverted
+}
+
+// DetectLanguage detects the language of a text by the script most of its letters are written in
+func (a *TextAnalyzer) DetectLanguage(content string) Language {
+	var cyrillic, latin int
+	for _, r := range content {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic > latin:
+		return LanguageRussian
+	case latin > 0:
+		return LanguageEnglish
+	}
+	return LanguageUnknown
+}
+
+// significantTokens splits a text into its normalized significant words, keeping their character offsets
+func (a *TextAnalyzer) significantTokens(text string) []token {
+	var significant []token
+	for _, t := range a.tokens(text) {
+		if !t.stopWord {
+			significant = append(significant, t)
+		}
+	}
+	return significant
+}
+
+// tokens splits a text into its normalized words, keeping their character offsets.
+// Words are separated by whitespace and punctuation, then normalized:
+// 1. Compatibility characters are replaced by their canonical forms (NFKC), and invisible formatting characters are removed
+// 2. Letters are lowercased and ё is folded to е
+// 3. Latin and Cyrillic letters that look the same are folded to the script of the rest of the word
+// 4. Stop words are marked, and the rest are stemmed if stemming is enabled
+func (a *TextAnalyzer) tokens(text string) []token {
+	language := a.DetectLanguage(text)
+
+	var tokens []token
+	var word strings.Builder
+	start := 0
+
+	flush := func(end int) {
+		if word.Len() == 0 {
+			return
+		}
+		// Normalization may turn a character into several words, such as "½" into "1⁄2"
+		for _, w := range strings.FieldsFunc(normalizeWord(word.String(), language), isWordSeparator) {
+			stopWord := a.StopWords[w]
+			if a.Stem && !stopWord {
+				w = stem(w)
+			}
+			tokens = append(tokens, token{word: w, start: start, end: end, stopWord: stopWord})
+		}
+		word.Reset()
+	}
+
+	offset := 0
+	for _, r := range text {
+		if isWordSeparator(r) {
+			flush(offset)
+		} else {
+			if word.Len() == 0 {
+				start = offset
+			}
+			word.WriteRune(r)
+		}
+		offset++
+	}
+	flush(offset)
+
+	return tokens
+}
+
+// version identifies the normalization settings in fingerprint versions
+func (a *TextAnalyzer) version() string {
+	stemming := 0
+	if a.Stem {
+		stemming = 1
+	}
+	return fmt.Sprintf("t%d-m%d-s%s", normalizationFormat, stemming, stopWordsHash(a))
+}
+
+// isWordSeparator reports whether r separates words
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// normalizeWord applies the NFKC normalization, lowercasing, ё folding and homoglyph folding to a word
+func normalizeWord(word string, language Language) string {
+	word = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Cf, r):
+			// Zero-width spaces, soft hyphens and other invisible characters
+			return -1
+		case r == 'ё':
+			return 'е'
+		}
+		return r
+	}, strings.ToLower(norm.NFKC.String(word)))
+
+	return foldHomoglyphs(word, language)
+}
+
+// foldHomoglyphs replaces Latin letters in Cyrillic words with the Cyrillic letters that look the same, and vice versa.
+// A word is written in the script most of its other letters are written in; a word consisting only of letters
+// that exist in both scripts, such as "сор", is written in the script of the language of the text.
+func foldHomoglyphs(word string, language Language) string {
+	var cyrillic, latin, lookalikes int
+	for _, r := range word {
+		switch {
+		case latinToCyrillic[r] != 0 || cyrillicToLatin[r] != 0:
+			lookalikes++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if lookalikes == 0 {
+		return word
+	}
+
+	toCyrillic := cyrillic > latin || (cyrillic == latin && language == LanguageRussian)
+	return strings.Map(func(r rune) rune {
+		if to := latinToCyrillic[r]; toCyrillic && to != 0 {
+			return to
+		}
+		if to := cyrillicToLatin[r]; !toCyrillic && to != 0 {
+			return to
+		}
+		return r
+	}, word)
+}
+
+// stem reduces a normalized word to its stem with the Snowball stemmer of its script
+func stem(word string) string {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return stemRussian(word)
+		}
+	}
+	return stemEnglish(word)
+}
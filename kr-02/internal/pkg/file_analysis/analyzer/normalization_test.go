@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestTextAnalyzer_DetectLanguage(t *testing.T) {
+	analyzer := NewTextAnalyzer()
+
+	tests := []struct {
+		name    string
+		content string
+		want    Language
+	}{
+		{"Russian", "Микросервисная архитектура", LanguageRussian},
+		{"English", "Microservice architecture", LanguageEnglish},
+		{"Mostly Russian", "Сервисы взаимодействуют через API", LanguageRussian},
+		{"Mostly English", "Services talk over HTTP, а не gRPC", LanguageEnglish},
+		{"No letters", "42 - 1", LanguageUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := analyzer.DetectLanguage(tt.content); got != tt.want {
+				t.Errorf("DetectLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextAnalyzer_GetSignificantWords_Normalization(t *testing.T) {
+	analyzer := NewTextAnalyzer()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Russian stop words",
+			content: "Это был сервис, и он работал",
+			want:    []string{"сервис", "работал"},
+		},
+		{
+			name:    "Yo is folded to ye",
+			content: "Ещё всё учтённое",
+			want:    []string{"учтенное"},
+		},
+		{
+			name:    "Latin letters in a Russian word",
+			content: "apхитектуpa сервиса", // Latin a and p
+			want:    []string{"архитектура", "сервиса"},
+		},
+		{
+			name:    "Cyrillic letters in an English word",
+			content: "the аrchitecture of servicеs", // Cyrillic а and е
+			want:    []string{"architecture", "services"},
+		},
+		{
+			name:    "Lookalike-only word follows the text language",
+			content: "Кот сорок cop", // "сор" in Latin letters
+			want:    []string{"кот", "сорок", "сор"},
+		},
+		{
+			name:    "Compatibility characters",
+			content: "ｆｕｌｌｗｉｄｔｈ ﬁle",
+			want:    []string{"fullwidth", "file"},
+		},
+		{
+			name:    "Invisible characters",
+			content: "plagia​rism re­port",
+			want:    []string{"plagiarism", "report"},
+		},
+		{
+			name:    "Empty",
+			content: "",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := analyzer.GetSignificantWords(tt.content); !equalStringSlices(got, tt.want) {
+				t.Errorf("GetSignificantWords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextAnalyzer_GetSignificantWords_Stem(t *testing.T) {
+	analyzer := NewTextAnalyzer()
+	content := "Сервисы взаимодействуют, services interacting"
+
+	want := []string{"сервисы", "взаимодействуют", "services", "interacting"}
+	if got := analyzer.GetSignificantWords(content); !equalStringSlices(got, want) {
+		t.Errorf("GetSignificantWords() = %q, want %q", got, want)
+	}
+
+	analyzer.Stem = true
+	want = []string{"сервис", "взаимодейств", "servic", "interact"}
+	if got := analyzer.GetSignificantWords(content); !equalStringSlices(got, want) {
+		t.Errorf("GetSignificantWords() with stemming = %q, want %q", got, want)
+	}
+}
+
+// Test that homoglyph substitutions do not hide plagiarism
+func TestPlagiarismChecker_Homoglyphs(t *testing.T) {
+	checker := NewPlagiarismChecker()
+	original := "Микросервисная архитектура предполагает построение приложения в виде набора небольших сервисов"
+	// Every Cyrillic а, е, о, р and с replaced by the Latin letter that looks the same
+	disguised := "Mикpoсepвиcнaя apхитeктуpa пpeдпoлaгaeт пocтpoeниe пpилoжeния в видe нaбopa нeбoльших cepвиcoв"
+
+	if got := checker.Similarity(checker.Fingerprint(original), checker.Fingerprint(disguised)); got != 1 {
+		t.Errorf("Similarity() of a text and its homoglyph copy = %.2f, want 1", got)
+	}
+}
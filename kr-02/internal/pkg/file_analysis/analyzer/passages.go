@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"slices"
+	"strings"
+)
+
+// Passage is a run of significant words two texts have in common.
+// Offsets count characters (runes) of the original texts; ends are exclusive.
+type Passage struct {
+	SourceStart int // Offset of the passage in the checked text
+	SourceEnd   int
+	MatchStart  int // Offset of the passage in the other text
+	MatchEnd    int
+	Text        string // The passage as written in the checked text
+	WordCount   int    // Number of significant words in the passage
+}
+
+// MatchingPassages finds the longest passages of at least NGramSize significant words
+// that occur in both texts, and returns at most limit of them, longest first.
+// Words are compared the way CheckPlagiarism compares them, ignoring case, punctuation, stop words and the differences normalization removes.
+// N-grams of the reference texts, such as the statement of the assignment, are not part of any passage.
+func (c *PlagiarismChecker) MatchingPassages(content, otherContent string, references []string, limit int) []Passage {
+	n := c.NGramSize
+	source := c.TextAnalyzer.significantTokens(content)
+	other := c.TextAnalyzer.significantTokens(otherContent)
+	if n <= 0 || len(source) < n || len(other) < n {
+		return nil
+	}
+
+	excluded := make(map[string]bool)
+	for _, reference := range references {
+		tokens := c.TextAnalyzer.significantTokens(reference)
+		for k := 0; k+n <= len(tokens); k++ {
+			excluded[ngramKey(tokens[k:k+n])] = true
+		}
+	}
+	// Index where every n-gram not in the references starts in the other text
+	starts := make(map[string][]int)
+	for j := 0; j+n <= len(other); j++ {
+		key := ngramKey(other[j : j+n])
+		if !excluded[key] {
+			starts[key] = append(starts[key], j)
+		}
+	}
+
+	runes := []rune(content)
+
+	// Extend every shared n-gram as far as both texts agree outside of the references,
+	// and continue after the longest extension
+	var passages []Passage
+	for i := 0; i+n <= len(source); {
+		bestLength, bestStart := 0, 0
+		for _, j := range starts[ngramKey(source[i:i+n])] {
+			length := n
+			for i+length < len(source) && j+length < len(other) && source[i+length].word == other[j+length].word &&
+				!excluded[ngramKey(source[i+length+1-n:i+length+1])] {
+				length++
+			}
+			if length > bestLength {
+				bestLength, bestStart = length, j
+			}
+		}
+
+		if bestLength == 0 {
+			i++
+			continue
+		}
+
+		first, last := source[i], source[i+bestLength-1]
+		passages = append(passages, Passage{
+			SourceStart: first.start,
+			SourceEnd:   last.end,
+			MatchStart:  other[bestStart].start,
+			MatchEnd:    other[bestStart+bestLength-1].end,
+			Text:        string(runes[first.start:last.end]),
+			WordCount:   bestLength,
+		})
+		i += bestLength
+	}
+
+	slices.SortStableFunc(passages, func(a, b Passage) int {
+		return b.WordCount - a.WordCount
+	})
+	if limit >= 0 && len(passages) > limit {
+		passages = passages[:limit]
+	}
+	return passages
+}
+
+// ngramKey joins the words of tokens into an n-gram
+func ngramKey(tokens []token) string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.word
+	}
+	return strings.Join(words, " ")
+}
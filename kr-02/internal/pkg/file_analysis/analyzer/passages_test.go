@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestPlagiarismChecker_MatchingPassages(t *testing.T) {
+	checker := NewPlagiarismChecker()
+
+	content := "Intro line. The quick brown fox jumps over the lazy dog! Something else entirely here."
+	other := "Другой текст: the QUICK brown fox, jumps over the lazy dog. Конец."
+
+	passages := checker.MatchingPassages(content, other, nil, 5)
+	if len(passages) != 1 {
+		t.Fatalf("MatchingPassages() returned %d passages, want 1: %+v", len(passages), passages)
+	}
+
+	passage := passages[0]
+	if passage.Text != "quick brown fox jumps over the lazy dog" {
+		t.Errorf("MatchingPassages() text = %q", passage.Text)
+	}
+	if passage.WordCount != 7 {
+		t.Errorf("MatchingPassages() word count = %d, want 7", passage.WordCount)
+	}
+
+	// Offsets count characters, not bytes
+	otherRunes := []rune(other)
+	if got := string(otherRunes[passage.MatchStart:passage.MatchEnd]); got != "QUICK brown fox, jumps over the lazy dog" {
+		t.Errorf("MatchingPassages() match = %q", got)
+	}
+	if got := string([]rune(content)[passage.SourceStart:passage.SourceEnd]); got != passage.Text {
+		t.Errorf("MatchingPassages() source = %q, want %q", got, passage.Text)
+	}
+}
+
+func TestPlagiarismChecker_MatchingPassages_Limit(t *testing.T) {
+	checker := NewPlagiarismChecker()
+
+	content := "alpha beta gamma delta. one two three. red green blue yellow purple"
+	other := "red green blue yellow purple. zzz. alpha beta gamma delta. qqq. one two three"
+
+	passages := checker.MatchingPassages(content, other, nil, 2)
+	if len(passages) != 2 {
+		t.Fatalf("MatchingPassages() returned %d passages, want 2", len(passages))
+	}
+	if passages[0].WordCount != 5 || passages[1].WordCount != 4 {
+		t.Errorf("MatchingPassages() word counts = %d, %d, want longest first (5, 4)", passages[0].WordCount, passages[1].WordCount)
+	}
+
+	if passages := checker.MatchingPassages(content, "nothing in common at all", nil, 5); len(passages) != 0 {
+		t.Errorf("MatchingPassages() of unrelated texts = %+v, want none", passages)
+	}
+}
+
+func TestPlagiarismChecker_MatchingPassages_References(t *testing.T) {
+	checker := NewPlagiarismChecker()
+
+	statement := "Describe the quick brown fox."
+	content := "Describe the quick brown fox. It jumps over the lazy dog near the river."
+	other := "Describe the quick brown fox! A cat jumps over the lazy dog near the river too."
+
+	if passages := checker.MatchingPassages(content, other, nil, 5); len(passages) != 2 {
+		t.Errorf("MatchingPassages() with the statement = %+v, want the statement and the passage after it", passages)
+	}
+	passages := checker.MatchingPassages(content, other, []string{statement}, 5)
+	if len(passages) != 1 || passages[0].Text != "jumps over the lazy dog near the river" {
+		t.Errorf("MatchingPassages() without the statement = %+v, want only the passage after it", passages)
+	}
+
+	if passages := checker.MatchingPassages(statement, statement, []string{statement}, 5); len(passages) != 0 {
+		t.Errorf("MatchingPassages() of the statement without the statement = %+v, want none", passages)
+	}
+}
+
+func TestPlagiarismChecker_Compare(t *testing.T) {
+	checker := NewPlagiarismChecker()
+
+	comparison := checker.Compare(
+		"The quick brown fox jumps over the lazy dog near the river.",
+		"A quick brown fox jumps over the lazy dog near a mill.",
+	)
+
+	// Both have 9 significant words; only the n-grams ending in "river" and "mill" differ
+	if comparison.NGrams != 7 || comparison.OtherNGrams != 7 || comparison.SharedNGrams != 6 {
+		t.Errorf("Compare() n-grams = %d, %d, shared %d, want 7, 7, shared 6",
+			comparison.NGrams, comparison.OtherNGrams, comparison.SharedNGrams)
+	}
+	if want := 6.0 / 8; comparison.Similarity != want {
+		t.Errorf("Compare() similarity = %v, want %v", comparison.Similarity, want)
+	}
+	if len(comparison.Passages) != 1 || comparison.Passages[0].WordCount != 8 {
+		t.Errorf("Compare() passages = %+v, want one of 8 words", comparison.Passages)
+	}
+}
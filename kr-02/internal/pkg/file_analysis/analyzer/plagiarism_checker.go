@@ -4,11 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"slices"
 	"strings"
 )
 
 // PlagiarismChecker provides methods for checking plagiarism between text documents
-// It uses a combination of techniques including:
+// and is the default Detector. It uses a combination of techniques including:
 // 1. Exact matching via hash comparison for efficiency
 // 2. N-gram analysis to detect similar text patterns
 // 3. Jaccard similarity coefficient to measure text similarity
@@ -25,8 +28,12 @@ type PlagiarismChecker struct {
 	// Default is 3
 	NGramSize int
 
+	// MinHash computes the signatures used to find candidate files before the exact comparison
+	// Default is 128 hashes in bands of 2, which finds files with 30% similarity with a probability above 99%
+	MinHash *MinHash
+
 	// TextAnalyzer instance for word extraction and text processing
-	textAnalyzer *TextAnalyzer
+	TextAnalyzer *TextAnalyzer
 }
 
 // NewPlagiarismChecker creates a new PlagiarismChecker instance
@@ -43,7 +50,9 @@ func NewPlagiarismChecker() *PlagiarismChecker {
 		// - Smaller values (2-3) catch more potential matches but may increase false positives
 		NGramSize:           3,
 
-		textAnalyzer:        NewTextAnalyzer(),
+		MinHash: NewMinHash(128, 2),
+
+		TextAnalyzer:        NewTextAnalyzer(),
 	}
 }
 
@@ -65,75 +74,114 @@ func NewPlagiarismChecker() *PlagiarismChecker {
 //   - bool: True if plagiarism is detected (similarity above threshold)
 //   - []string: List of file IDs that are similar to the provided content
 func (c *PlagiarismChecker) CheckPlagiarism(ctx context.Context, content string, otherContents map[string]string) (bool, []string) {
-	var similarFileIDs []string
+	otherFingerprints := make(map[string]Fingerprint, len(otherContents))
+	for fileID, otherContent := range otherContents {
+		otherFingerprints[fileID] = c.Fingerprint(otherContent)
+	}
+	return c.CompareFingerprints(c.Fingerprint(content), otherFingerprints)
+}
 
-	// Preprocess the current content
+// Fingerprint is the preprocessed form of a text that comparisons work on.
+// It is computed once per file and stored, so texts do not have to be fetched and preprocessed again.
+type Fingerprint struct {
+	// SHA-256 of the preprocessed text, for exact matches
+	ContentHash string
+
+	// Sorted distinct hashes the detector compares, such as those of the n-grams of the preprocessed text
+	NGrams []uint64
+}
+
+// fingerprintFormat is incremented whenever the way fingerprints are computed changes
+const fingerprintFormat = 1
+
+// Fingerprint preprocesses the content and computes its fingerprint
+func (c *PlagiarismChecker) Fingerprint(content string) Fingerprint {
 	processedContent := c.preprocessText(content)
 
-	// Generate n-grams for the current content
-	currentNGrams := c.generateNGrams(processedContent, c.NGramSize)
+	ngrams := c.generateNGrams(processedContent, c.NGramSize)
+	hashes := make([]uint64, 0, len(ngrams))
+	for ngram := range ngrams {
+		hashes = append(hashes, hashNGram(ngram))
+	}
+	slices.Sort(hashes)
 
-	// Compare with other contents
-	for fileID, otherContent := range otherContents {
-		// Preprocess the other content
-		processedOtherContent := c.preprocessText(otherContent)
-
-		// First, do a quick hash check for exact matches
-		if c.calculateHash(processedContent) == c.calculateHash(processedOtherContent) {
-			similarFileIDs = append(similarFileIDs, fileID)
-			continue
-		}
-
-		// If not an exact match, calculate Jaccard similarity
-		otherNGrams := c.generateNGrams(processedOtherContent, c.NGramSize)
-		similarity := c.calculateJaccardSimilarity(currentNGrams, otherNGrams)
-
-		// Uncomment for debugging
-		/*
-		if ctx.Value("debug") != nil {
-			println("Comparing with", fileID)
-			println("Content 1:", processedContent)
-			println("Content 2:", processedOtherContent)
-			println("Similarity:", similarity)
-			println("Threshold:", c.SimilarityThreshold)
-
-			// Print n-grams for debugging
-			println("N-grams 1:")
-			for ngram := range currentNGrams {
-				println("  -", ngram)
-			}
-			println("N-grams 2:")
-			for ngram := range otherNGrams {
-				println("  -", ngram)
-			}
-		}
-		*/
-
-		// If similarity is above threshold, consider it plagiarism
-		if similarity >= c.SimilarityThreshold {
-			similarFileIDs = append(similarFileIDs, fileID)
-		}
+	return Fingerprint{
+		ContentHash: calculateHash(processedContent),
+		NGrams:      slices.Compact(hashes),
 	}
+}
+
+// Name identifies the Jaccard detector
+func (c *PlagiarismChecker) Name() string {
+	return "jaccard"
+}
+
+// Version identifies the settings fingerprints are computed with.
+// Stored fingerprints with another version are stale and must be computed again.
+func (c *PlagiarismChecker) Version() string {
+	return fmt.Sprintf("v%d-n%d-%s", fingerprintFormat, c.NGramSize, c.TextAnalyzer.version())
+}
 
+// Threshold returns the similarity from which a file is considered plagiarized
+func (c *PlagiarismChecker) Threshold() float64 {
+	return c.SimilarityThreshold
+}
+
+// CompareFingerprints checks if the fingerprint matches any of the other fingerprints
+// the same way CheckPlagiarism compares texts:
+// 1. Exact matches of the preprocessed texts are similar
+// 2. Otherwise, the Jaccard similarity of the n-gram sets must reach the threshold
+//
+// Returns:
+//   - bool: True if plagiarism is detected (similarity above threshold)
+//   - []string: List of file IDs that are similar to the fingerprinted content
+func (c *PlagiarismChecker) CompareFingerprints(fingerprint Fingerprint, otherFingerprints map[string]Fingerprint) (bool, []string) {
+	var similarFileIDs []string
+	for _, similarFile := range ScoreFingerprints(c, fingerprint, otherFingerprints) {
+		similarFileIDs = append(similarFileIDs, similarFile.FileID)
+	}
 	return len(similarFileIDs) > 0, similarFileIDs
 }
 
+// Similarity computes the similarity of two fingerprints: 1 for exact matches of the preprocessed texts,
+// the Jaccard similarity of their n-gram sets otherwise
+func (c *PlagiarismChecker) Similarity(fingerprint1, fingerprint2 Fingerprint) float64 {
+	// First, do a quick hash check for exact matches
+	if exactMatch(fingerprint1, fingerprint2) {
+		return 1
+	}
+
+	// If not an exact match, calculate Jaccard similarity
+	return c.calculateJaccardSimilarity(fingerprint1.NGrams, fingerprint2.NGrams)
+}
+
+// Signature computes the MinHash signature of the n-grams of a fingerprint.
+// A fingerprint without n-grams has no signature and returns nil.
+func (c *PlagiarismChecker) Signature(fingerprint Fingerprint) []uint64 {
+	return c.MinHash.Signature(fingerprint.NGrams)
+}
+
+// BandKeys computes the LSH band keys of a signature, used to look up candidate files
+func (c *PlagiarismChecker) BandKeys(signature []uint64) []uint64 {
+	return c.MinHash.BandKeys(signature)
+}
+
 // preprocessText prepares text for comparison by normalizing it
 func (c *PlagiarismChecker) preprocessText(text string) string {
 	// Get significant words (removes stop words and punctuation)
-	significantWords := c.textAnalyzer.GetSignificantWords(text)
+	significantWords := c.TextAnalyzer.GetSignificantWords(text)
 
 	// Join the significant words
 	processedText := strings.Join(significantWords, " ")
 
 	// Normalize whitespace
-	return c.textAnalyzer.RemoveExcessWhitespace(processedText)
+	return c.TextAnalyzer.RemoveExcessWhitespace(processedText)
 }
 
 // generateNGrams creates a map of n-grams from the text
 func (c *PlagiarismChecker) generateNGrams(text string, n int) map[string]int {
 	// Use the TextAnalyzer's GetNGrams method to get n-grams
-	ngrams := c.textAnalyzer.GetNGrams(text, n)
+	ngrams := c.TextAnalyzer.GetNGrams(text, n)
 
 	// Convert to a frequency map
 	ngramFreq := make(map[string]int)
@@ -144,30 +192,13 @@ func (c *PlagiarismChecker) generateNGrams(text string, n int) map[string]int {
 	return ngramFreq
 }
 
-// calculateJaccardSimilarity computes the Jaccard similarity coefficient between two sets of n-grams
-func (c *PlagiarismChecker) calculateJaccardSimilarity(ngrams1, ngrams2 map[string]int) float64 {
-	// Create sets from the n-grams
-	set1 := make(map[string]bool)
-	set2 := make(map[string]bool)
-
-	for ngram := range ngrams1 {
-		set1[ngram] = true
-	}
-
-	for ngram := range ngrams2 {
-		set2[ngram] = true
-	}
-
+// calculateJaccardSimilarity computes the Jaccard similarity coefficient between two sorted sets of n-gram hashes
+func (c *PlagiarismChecker) calculateJaccardSimilarity(ngrams1, ngrams2 []uint64) float64 {
 	// Calculate intersection size
-	intersection := 0
-	for ngram := range set1 {
-		if set2[ngram] {
-			intersection++
-		}
-	}
+	intersection := countShared(ngrams1, ngrams2)
 
 	// Calculate union size
-	union := len(set1) + len(set2) - intersection
+	union := len(ngrams1) + len(ngrams2) - intersection
 
 	// Avoid division by zero
 	if union == 0 {
@@ -177,8 +208,26 @@ func (c *PlagiarismChecker) calculateJaccardSimilarity(ngrams1, ngrams2 map[stri
 	return float64(intersection) / float64(union)
 }
 
+// hashNGram hashes an n-gram for fingerprints and signatures
+func hashNGram(ngram string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(ngram))
+	return h.Sum64()
+}
+
 // calculateHash calculates a SHA-256 hash of the content
-func (c *PlagiarismChecker) calculateHash(content string) string {
+func calculateHash(content string) string {
 	hash := sha256.Sum256([]byte(content))
 	return hex.EncodeToString(hash[:])
 }
+
+// stopWordsHash identifies the stop words of a text analyzer in fingerprint versions
+func stopWordsHash(textAnalyzer *TextAnalyzer) string {
+	stopWords := make([]string, 0, len(textAnalyzer.StopWords))
+	for word := range textAnalyzer.StopWords {
+		stopWords = append(stopWords, word)
+	}
+	slices.Sort(stopWords)
+
+	return calculateHash(strings.Join(stopWords, "\n"))[:12]
+}
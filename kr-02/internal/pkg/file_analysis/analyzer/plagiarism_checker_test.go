@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"context"
+	"slices"
 	"testing"
 )
 
@@ -141,6 +142,16 @@ func equalStringSlices(a, b []string) bool {
 	return true
 }
 
+// Helper function to hash a set of n-grams the way fingerprints do
+func hashNGramSet(ngrams map[string]int) []uint64 {
+	var hashes []uint64
+	for ngram := range ngrams {
+		hashes = append(hashes, hashNGram(ngram))
+	}
+	slices.Sort(hashes)
+	return hashes
+}
+
 // Test for the Jaccard similarity calculation
 func TestPlagiarismChecker_calculateJaccardSimilarity(t *testing.T) {
 	checker := NewPlagiarismChecker()
@@ -195,7 +206,7 @@ func TestPlagiarismChecker_calculateJaccardSimilarity(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := checker.calculateJaccardSimilarity(tt.ngrams1, tt.ngrams2)
+			got := checker.calculateJaccardSimilarity(hashNGramSet(tt.ngrams1), hashNGramSet(tt.ngrams2))
 			if got != tt.expected {
 				t.Errorf("calculateJaccardSimilarity() = %v, want %v", got, tt.expected)
 			}
@@ -208,7 +219,7 @@ func TestPlagiarismChecker_preprocessText(t *testing.T) {
 	// Create a custom checker with a modified TextAnalyzer that doesn't have "this" as a stop word
 	checker := NewPlagiarismChecker()
 	// Remove "this" from stop words for testing
-	delete(checker.textAnalyzer.StopWords, "this")
+	delete(checker.TextAnalyzer.StopWords, "this")
 
 	tests := []struct {
 		name     string
@@ -241,3 +252,56 @@ func TestPlagiarismChecker_preprocessText(t *testing.T) {
 		})
 	}
 }
+
+// Test that stored fingerprints compare like the texts they were computed from
+func TestPlagiarismChecker_CompareFingerprints(t *testing.T) {
+	checker := NewPlagiarismChecker()
+
+	content := "The quick brown fox jumps over the lazy dog near the river bank today."
+	others := map[string]string{
+		"copy":      "The quick brown fox jumps over the lazy dog near the river bank today!",
+		"edited":    "The quick brown fox jumps over the lazy dog near the old mill today.",
+		"unrelated": "Completely different words describe a rainy afternoon in the city library.",
+	}
+
+	otherFingerprints := make(map[string]Fingerprint)
+	for fileID, other := range others {
+		otherFingerprints[fileID] = checker.Fingerprint(other)
+	}
+
+	wantResult, wantIDs := checker.CheckPlagiarism(context.Background(), content, others)
+	gotResult, gotIDs := checker.CompareFingerprints(checker.Fingerprint(content), otherFingerprints)
+	if gotResult != wantResult || !equalStringSlices(gotIDs, wantIDs) {
+		t.Errorf("CompareFingerprints() = %v, %v, want %v, %v", gotResult, gotIDs, wantResult, wantIDs)
+	}
+	if !equalStringSlices(gotIDs, []string{"copy", "edited"}) {
+		t.Errorf("CompareFingerprints() IDs = %v, want [copy edited]", gotIDs)
+	}
+}
+
+// Test that fingerprints are computed again when the settings change
+func TestPlagiarismChecker_Version(t *testing.T) {
+	checker := NewPlagiarismChecker()
+	version := checker.Version()
+
+	if got := NewPlagiarismChecker().Version(); got != version {
+		t.Errorf("Version() = %q for the same settings, want %q", got, version)
+	}
+
+	checker.NGramSize = 4
+	if checker.Version() == version {
+		t.Error("Version() did not change with the n-gram size")
+	}
+
+	checker = NewPlagiarismChecker()
+	checker.TextAnalyzer.StopWords["however"] = true
+	if checker.Version() == version {
+		t.Error("Version() did not change with the stop words")
+	}
+
+	checker = NewPlagiarismChecker()
+	checker.TextAnalyzer.Stem = true
+	if checker.Version() == version {
+		t.Error("Version() did not change with stemming")
+	}
+}
@@ -0,0 +1,42 @@
+package analyzer
+
+import (
+	"slices"
+)
+
+// ReferenceNGrams computes the hashes of reference texts with the detector, such as the statement or the template
+// of an assignment, which submissions are allowed to share. The hashes are sorted and distinct.
+func ReferenceNGrams(detector Detector, references []string) []uint64 {
+	var hashes []uint64
+	for _, reference := range references {
+		hashes = append(hashes, detector.Fingerprint(reference).NGrams...)
+	}
+	slices.Sort(hashes)
+	return slices.Compact(hashes)
+}
+
+// WithoutReference returns the fingerprint without the hashes of reference texts computed by ReferenceNGrams,
+// so text shared with the references does not count towards the similarity of files.
+// Exact copies are still similar, but a fingerprint left without hashes is similar to nothing.
+func (f Fingerprint) WithoutReference(reference []uint64) Fingerprint {
+	if len(reference) == 0 {
+		return f
+	}
+
+	ngrams := make([]uint64, 0, len(f.NGrams))
+	for _, hash := range f.NGrams {
+		if _, found := slices.BinarySearch(reference, hash); !found {
+			ngrams = append(ngrams, hash)
+		}
+	}
+	if len(ngrams) == 0 {
+		return Fingerprint{}
+	}
+	return Fingerprint{ContentHash: f.ContentHash, NGrams: ngrams}
+}
+
+// exactMatch reports whether two fingerprints are of the same preprocessed text.
+// Fingerprints without a content hash, left without hashes by WithoutReference, match nothing.
+func exactMatch(fingerprint1, fingerprint2 Fingerprint) bool {
+	return fingerprint1.ContentHash != "" && fingerprint1.ContentHash == fingerprint2.ContentHash
+}
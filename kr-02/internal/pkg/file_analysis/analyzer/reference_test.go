@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+// Compare texts of the corpus that start with the same assignment statement, the text 03-03,
+// with and without the statement as a reference text
+func TestWithoutReference_Corpus(t *testing.T) {
+	texts := readCorpus(t)
+	textAnalyzer := NewTextAnalyzer()
+	textAnalyzer.Stem = true
+	jaccard, winnowing := NewPlagiarismChecker(), NewWinnowingDetector()
+	jaccard.TextAnalyzer, winnowing.TextAnalyzer = textAnalyzer, textAnalyzer
+
+	statement := texts["03-03"]
+	report := func(name string) string {
+		return statement + "\n\n" + texts[name]
+	}
+
+	for _, detector := range []Detector{jaccard, winnowing} {
+		reference := ReferenceNGrams(detector, []string{statement})
+		similarity := func(name1, name2 string, reference []uint64) float64 {
+			return detector.Similarity(
+				detector.Fingerprint(report(name1)).WithoutReference(reference),
+				detector.Fingerprint(report(name2)).WithoutReference(reference),
+			)
+		}
+
+		// Unrelated texts share only the statement
+		for _, pair := range [][2]string{{"01-01", "02-01"}, {"01-03", "02-03"}} {
+			if got := similarity(pair[0], pair[1], nil); got < detector.Threshold() {
+				t.Errorf("%s similarity of %s and %s with the statement = %.2f, want at least %.2f",
+					detector.Name(), pair[0], pair[1], got, detector.Threshold())
+			}
+			if got := similarity(pair[0], pair[1], reference); got >= detector.Threshold()/2 {
+				t.Errorf("%s similarity of %s and %s without the statement = %.2f, want below %.2f",
+					detector.Name(), pair[0], pair[1], got, detector.Threshold()/2)
+			}
+		}
+
+		// Copies stay similar
+		for _, pair := range [][2]string{{"01-01", "01-02"}, {"02-01", "02-04"}} {
+			if got := similarity(pair[0], pair[1], reference); got < detector.Threshold() {
+				t.Errorf("%s similarity of copies %s and %s without the statement = %.2f, want at least %.2f",
+					detector.Name(), pair[0], pair[1], got, detector.Threshold())
+			}
+		}
+
+		// Submitting the statement alone copies nothing
+		if got := detector.Similarity(
+			detector.Fingerprint(statement).WithoutReference(reference),
+			detector.Fingerprint(statement).WithoutReference(reference),
+		); got != 0 {
+			t.Errorf("%s similarity of the statement to itself without the statement = %.2f, want 0", detector.Name(), got)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package analyzer
+
+import (
+	"strings"
+)
+
+// englishExceptions are words the English Snowball stemmer maps to fixed stems
+var englishExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// englishInvariants are words left as they are once their plural ending is removed
+var englishInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+// englishStep2 and englishStep3 map endings to their replacements, checked longest first
+var (
+	englishStep2 = []struct{ suffix, replacement string }{
+		{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+		{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+		{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"},
+		{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"}, {"alli", "al"},
+		{"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+	}
+	englishStep3 = []struct{ suffix, replacement string }{
+		{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+		{"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+	}
+	englishStep4 = []string{
+		"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+		"al", "er", "ic",
+	}
+)
+
+// isEnglishVowel reports whether r is an English vowel for stemming; Y marks a consonant y
+func isEnglishVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+// stemEnglish reduces a lowercase English word to its stem with the Snowball (Porter2) algorithm
+// (https://snowballstem.org/algorithms/english/stemmer.html)
+func stemEnglish(word string) string {
+	if stem, ok := englishExceptions[word]; ok {
+		return stem
+	}
+	if len(word) <= 2 {
+		return word
+	}
+
+	w := []rune(strings.TrimPrefix(word, "'"))
+
+	// Mark y that acts as a consonant
+	for i, r := range w {
+		if r == 'y' && (i == 0 || isEnglishVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1 := snowballRegion(w, 0, isEnglishVowel)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len([]rune(prefix))
+		}
+	}
+	r2 := snowballRegion(w, r1, isEnglishVowel)
+
+	s := &englishStem{word: w, r1: r1, r2: r2}
+	s.step0()
+	s.step1a()
+	if englishInvariants[string(s.word)] {
+		return string(s.word)
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+
+	return strings.ReplaceAll(string(s.word), "Y", "y")
+}
+
+// englishStem is a word being stemmed with the start of its regions R1 and R2
+type englishStem struct {
+	word   []rune
+	r1, r2 int
+}
+
+// hasSuffix reports whether the word ends with suffix
+func (s *englishStem) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.word), suffix)
+}
+
+// suffixStart returns where suffix starts in the word, which must end with it
+func (s *englishStem) suffixStart(suffix string) int {
+	return len(s.word) - len([]rune(suffix))
+}
+
+// replace replaces the ending suffix of the word with replacement
+func (s *englishStem) replace(suffix, replacement string) {
+	s.word = append(s.word[:s.suffixStart(suffix)], []rune(replacement)...)
+}
+
+// containsVowel reports whether the first n letters of the word contain a vowel
+func (s *englishStem) containsVowel(n int) bool {
+	for _, r := range s.word[:n] {
+		if isEnglishVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsWithShortSyllable reports whether the first n letters of the word end with a short syllable:
+// a non-vowel other than w, x and Y after a vowel after a non-vowel, or a non-vowel after a vowel at the start
+func (s *englishStem) endsWithShortSyllable(n int) bool {
+	w := s.word[:n]
+	switch {
+	case n == 2:
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	case n >= 3:
+		return !isEnglishVowel(w[n-3]) && isEnglishVowel(w[n-2]) &&
+			!isEnglishVowel(w[n-1]) && !strings.ContainsRune("wxY", w[n-1])
+	}
+	return false
+}
+
+// isShort reports whether the word is short: it ends with a short syllable and R1 is empty
+func (s *englishStem) isShort() bool {
+	return s.r1 >= len(s.word) && s.endsWithShortSyllable(len(s.word))
+}
+
+// step0 removes possessive endings
+func (s *englishStem) step0() {
+	for _, suffix := range []string{"'s'", "'s", "'"} {
+		if s.hasSuffix(suffix) {
+			s.replace(suffix, "")
+			return
+		}
+	}
+}
+
+// step1a removes plural endings
+func (s *englishStem) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ied"), s.hasSuffix("ies"):
+		// Both endings have three letters
+		suffix := string(s.word[len(s.word)-3:])
+		if s.suffixStart(suffix) > 1 {
+			s.replace(suffix, "i")
+		} else {
+			s.replace(suffix, "ie")
+		}
+	case s.hasSuffix("us"), s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		// Delete s if a vowel precedes it, not immediately before it
+		if n := s.suffixStart("s"); n >= 2 && s.containsVowel(n-1) {
+			s.replace("s", "")
+		}
+	}
+}
+
+// step1b removes past tense and gerund endings
+func (s *englishStem) step1b() {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if s.hasSuffix(suffix) {
+			if s.suffixStart(suffix) >= s.r1 {
+				s.replace(suffix, "ee")
+			}
+			return
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		if !s.containsVowel(s.suffixStart(suffix)) {
+			return
+		}
+		s.replace(suffix, "")
+
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.word = append(s.word, 'e')
+		case s.endsWithDouble():
+			s.word = s.word[:len(s.word)-1]
+		case s.isShort():
+			s.word = append(s.word, 'e')
+		}
+		return
+	}
+}
+
+// endsWithDouble reports whether the word ends with one of the doubles bb, dd, ff, gg, mm, nn, pp, rr and tt
+func (s *englishStem) endsWithDouble() bool {
+	for _, double := range []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"} {
+		if s.hasSuffix(double) {
+			return true
+		}
+	}
+	return false
+}
+
+// step1c replaces a final y after a non-vowel that is not the first letter with i
+func (s *englishStem) step1c() {
+	n := len(s.word)
+	if n > 2 && (s.word[n-1] == 'y' || s.word[n-1] == 'Y') && !isEnglishVowel(s.word[n-2]) {
+		s.word[n-1] = 'i'
+	}
+}
+
+// step2 replaces derivational endings in R1
+func (s *englishStem) step2() {
+	for _, rule := range englishStep2 {
+		if !s.hasSuffix(rule.suffix) {
+			continue
+		}
+		start := s.suffixStart(rule.suffix)
+		if start < s.r1 {
+			return
+		}
+		switch rule.suffix {
+		case "ogi":
+			if start == 0 || s.word[start-1] != 'l' {
+				return
+			}
+		case "li":
+			if start == 0 || !strings.ContainsRune("cdeghkmnrt", s.word[start-1]) {
+				return
+			}
+		}
+		s.replace(rule.suffix, rule.replacement)
+		return
+	}
+}
+
+// step3 replaces further derivational endings in R1
+func (s *englishStem) step3() {
+	for _, rule := range englishStep3 {
+		if !s.hasSuffix(rule.suffix) {
+			continue
+		}
+		start := s.suffixStart(rule.suffix)
+		if start < s.r1 || (rule.suffix == "ative" && start < s.r2) {
+			return
+		}
+		s.replace(rule.suffix, rule.replacement)
+		return
+	}
+}
+
+// step4 removes endings in R2
+func (s *englishStem) step4() {
+	for _, suffix := range englishStep4 {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		start := s.suffixStart(suffix)
+		if start < s.r2 {
+			return
+		}
+		if suffix == "ion" && (start == 0 || !strings.ContainsRune("st", s.word[start-1])) {
+			return
+		}
+		s.replace(suffix, "")
+		return
+	}
+}
+
+// step5 removes a final e or the second l of a final ll
+func (s *englishStem) step5() {
+	n := len(s.word)
+	switch {
+	case s.hasSuffix("e"):
+		if n-1 >= s.r2 || (n-1 >= s.r1 && !s.endsWithShortSyllable(n-1)) {
+			s.word = s.word[:n-1]
+		}
+	case s.hasSuffix("ll"):
+		if n-1 >= s.r2 {
+			s.word = s.word[:n-1]
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"slices"
+	"strings"
+)
+
+// Endings of the Russian Snowball stemmer. Endings in the groups marked "after а or я"
+// are only removed when they follow one of these letters, which is kept.
+var (
+	russianPerfectiveGerunds = suffixGroups(
+		[]string{"в", "вши", "вшись"}, // after а or я
+		[]string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"},
+	)
+	russianAdjectives = suffixGroups(nil, []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	})
+	russianParticiples = suffixGroups(
+		[]string{"ем", "нн", "вш", "ющ", "щ"}, // after а or я
+		[]string{"ивш", "ывш", "ующ"},
+	)
+	russianReflexives = suffixGroups(nil, []string{"ся", "сь"})
+	russianVerbs      = suffixGroups(
+		[]string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}, // after а or я
+		[]string{
+			"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+			"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+		},
+	)
+	russianNouns = suffixGroups(nil, []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	})
+	russianDerivationals = suffixGroups(nil, []string{"ост", "ость"})
+	russianSuperlatives  = suffixGroups(nil, []string{"ейш", "ейше"})
+)
+
+// suffix is an ending a stemmer removes, possibly only after one of the letters in after
+type suffix struct {
+	text  []rune
+	after string
+}
+
+// suffixGroups builds a list of endings, longest first. Endings of the first group are only removed after а or я.
+func suffixGroups(afterAOrYa, anywhere []string) []suffix {
+	var suffixes []suffix
+	for _, text := range afterAOrYa {
+		suffixes = append(suffixes, suffix{text: []rune(text), after: "ая"})
+	}
+	for _, text := range anywhere {
+		suffixes = append(suffixes, suffix{text: []rune(text)})
+	}
+	slices.SortStableFunc(suffixes, func(a, b suffix) int {
+		return len(b.text) - len(a.text)
+	})
+	return suffixes
+}
+
+// removeSuffix removes the longest of the endings that the word ends with, if it is allowed there.
+// Like the among command of Snowball, it does not fall back to shorter endings when the longest is not allowed.
+func removeSuffix(word []rune, suffixes []suffix) ([]rune, bool) {
+	for _, s := range suffixes {
+		if !hasRuneSuffix(word, s.text) {
+			continue
+		}
+		rest := word[:len(word)-len(s.text)]
+		if s.after != "" && (len(rest) == 0 || !strings.ContainsRune(s.after, rest[len(rest)-1])) {
+			return word, false
+		}
+		return rest, true
+	}
+	return word, false
+}
+
+// hasRuneSuffix reports whether word ends with suffix
+func hasRuneSuffix(word, suffix []rune) bool {
+	return len(word) >= len(suffix) && slices.Equal(word[len(word)-len(suffix):], suffix)
+}
+
+// isRussianVowel reports whether r is a Russian vowel; ё is expected to be folded to е
+func isRussianVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+// stemRussian reduces a lowercase Russian word to its stem with the Snowball algorithm
+// (https://snowballstem.org/algorithms/russian/stemmer.html)
+func stemRussian(word string) string {
+	runes := []rune(word)
+
+	// RV is the region after the first vowel, R2 the region after the second vowel-consonant pair
+	rv := len(runes)
+	for i, r := range runes {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r2 := snowballRegion(runes, snowballRegion(runes, 0, isRussianVowel), isRussianVowel)
+
+	// All steps work on RV; the part before it is kept as is
+	prefix, stem := runes[:rv], runes[rv:]
+
+	// Step 1: a perfective gerund, or else an adjectival, verb or noun ending after an optional reflexive one
+	if rest, ok := removeSuffix(stem, russianPerfectiveGerunds); ok {
+		stem = rest
+	} else {
+		stem, _ = removeSuffix(stem, russianReflexives)
+		if rest, ok := removeSuffix(stem, russianAdjectives); ok {
+			stem, _ = removeSuffix(rest, russianParticiples)
+		} else if rest, ok := removeSuffix(stem, russianVerbs); ok {
+			stem = rest
+		} else {
+			stem, _ = removeSuffix(stem, russianNouns)
+		}
+	}
+
+	// Step 2
+	if len(stem) > 0 && stem[len(stem)-1] == 'и' {
+		stem = stem[:len(stem)-1]
+	}
+
+	// Step 3: a derivational ending in R2
+	if rest, ok := removeSuffix(stem, russianDerivationals); ok && len(prefix)+len(rest) >= r2 {
+		stem = rest
+	}
+
+	// Step 4: undouble н, remove a superlative ending, or remove ь
+	if rest, ok := removeSuffix(stem, russianSuperlatives); ok {
+		stem = rest
+	}
+	switch {
+	case hasRuneSuffix(stem, []rune("нн")):
+		stem = stem[:len(stem)-1]
+	case len(stem) > 0 && stem[len(stem)-1] == 'ь':
+		stem = stem[:len(stem)-1]
+	}
+
+	return string(prefix) + string(stem)
+}
+
+// snowballRegion returns the start of the region after the first non-vowel following a vowel,
+// searching from start. It returns len(word) if there is no such region.
+func snowballRegion(word []rune, start int, isVowel func(rune) bool) int {
+	for i := start + 1; i < len(word); i++ {
+		if !isVowel(word[i]) && isVowel(word[i-1]) {
+			return i + 1
+		}
+	}
+	return len(word)
+}
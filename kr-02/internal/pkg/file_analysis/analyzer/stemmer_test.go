@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+// Words and stems from the sample vocabularies of the Snowball project
+func TestStemRussian(t *testing.T) {
+	tests := map[string]string{
+		"в":                "в",
+		"вагона":           "вагон",
+		"вагонов":          "вагон",
+		"важная":           "важн",
+		"важнейшие":        "важн",
+		"важничал":         "важнича",
+		"важности":         "важност",
+		"важностию":        "важност",
+		"важность":         "важност",
+		"важностью":        "важност",
+		"вазах":            "ваз",
+		"валандался":       "валанда",
+		"валериановых":     "валерианов",
+		"валился":          "вал",
+		"валялись":         "валя",
+		"валяются":         "валя",
+		"программирования": "программирован",
+	}
+
+	for word, want := range tests {
+		if got := stemRussian(word); got != want {
+			t.Errorf("stemRussian(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+// Words and stems from the sample vocabularies of the Snowball project
+func TestStemEnglish(t *testing.T) {
+	tests := map[string]string{
+		"consign":       "consign",
+		"consigned":     "consign",
+		"consignment":   "consign",
+		"consistency":   "consist",
+		"consistently":  "consist",
+		"consolation":   "consol",
+		"consolatory":   "consolatori",
+		"consolidating": "consolid",
+		"consolingly":   "consol",
+		"conspicuously": "conspicu",
+		"conspiracy":    "conspiraci",
+		"conspirators":  "conspir",
+		"constables":    "constabl",
+		"constancy":     "constanc",
+		"knackeries":    "knackeri",
+		"knaves":        "knave",
+		"kneeling":      "kneel",
+		"knightly":      "knight",
+		"knitting":      "knit",
+		"knives":        "knive",
+		"generously":    "generous",
+		"caresses":      "caress",
+		"ponies":        "poni",
+		"ties":          "tie",
+		"gas":           "gas",
+		"skies":         "sky",
+		"by":            "by",
+	}
+
+	for word, want := range tests {
+		if got := stemEnglish(word); got != want {
+			t.Errorf("stemEnglish(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package analyzer
+
+// englishStopWords are common English words ignored in analysis
+var englishStopWords = []string{
+	"a", "an", "the", "and", "or", "but",
+	"is", "are", "was", "were", "be", "been",
+	"in", "on", "at", "to", "for", "with",
+	"by", "of", "about", "from",
+	"this", "that", "these", "those",
+	"it", "its", "it's", "they", "them", "their",
+}
+
+// russianStopWords are common Russian words ignored in analysis, with ё written as е
+var russianStopWords = []string{
+	"а", "без", "более", "бы", "был", "была", "были", "было", "быть", "в", "вам", "вас", "ведь", "весь", "во",
+	"вот", "все", "всего", "всех", "вы", "где", "да", "даже", "для", "до", "его", "ее", "ей", "ему", "если",
+	"есть", "еще", "же", "за", "здесь", "и", "из", "или", "им", "их", "к", "как", "какая", "какой", "когда",
+	"кто", "ли", "либо", "между", "меня", "мне", "может", "мы", "на", "над", "надо", "наш", "не", "него", "нее",
+	"нет", "ни", "них", "но", "ну", "о", "об", "однако", "он", "она", "они", "оно", "от", "очень", "по", "под",
+	"при", "про", "с", "со", "так", "также", "такой", "там", "те", "тем", "то", "того", "тоже", "той", "только",
+	"том", "ты", "у", "уже", "хотя", "чего", "чем", "что", "чтобы", "чье", "чья", "эта", "эти", "это", "этого",
+	"этой", "этом", "этот", "эту", "я",
+}
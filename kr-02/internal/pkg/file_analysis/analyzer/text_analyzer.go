@@ -3,34 +3,43 @@ package analyzer
 import (
 	"regexp"
 	"strings"
-	"unicode"
+	"unicode/utf8"
 )
 
 // TextAnalyzer provides methods for analyzing text content
 type TextAnalyzer struct {
-	// Common words to ignore in analysis (stop words)
+	// Common words to ignore in analysis (stop words), lowercase and with ё written as е
 	StopWords map[string]bool
+
+	// Stem reduces significant words to their stems, so that different forms of a word match
+	Stem bool
+
+	// Number of most frequent significant words reported in text statistics
+	// Default is 10
+	TopTermCount int
 }
 
 // NewTextAnalyzer creates a new TextAnalyzer instance
 func NewTextAnalyzer() *TextAnalyzer {
-	// Initialize with common English stop words
-	stopWords := map[string]bool{
-		"a": true, "an": true, "the": true, "and": true, "or": true, "but": true,
-		"is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
-		"in": true, "on": true, "at": true, "to": true, "for": true, "with": true,
-		"by": true, "of": true, "about": true, "from": true,
-		"this": true, "that": true, "these": true, "those": true,
-		"it": true, "its": true, "it's": true, "they": true, "them": true, "their": true,
+	// Initialize with common English and Russian stop words
+	stopWords := make(map[string]bool, len(englishStopWords)+len(russianStopWords))
+	for _, word := range englishStopWords {
+		stopWords[word] = true
+	}
+	for _, word := range russianStopWords {
+		stopWords[word] = true
 	}
 
 	return &TextAnalyzer{
-		StopWords: stopWords,
+		StopWords:    stopWords,
+		TopTermCount: 10,
 	}
 }
 
 // AnalyzeText analyzes text content and returns statistics
-func (a *TextAnalyzer) AnalyzeText(content string) (paragraphCount, wordCount, characterCount int32) {
+func (a *TextAnalyzer) AnalyzeText(content string) TextStats {
+	var stats TextStats
+
 	// Count paragraphs (separated by double newlines)
 	paragraphs := strings.Split(content, "\n\n")
 	// Filter out empty paragraphs
@@ -40,16 +49,38 @@ func (a *TextAnalyzer) AnalyzeText(content string) (paragraphCount, wordCount, c
 			nonEmptyParagraphs = append(nonEmptyParagraphs, p)
 		}
 	}
-	paragraphCount = int32(len(nonEmptyParagraphs))
+	stats.ParagraphCount = int32(len(nonEmptyParagraphs))
 
 	// Count words
 	words := strings.Fields(content)
-	wordCount = int32(len(words))
+	stats.WordCount = int32(len(words))
 
-	// Count characters (including whitespace)
-	characterCount = int32(len(content))
+	// Count characters (including whitespace), not bytes
+	stats.CharacterCount = int32(utf8.RuneCountInString(content))
+
+	// Sentences, vocabulary and readability are computed on the normalized words
+	tokens := a.tokens(content)
+	stats.SentenceCount = int32(countSentences(content))
+	if stats.SentenceCount > 0 {
+		stats.AverageSentenceLength = float64(len(tokens)) / float64(stats.SentenceCount)
+	}
+
+	uniqueWords := make(map[string]bool)
+	termCounts := make(map[string]int32)
+	for _, t := range tokens {
+		uniqueWords[t.word] = true
+		if !t.stopWord {
+			termCounts[t.word]++
+		}
+	}
+	stats.UniqueWordCount = int32(len(uniqueWords))
+	if len(tokens) > 0 {
+		stats.LexicalDensity = float64(len(tokens)-countStopWords(tokens)) / float64(len(tokens))
+	}
+	stats.TopTerms = topTerms(termCounts, a.TopTermCount)
+	stats.Readability = readability(content, tokens, stats.AverageSentenceLength, a.DetectLanguage(content))
 
-	return paragraphCount, wordCount, characterCount
+	return stats
 }
 
 // GetWords returns a slice of all words in the content
@@ -57,31 +88,11 @@ func (a *TextAnalyzer) GetWords(content string) []string {
 	return strings.Fields(content)
 }
 
-// GetSignificantWords returns words after removing stop words and punctuation
+// GetSignificantWords returns normalized words after removing stop words and punctuation
 func (a *TextAnalyzer) GetSignificantWords(content string) []string {
-	// Convert to lowercase
-	content = strings.ToLower(content)
-
-	// Remove punctuation
-	var sb strings.Builder
-	for _, r := range content {
-		if !unicode.IsPunct(r) {
-			sb.WriteRune(r)
-		} else {
-			sb.WriteRune(' ')
-		}
-	}
-	content = sb.String()
-
-	// Split into words
-	words := strings.Fields(content)
-
-	// Filter out stop words
 	var significantWords []string
-	for _, word := range words {
-		if !a.StopWords[word] {
-			significantWords = append(significantWords, word)
-		}
+	for _, t := range a.significantTokens(content) {
+		significantWords = append(significantWords, t.word)
 	}
 
 	return significantWords
@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// TextStatsVersion is incremented whenever statistics are added or computed differently,
+// so that stored statistics of another version are computed again
+const TextStatsVersion = 1
+
+// TextStats are the statistics of a text.
+// Sentence lengths, unique words, lexical density and top terms count the normalized words
+// that significant words are taken from, so they follow the stop words and stemming settings.
+type TextStats struct {
+	ParagraphCount        int32
+	WordCount             int32 // Whitespace-separated words
+	CharacterCount        int32 // Characters (runes) including whitespace
+	SentenceCount         int32
+	AverageSentenceLength float64 // Words per sentence
+	UniqueWordCount       int32   // Distinct normalized words, stop words included
+	LexicalDensity        float64 // Share of words that are not stop words, from 0 to 1
+	TopTerms              []TermCount
+	Readability           float64 // Flesch reading ease for the text language, from 0 (hardest) to 100 (easiest)
+}
+
+// TermCount is a significant word and how often it occurs
+type TermCount struct {
+	Term  string
+	Count int32
+}
+
+// sentenceTerminators end sentences; runs of them, such as "?!" or "...", end a single sentence
+const sentenceTerminators = ".!?…"
+
+// countSentences counts the sentences of a text: runs of text with letters or digits
+// ending with a sentence terminator, a paragraph break or the end of the text
+func countSentences(content string) int {
+	runes := []rune(content)
+	count := 0
+	inSentence := false
+	newlines := 0
+
+	for i, r := range runes {
+		switch {
+		case r == '\n':
+			newlines++
+			if newlines >= 2 && inSentence {
+				count++
+				inSentence = false
+			}
+			continue
+		case strings.ContainsRune(sentenceTerminators, r):
+			// A point between digits is a decimal separator
+			if r == '.' && i > 0 && i+1 < len(runes) && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+				break
+			}
+			if inSentence {
+				count++
+				inSentence = false
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+		if !unicode.IsSpace(r) {
+			newlines = 0
+		}
+	}
+	if inSentence {
+		count++
+	}
+
+	return count
+}
+
+// countStopWords counts the stop words among tokens
+func countStopWords(tokens []token) int {
+	count := 0
+	for _, t := range tokens {
+		if t.stopWord {
+			count++
+		}
+	}
+	return count
+}
+
+// topTerms returns at most limit of the most frequent terms, alphabetically among equally frequent ones
+func topTerms(termCounts map[string]int32, limit int) []TermCount {
+	terms := make([]TermCount, 0, len(termCounts))
+	for term, count := range termCounts {
+		terms = append(terms, TermCount{Term: term, Count: count})
+	}
+
+	slices.SortFunc(terms, func(a, b TermCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return strings.Compare(a.Term, b.Term)
+	})
+	if len(terms) > limit {
+		terms = terms[:max(limit, 0)]
+	}
+	return terms
+}
+
+// readability computes the Flesch reading ease of a text from its words and average sentence length.
+// Russian texts use the coefficients adapted by Oborneva, other texts the original English ones.
+func readability(content string, tokens []token, averageSentenceLength float64, language Language) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	runes := []rune(content)
+	syllables := 0
+	for _, t := range tokens {
+		syllables += countSyllables(strings.ToLower(string(runes[t.start:t.end])))
+	}
+	averageSyllables := float64(syllables) / float64(len(tokens))
+
+	var score float64
+	if language == LanguageRussian {
+		score = 206.835 - 1.3*averageSentenceLength - 60.1*averageSyllables
+	} else {
+		score = 206.835 - 1.015*averageSentenceLength - 84.6*averageSyllables
+	}
+	return min(max(score, 0), 100)
+}
+
+// countSyllables estimates the syllables of a lowercase word: every Russian vowel is a syllable,
+// and every group of English vowels except a silent final e. Words with letters have at least one syllable.
+func countSyllables(word string) int {
+	count := 0
+	hasLetters := false
+	previousVowel := false
+	runes := []rune(word)
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) {
+			previousVowel = false
+			continue
+		}
+		hasLetters = true
+
+		switch {
+		case strings.ContainsRune("аеёиоуыэюя", r):
+			count++
+			previousVowel = false
+		case strings.ContainsRune("aeiouy", r):
+			silentE := r == 'e' && i == len(runes)-1 && i >= 2 && !(runes[i-1] == 'l' && !strings.ContainsRune("aeiouy", runes[i-2]))
+			if !previousVowel && !silentE {
+				count++
+			}
+			previousVowel = true
+		default:
+			previousVowel = false
+		}
+	}
+
+	if hasLetters && count == 0 {
+		return 1
+	}
+	return count
+}
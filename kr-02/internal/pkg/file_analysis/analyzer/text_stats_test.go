@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTextAnalyzer_AnalyzeText(t *testing.T) {
+	analyzer := NewTextAnalyzer()
+	analyzer.TopTermCount = 2
+
+	content := "Сервис хранит файлы. Сервис анализирует файлы!\n\nОтчёт готов"
+	stats := analyzer.AnalyzeText(content)
+
+	if stats.ParagraphCount != 2 {
+		t.Errorf("ParagraphCount = %d, want 2", stats.ParagraphCount)
+	}
+	if stats.WordCount != 8 {
+		t.Errorf("WordCount = %d, want 8", stats.WordCount)
+	}
+	// Characters, not the 108 bytes of the UTF-8 encoding
+	if stats.CharacterCount != 59 {
+		t.Errorf("CharacterCount = %d, want 59", stats.CharacterCount)
+	}
+	if stats.SentenceCount != 3 {
+		t.Errorf("SentenceCount = %d, want 3", stats.SentenceCount)
+	}
+	if want := 8.0 / 3; math.Abs(stats.AverageSentenceLength-want) > 1e-9 {
+		t.Errorf("AverageSentenceLength = %v, want %v", stats.AverageSentenceLength, want)
+	}
+	if stats.UniqueWordCount != 6 {
+		t.Errorf("UniqueWordCount = %d, want 6", stats.UniqueWordCount)
+	}
+	if stats.LexicalDensity != 1 {
+		t.Errorf("LexicalDensity = %v, want 1", stats.LexicalDensity)
+	}
+	wantTerms := []TermCount{{"сервис", 2}, {"файлы", 2}}
+	if !reflect.DeepEqual(stats.TopTerms, wantTerms) {
+		t.Errorf("TopTerms = %v, want %v", stats.TopTerms, wantTerms)
+	}
+	if stats.Readability <= 0 || stats.Readability >= 100 {
+		t.Errorf("Readability = %v, want between 0 and 100", stats.Readability)
+	}
+}
+
+func TestTextAnalyzer_AnalyzeText_Empty(t *testing.T) {
+	stats := NewTextAnalyzer().AnalyzeText("")
+	if !reflect.DeepEqual(stats, TextStats{TopTerms: []TermCount{}}) {
+		t.Errorf("AnalyzeText(\"\") = %+v, want zero statistics", stats)
+	}
+}
+
+func TestCountSentences(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"Terminators", "One. Two! Three? Four", 4},
+		{"Runs of terminators", "Really?! Yes... Fine…", 3},
+		{"Decimal point", "Pi is 3.14 or so.", 1},
+		{"Paragraph without a point", "Heading\n\nText.", 2},
+		{"Line break within a sentence", "A long\nsentence.", 1},
+		{"No words", "... !", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countSentences(tt.content); got != tt.want {
+				t.Errorf("countSentences(%q) = %d, want %d", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	tests := map[string]int{
+		"the":         1,
+		"table":       2,
+		"readability": 5,
+		"file":        1,
+		"сервис":      2,
+		"архитектура": 5,
+		"вв":          1,
+		"42":          0,
+	}
+
+	for word, want := range tests {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+// Simple English text reads easier than long sentences of long words
+func TestReadability(t *testing.T) {
+	analyzer := NewTextAnalyzer()
+	simple := analyzer.AnalyzeText("The cat sat. The dog ran. We had fun.")
+	complicated := analyzer.AnalyzeText("Microservice architectures decompose applications into independently deployable components communicating asynchronously.")
+
+	if simple.Readability <= complicated.Readability {
+		t.Errorf("Readability of simple text = %.1f, want above %.1f", simple.Readability, complicated.Readability)
+	}
+}
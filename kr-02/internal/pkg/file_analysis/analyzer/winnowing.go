@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// winnowingFormat is incremented whenever the way winnowing fingerprints are computed changes
+const winnowingFormat = 1
+
+// WinnowingDetector detects plagiarism the way MOSS does:
+// 1. The text is reduced to its significant words, without spaces, punctuation or case
+// 2. Every k-gram of characters of the reduced text is hashed
+// 3. In every window of consecutive hashes the minimum is selected (winnowing)
+// 4. Texts are compared by the share of selected hashes they have in common
+//
+// Winnowing guarantees that any shared run of at least KGramSize+WindowSize-1 characters is detected,
+// and since the selected hashes depend only on the text around them, reordered paragraphs
+// and insertions leave the rest of the fingerprint unchanged.
+type WinnowingDetector struct {
+	// Threshold for similarity (0.0 to 1.0)
+	// Default is 0.3: 30% of the selected hashes of the shorter text occur in the other text
+	SimilarityThreshold float64
+
+	// Number of characters of a k-gram
+	// Shorter k-grams match common words by chance, longer ones miss slightly edited passages
+	// Default is 12
+	KGramSize int
+
+	// Number of consecutive k-gram hashes to select the minimum from
+	// Larger windows keep fewer hashes but only guarantee detection of longer shared runs
+	// Default is 8
+	WindowSize int
+
+	// TextAnalyzer instance for word extraction and normalization
+	TextAnalyzer *TextAnalyzer
+}
+
+// NewWinnowingDetector creates a new WinnowingDetector instance
+func NewWinnowingDetector() *WinnowingDetector {
+	return &WinnowingDetector{
+		SimilarityThreshold: 0.3,
+		KGramSize:           12,
+		WindowSize:          8,
+		TextAnalyzer:        NewTextAnalyzer(),
+	}
+}
+
+// Name identifies the winnowing detector
+func (d *WinnowingDetector) Name() string {
+	return "winnowing"
+}
+
+// Version identifies the settings fingerprints are computed with
+func (d *WinnowingDetector) Version() string {
+	return fmt.Sprintf("v%d-k%d-w%d-%s", winnowingFormat, d.KGramSize, d.WindowSize, d.TextAnalyzer.version())
+}
+
+// Threshold returns the similarity from which a file is considered plagiarized
+func (d *WinnowingDetector) Threshold() float64 {
+	return d.SimilarityThreshold
+}
+
+// Fingerprint reduces the content to its significant characters and selects its k-gram hashes by winnowing
+func (d *WinnowingDetector) Fingerprint(content string) Fingerprint {
+	reduced := []rune(strings.Join(d.TextAnalyzer.GetSignificantWords(content), ""))
+	if len(reduced) == 0 {
+		return Fingerprint{ContentHash: calculateHash("")}
+	}
+
+	// Texts shorter than a k-gram are a single k-gram
+	k := min(d.KGramSize, len(reduced))
+	hashes := make([]uint64, len(reduced)-k+1)
+	for i := range hashes {
+		hashes[i] = hashNGram(string(reduced[i : i+k]))
+	}
+
+	return Fingerprint{
+		ContentHash: calculateHash(string(reduced)),
+		NGrams:      winnow(hashes, d.WindowSize),
+	}
+}
+
+// Similarity computes the similarity of two fingerprints: 1 for exact matches of the reduced texts,
+// otherwise the share of the selected hashes of the smaller fingerprint that the other one contains.
+// Measuring against the smaller fingerprint keeps a text copied into a longer one similar to it.
+func (d *WinnowingDetector) Similarity(fingerprint1, fingerprint2 Fingerprint) float64 {
+	if exactMatch(fingerprint1, fingerprint2) {
+		return 1
+	}
+
+	smaller := min(len(fingerprint1.NGrams), len(fingerprint2.NGrams))
+	if smaller == 0 {
+		return 0
+	}
+	return float64(countShared(fingerprint1.NGrams, fingerprint2.NGrams)) / float64(smaller)
+}
+
+// CandidateKeys returns the selected hashes of the fingerprint. A file sharing a run of text long enough
+// for winnowing to detect shares one of them, however much longer either file is. The MinHash signatures
+// of a short text and a long file it is copied into estimate a low Jaccard similarity and rarely share a band.
+func (d *WinnowingDetector) CandidateKeys(fingerprint Fingerprint) []uint64 {
+	return fingerprint.NGrams
+}
+
+// winnow selects the minimum hash of every window of consecutive hashes, the rightmost one on ties,
+// and returns the distinct selected hashes sorted
+func winnow(hashes []uint64, windowSize int) []uint64 {
+	windowSize = max(1, min(windowSize, len(hashes)))
+
+	var selected []uint64
+	minIndex := -1
+	for start := 0; start+windowSize <= len(hashes); start++ {
+		end := start + windowSize
+		if minIndex < start {
+			// The previous minimum left the window: scan the whole window
+			minIndex = start
+			for i := start + 1; i < end; i++ {
+				if hashes[i] <= hashes[minIndex] {
+					minIndex = i
+				}
+			}
+			selected = append(selected, hashes[minIndex])
+		} else if hashes[end-1] <= hashes[minIndex] {
+			// The hash entering the window is the new minimum
+			minIndex = end - 1
+			selected = append(selected, hashes[minIndex])
+		}
+	}
+
+	slices.Sort(selected)
+	return slices.Compact(selected)
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWinnow(t *testing.T) {
+	tests := []struct {
+		name       string
+		hashes     []uint64
+		windowSize int
+		want       []uint64
+	}{
+		{
+			// The example of Schleimer, Wilkerson and Aiken, "Winnowing: Local Algorithms for Document Fingerprinting"
+			name:       "Paper example",
+			hashes:     []uint64{77, 74, 42, 17, 98, 50, 17, 98, 8, 88, 67, 39, 77, 74, 42, 17, 98},
+			windowSize: 4,
+			want:       []uint64{8, 17, 39},
+		},
+		{
+			name:       "Fewer hashes than a window",
+			hashes:     []uint64{5, 3, 9},
+			windowSize: 4,
+			want:       []uint64{3},
+		},
+		{
+			name:       "Window of one",
+			hashes:     []uint64{5, 3, 5},
+			windowSize: 1,
+			want:       []uint64{3, 5},
+		},
+		{
+			name:       "No hashes",
+			windowSize: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := winnow(tt.hashes, tt.windowSize); !slices.Equal(got, tt.want) {
+				t.Errorf("winnow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWinnowingDetector_Similarity(t *testing.T) {
+	detector := NewWinnowingDetector()
+
+	content := "Winnowing selects the minimum hash of every window. Selected hashes depend only on nearby text."
+	tests := []struct {
+		name       string
+		other      string
+		similarity float64
+	}{
+		{"Punctuation and case changes", "WINNOWING selects the minimum hash of every window; selected hashes depend only on nearby text!", 1},
+		{"Copied into a longer text", "Introduction. " + content + " Conclusion follows here.", 1},
+		{"Unrelated", "Completely different words describe a rainy afternoon in the city library.", 0},
+		{"Empty", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detector.Similarity(detector.Fingerprint(content), detector.Fingerprint(tt.other))
+			if got != tt.similarity {
+				t.Errorf("Similarity() = %v, want %v", got, tt.similarity)
+			}
+		})
+	}
+}
+
+// Test that fingerprints are computed again when the settings change
+func TestWinnowingDetector_Version(t *testing.T) {
+	version := NewWinnowingDetector().Version()
+
+	detector := NewWinnowingDetector()
+	detector.KGramSize = 20
+	if detector.Version() == version {
+		t.Error("Version() did not change with the k-gram size")
+	}
+
+	detector = NewWinnowingDetector()
+	detector.WindowSize = 4
+	if detector.Version() == version {
+		t.Error("Version() did not change with the window size")
+	}
+}
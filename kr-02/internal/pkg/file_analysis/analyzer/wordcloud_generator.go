@@ -8,42 +8,91 @@ import (
 	"github.com/google/uuid"
 	"io"
 	"net/http"
+	"strings"
 )
 
-// WordCloudGenerator provides methods for generating word clouds
+// WordCloudRenderer renders word clouds of texts
+type WordCloudRenderer interface {
+	// GenerateWordCloud renders a word cloud of the text with the options and returns the image
+	// with a new location to store it at
+	GenerateWordCloud(ctx context.Context, text string, options WordCloudOptions) ([]byte, string, error)
+
+	// CacheKey identifies the word cloud rendered with the options, so renderings can be reused.
+	// It returns an error wrapping ErrInvalidWordCloudOptions if the options are invalid.
+	CacheKey(options WordCloudOptions) (string, error)
+}
+
+// WordCloudGenerator provides methods for generating word clouds with an HTTP word cloud API such as quickchart.io.
+// The significant words of the text are sent to the API; LocalWordCloudRenderer renders word clouds without
+// sending texts anywhere.
 type WordCloudGenerator struct {
-	apiURL string
+	apiURL       string
+	textAnalyzer *TextAnalyzer
 }
 
-// NewWordCloudGenerator creates a new WordCloudGenerator instance
-func NewWordCloudGenerator(apiURL string) *WordCloudGenerator {
+// NewWordCloudGenerator creates a new WordCloudGenerator instance taking words from the text analyzer
+func NewWordCloudGenerator(apiURL string, textAnalyzer *TextAnalyzer) *WordCloudGenerator {
 	if apiURL == "" {
 		apiURL = "https://quickchart.io/wordcloud"
 	}
 	return &WordCloudGenerator{
-		apiURL: apiURL,
+		apiURL:       apiURL,
+		textAnalyzer: textAnalyzer,
 	}
 }
 
+// defaultWordCloudOptions are the options the API renders with when a request leaves them zero
+var defaultWordCloudOptions = WordCloudOptions{Width: 1024, Height: 1024, Format: WordCloudPNG, MaxWords: 100}
+
 // WordItem represents a word and its frequency for the word cloud API
 type WordItem struct {
 	Text  string `json:"text"`
 	Value int    `json:"value"`
 }
 
-// GenerateWordCloud generates a word cloud image from the given words
-func (g *WordCloudGenerator) GenerateWordCloud(ctx context.Context, text string) ([]byte, string, error) {
+// GenerateWordCloud generates a word cloud image from the significant words of the text.
+// Stop words are removed before the words are sent, since the API knows only its own English ones.
+func (g *WordCloudGenerator) GenerateWordCloud(ctx context.Context, text string, options WordCloudOptions) ([]byte, string, error) {
+	options, palette, err := resolveWordCloudOptions(options, defaultWordCloudOptions, DefaultWordCloudPalette)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The API counts the words itself, so each word is repeated as often as it occurs
+	var words []string
+	for _, word := range wordCloudWords(g.textAnalyzer, text, options) {
+		for i := 0; i < word.count; i++ {
+			words = append(words, word.text)
+		}
+	}
+	colors := make([]string, len(palette))
+	for i, c := range palette {
+		colors[i] = hexColor(c)
+	}
+	wordCase := "lower"
+	if options.PreserveCase {
+		wordCase = "none"
+	}
+
 	// Prepare the request payload
 	requestData := struct {
-		Width  int    `json:"width"`
-		Height int    `json:"height"`
-		Text   string `json:"text"`
-		Format string `json:"format"`
+		Width           int      `json:"width"`
+		Height          int      `json:"height"`
+		Text            string   `json:"text"`
+		Format          string   `json:"format"`
+		MaxNumWords     int      `json:"maxNumWords"`
+		Colors          []string `json:"colors"`
+		Case            string   `json:"case"`
+		RemoveStopwords bool     `json:"removeStopwords"`
 	}{
-		Width:  1024,
-		Height: 1024,
-		Text:   text,
-		Format: "png",
+		Width:           options.Width,
+		Height:          options.Height,
+		Text:            strings.Join(words, " "),
+		Format:          options.Format,
+		MaxNumWords:     options.MaxWords,
+		Colors:          colors,
+		Case:            wordCase,
+		RemoveStopwords: false,
 	}
 
 	// Convert to JSON
@@ -79,7 +128,16 @@ func (g *WordCloudGenerator) GenerateWordCloud(ctx context.Context, text string)
 	}
 
 	// Generate a unique location for the image
-	location := uuid.New().String() + ".png"
+	location := uuid.New().String() + "." + options.Format
 
 	return imageData, location, nil
 }
+
+// CacheKey identifies the word cloud GenerateWordCloud renders of a text with the options
+func (g *WordCloudGenerator) CacheKey(options WordCloudOptions) (string, error) {
+	options, palette, err := resolveWordCloudOptions(options, defaultWordCloudOptions, DefaultWordCloudPalette)
+	if err != nil {
+		return "", err
+	}
+	return wordCloudCacheKey("http-"+g.apiURL, options, palette, g.textAnalyzer), nil
+}
@@ -0,0 +1,227 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidWordCloudOptions is returned when word cloud options are out of range
+var ErrInvalidWordCloudOptions = errors.New("invalid word cloud options")
+
+// Limits of word cloud options
+const (
+	MinWordCloudSize      = 64
+	MaxWordCloudSize      = 4096
+	MaxWordCloudWords     = 500
+	MaxWordCloudStopWords = 200
+)
+
+// WordCloudOptions choose how a word cloud is rendered. Zero values select the defaults of the renderer.
+type WordCloudOptions struct {
+	// Size of the image in pixels
+	Width  int
+	Height int
+
+	// Image format, WordCloudPNG or WordCloudSVG
+	Format string
+
+	// Name of one of the WordCloudColorSchemes, or comma-separated #rrggbb colors
+	ColorScheme string
+
+	// Number of most frequent words to show
+	MaxWords int
+
+	// PreserveCase shows words as they are most often written in the text instead of lowercase
+	PreserveCase bool
+
+	// Words to leave out in addition to the stop words of the text analyzer
+	ExtraStopWords []string
+}
+
+// WordCloudColorSchemes are the named palettes words can be drawn in
+var WordCloudColorSchemes = map[string][]color.RGBA{
+	"default": DefaultWordCloudPalette,
+	"warm": {
+		{R: 0xb2, G: 0x18, B: 0x2b, A: 0xff},
+		{R: 0xd6, G: 0x60, B: 0x4d, A: 0xff},
+		{R: 0xf4, G: 0xa5, B: 0x82, A: 0xff},
+		{R: 0xe6, G: 0x55, B: 0x0d, A: 0xff},
+		{R: 0x8c, G: 0x2d, B: 0x04, A: 0xff},
+	},
+	"cool": {
+		{R: 0x08, G: 0x45, B: 0x94, A: 0xff},
+		{R: 0x21, G: 0x71, B: 0xb5, A: 0xff},
+		{R: 0x41, G: 0xb6, B: 0xc4, A: 0xff},
+		{R: 0x1d, G: 0x91, B: 0xc0, A: 0xff},
+		{R: 0x22, G: 0x5e, B: 0xa8, A: 0xff},
+	},
+	"grayscale": {
+		{R: 0x25, G: 0x25, B: 0x25, A: 0xff},
+		{R: 0x52, G: 0x52, B: 0x52, A: 0xff},
+		{R: 0x73, G: 0x73, B: 0x73, A: 0xff},
+		{R: 0x96, G: 0x96, B: 0x96, A: 0xff},
+	},
+}
+
+// Validate checks that the options are within their limits and name a known format and color scheme
+func (o WordCloudOptions) Validate() error {
+	switch {
+	case o.Width != 0 && (o.Width < MinWordCloudSize || o.Width > MaxWordCloudSize):
+		return fmt.Errorf("%w: width must be from %d to %d", ErrInvalidWordCloudOptions, MinWordCloudSize, MaxWordCloudSize)
+	case o.Height != 0 && (o.Height < MinWordCloudSize || o.Height > MaxWordCloudSize):
+		return fmt.Errorf("%w: height must be from %d to %d", ErrInvalidWordCloudOptions, MinWordCloudSize, MaxWordCloudSize)
+	case o.Format != "" && o.Format != WordCloudPNG && o.Format != WordCloudSVG:
+		return fmt.Errorf("%w: format must be %s or %s", ErrInvalidWordCloudOptions, WordCloudPNG, WordCloudSVG)
+	case o.MaxWords < 0 || o.MaxWords > MaxWordCloudWords:
+		return fmt.Errorf("%w: max words must be from 1 to %d", ErrInvalidWordCloudOptions, MaxWordCloudWords)
+	case len(o.ExtraStopWords) > MaxWordCloudStopWords:
+		return fmt.Errorf("%w: at most %d extra stop words are allowed", ErrInvalidWordCloudOptions, MaxWordCloudStopWords)
+	}
+
+	if o.ColorScheme != "" {
+		if _, err := colorScheme(o.ColorScheme); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidWordCloudOptions, err)
+		}
+	}
+	return nil
+}
+
+// colorScheme returns the palette of a named color scheme, or parses a list of colors
+func colorScheme(scheme string) ([]color.RGBA, error) {
+	if palette, ok := WordCloudColorSchemes[scheme]; ok {
+		return palette, nil
+	}
+	if !strings.Contains(scheme, "#") {
+		return nil, fmt.Errorf("unknown color scheme %q", scheme)
+	}
+	return ParsePalette(scheme)
+}
+
+// resolveWordCloudOptions fills the options left zero with the defaults and returns the palette they select
+func resolveWordCloudOptions(options, defaults WordCloudOptions, defaultPalette []color.RGBA) (WordCloudOptions, []color.RGBA, error) {
+	if err := options.Validate(); err != nil {
+		return WordCloudOptions{}, nil, err
+	}
+
+	if options.Width == 0 {
+		options.Width = defaults.Width
+	}
+	if options.Height == 0 {
+		options.Height = defaults.Height
+	}
+	if options.Format == "" {
+		options.Format = defaults.Format
+	}
+	if options.MaxWords == 0 {
+		options.MaxWords = defaults.MaxWords
+	}
+
+	palette := defaultPalette
+	if options.ColorScheme != "" {
+		palette, _ = colorScheme(options.ColorScheme)
+	}
+	if len(palette) == 0 {
+		palette = DefaultWordCloudPalette
+	}
+	return options, palette, nil
+}
+
+// wordCloudCacheKey identifies a rendering of a text: the same key means the same image.
+// It covers the renderer, the resolved options and the normalization of the words.
+func wordCloudCacheKey(renderer string, options WordCloudOptions, palette []color.RGBA, textAnalyzer *TextAnalyzer) string {
+	colors := make([]string, len(palette))
+	for i, c := range palette {
+		colors[i] = hexColor(c)
+	}
+
+	fields := []string{
+		renderer,
+		strconv.Itoa(options.Width) + "x" + strconv.Itoa(options.Height),
+		options.Format,
+		strings.Join(colors, ","),
+		strconv.Itoa(options.MaxWords),
+		strconv.FormatBool(options.PreserveCase),
+		strings.Join(textAnalyzer.extraStopWords(options.ExtraStopWords), ","),
+		textAnalyzer.version(),
+	}
+	return calculateHash(strings.Join(fields, "\n"))[:16]
+}
+
+// wordCount is a word of a word cloud, as shown, with its frequency
+type wordCount struct {
+	text  string
+	count int
+}
+
+// wordCloudWords returns the most frequent significant words of a text without the extra stop words,
+// most frequent first and alphabetically among equally frequent words, so layouts are reproducible
+func wordCloudWords(textAnalyzer *TextAnalyzer, text string, options WordCloudOptions) []wordCount {
+	extraStopWords := make(map[string]bool)
+	for _, word := range textAnalyzer.extraStopWords(options.ExtraStopWords) {
+		extraStopWords[word] = true
+	}
+
+	runes := []rune(text)
+	counts := make(map[string]int)
+	// How often each word is written in each way, for showing words as written
+	spellings := make(map[string]map[string]int)
+	for _, t := range textAnalyzer.significantTokens(text) {
+		if extraStopWords[t.word] {
+			continue
+		}
+		counts[t.word]++
+		if options.PreserveCase {
+			if spellings[t.word] == nil {
+				spellings[t.word] = make(map[string]int)
+			}
+			spellings[t.word][string(runes[t.start:t.end])]++
+		}
+	}
+
+	words := make([]wordCount, 0, len(counts))
+	for word, count := range counts {
+		shown := word
+		if options.PreserveCase {
+			shown = mostFrequent(spellings[word])
+		}
+		words = append(words, wordCount{text: shown, count: count})
+	}
+	slices.SortFunc(words, func(a, b wordCount) int {
+		if a.count != b.count {
+			return b.count - a.count
+		}
+		return strings.Compare(a.text, b.text)
+	})
+	if len(words) > options.MaxWords {
+		words = words[:max(options.MaxWords, 0)]
+	}
+	return words
+}
+
+// mostFrequent returns the most frequent of the strings, the first alphabetically among equally frequent ones
+func mostFrequent(counts map[string]int) string {
+	best, bestCount := "", 0
+	for s, count := range counts {
+		if count > bestCount || (count == bestCount && s < best) {
+			best, bestCount = s, count
+		}
+	}
+	return best
+}
+
+// extraStopWords normalizes additional stop words the way words of texts are normalized, sorted and without duplicates
+func (a *TextAnalyzer) extraStopWords(words []string) []string {
+	var normalized []string
+	for _, word := range words {
+		// Stop words are left out of texts anyway
+		for _, t := range a.significantTokens(word) {
+			normalized = append(normalized, t.word)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordCloudOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options WordCloudOptions
+		valid   bool
+	}{
+		{"defaults", WordCloudOptions{}, true},
+		{"all options", WordCloudOptions{Width: 800, Height: 600, Format: WordCloudSVG, ColorScheme: "warm", MaxWords: 50, PreserveCase: true, ExtraStopWords: []string{"сервис"}}, true},
+		{"custom colors", WordCloudOptions{ColorScheme: "#ff0000,#00ff00"}, true},
+		{"too small", WordCloudOptions{Width: 10}, false},
+		{"too large", WordCloudOptions{Height: MaxWordCloudSize + 1}, false},
+		{"unknown format", WordCloudOptions{Format: "gif"}, false},
+		{"unknown color scheme", WordCloudOptions{ColorScheme: "rainbow"}, false},
+		{"invalid colors", WordCloudOptions{ColorScheme: "#ff0000,#nope"}, false},
+		{"negative max words", WordCloudOptions{MaxWords: -1}, false},
+		{"too many words", WordCloudOptions{MaxWords: MaxWordCloudWords + 1}, false},
+		{"too many stop words", WordCloudOptions{ExtraStopWords: make([]string, MaxWordCloudStopWords+1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidWordCloudOptions) {
+				t.Errorf("Validate() error = %v, want ErrInvalidWordCloudOptions", err)
+			}
+		})
+	}
+}
+
+// Test that renderings differ in their cache key exactly when they differ in their image
+func TestLocalWordCloudRenderer_CacheKey(t *testing.T) {
+	renderer, err := NewLocalWordCloudRenderer(NewTextAnalyzer())
+	if err != nil {
+		t.Fatalf("NewLocalWordCloudRenderer() error = %v", err)
+	}
+
+	cacheKey := func(options WordCloudOptions) string {
+		t.Helper()
+		key, err := renderer.CacheKey(options)
+		if err != nil {
+			t.Fatalf("CacheKey(%+v) error = %v", options, err)
+		}
+		return key
+	}
+
+	defaults := cacheKey(WordCloudOptions{})
+	// Options equal to the defaults of the renderer select the same rendering
+	same := []WordCloudOptions{
+		{Width: 1024, Height: 1024, Format: WordCloudPNG, MaxWords: 100},
+		{ColorScheme: "default"},
+	}
+	for _, options := range same {
+		if key := cacheKey(options); key != defaults {
+			t.Errorf("CacheKey(%+v) = %s, want the default key %s", options, key, defaults)
+		}
+	}
+
+	keys := map[string]WordCloudOptions{defaults: {}}
+	different := []WordCloudOptions{
+		{Width: 800},
+		{Height: 800},
+		{Format: WordCloudSVG},
+		{ColorScheme: "cool"},
+		{MaxWords: 10},
+		{PreserveCase: true},
+		{ExtraStopWords: []string{"сервис"}},
+	}
+	for _, options := range different {
+		key := cacheKey(options)
+		if other, ok := keys[key]; ok {
+			t.Errorf("CacheKey(%+v) = CacheKey(%+v) = %s, want different keys", options, other, key)
+		}
+		keys[key] = options
+	}
+
+	// Extra stop words are normalized, and their order and duplicates do not matter
+	if a, b := cacheKey(WordCloudOptions{ExtraStopWords: []string{"Сервис", "файлы"}}), cacheKey(WordCloudOptions{ExtraStopWords: []string{"файлы", "сервис", "файлы", "the"}}); a != b {
+		t.Errorf("CacheKey() = %s and %s for equivalent extra stop words, want equal keys", a, b)
+	}
+
+	if _, err := renderer.CacheKey(WordCloudOptions{Format: "gif"}); !errors.Is(err, ErrInvalidWordCloudOptions) {
+		t.Errorf("CacheKey() with an unknown format error = %v, want ErrInvalidWordCloudOptions", err)
+	}
+}
+
+func TestWordCloudWords(t *testing.T) {
+	textAnalyzer := NewTextAnalyzer()
+	text := "Сервис хранит файлы. Сервис анализирует Файлы, файлы. сервис строит облако слов. Moscow Moscow"
+
+	shown := func(words []wordCount) string {
+		var texts []string
+		for _, word := range words {
+			texts = append(texts, word.text)
+		}
+		return strings.Join(texts, " ")
+	}
+
+	tests := []struct {
+		name    string
+		options WordCloudOptions
+		want    string
+	}{
+		{"lowercase", WordCloudOptions{MaxWords: 4}, "сервис файлы moscow анализирует"},
+		{"preserve case", WordCloudOptions{MaxWords: 4, PreserveCase: true}, "Сервис файлы Moscow анализирует"},
+		{"extra stop words", WordCloudOptions{MaxWords: 3, ExtraStopWords: []string{"СЕРВИС", "облако"}}, "файлы moscow анализирует"},
+		{"max words", WordCloudOptions{MaxWords: 1}, "сервис"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shown(wordCloudWords(textAnalyzer, text, tt.options)); got != tt.want {
+				t.Errorf("wordCloudWords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Image formats of word clouds
+const (
+	WordCloudPNG = "png"
+	WordCloudSVG = "svg"
+)
+
+// ErrUnknownWordCloudFormat is returned when a word cloud is requested in a format that cannot be rendered
+var ErrUnknownWordCloudFormat = errors.New("unknown word cloud format")
+
+// DefaultWordCloudPalette are the colors words are drawn in by default
+var DefaultWordCloudPalette = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+}
+
+// LocalWordCloudRenderer renders word clouds in the process, without sending texts anywhere.
+// Its size, format, palette and number of words are the defaults for options left zero.
+// Words are sized by their frequency among the significant words of the text and placed
+// along a spiral from the center, most frequent first, in the embedded Go font.
+type LocalWordCloudRenderer struct {
+	// Size of the image in pixels
+	// Default is 1024x1024
+	Width  int
+	Height int
+
+	// Image format, WordCloudPNG or WordCloudSVG
+	// Default is PNG
+	Format string
+
+	// Colors of the words, used in turn, and of the background
+	Palette    []color.RGBA
+	Background color.RGBA
+
+	// Number of most frequent words to place
+	// Default is 100
+	MaxWords int
+
+	// Font sizes in pixels of the least and the most frequent words
+	// Default is 14 to 120
+	MinFontSize float64
+	MaxFontSize float64
+
+	font         *opentype.Font
+	textAnalyzer *TextAnalyzer
+}
+
+// NewLocalWordCloudRenderer creates a new LocalWordCloudRenderer instance taking words from the text analyzer
+func NewLocalWordCloudRenderer(textAnalyzer *TextAnalyzer) (*LocalWordCloudRenderer, error) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse word cloud font: %w", err)
+	}
+
+	return &LocalWordCloudRenderer{
+		Width:        1024,
+		Height:       1024,
+		Format:       WordCloudPNG,
+		Palette:      DefaultWordCloudPalette,
+		Background:   color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		MaxWords:     100,
+		MinFontSize:  14,
+		MaxFontSize:  120,
+		font:         f,
+		textAnalyzer: textAnalyzer,
+	}, nil
+}
+
+// fontFaces creates faces of the word cloud font in the sizes a word cloud needs, once per size
+type fontFaces struct {
+	font  *opentype.Font
+	faces map[float64]font.Face
+}
+
+// newFontFaces creates an empty set of faces of the font of the renderer
+func (r *LocalWordCloudRenderer) newFontFaces() *fontFaces {
+	return &fontFaces{font: r.font, faces: make(map[float64]font.Face)}
+}
+
+// get returns the face of the given size in pixels
+func (f *fontFaces) get(size float64) (font.Face, error) {
+	if face, ok := f.faces[size]; ok {
+		return face, nil
+	}
+	face, err := opentype.NewFace(f.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	f.faces[size] = face
+	return face, nil
+}
+
+// close releases all faces
+func (f *fontFaces) close() {
+	for _, face := range f.faces {
+		face.Close()
+	}
+}
+
+// placedWord is a word of a word cloud with its font size, color and position
+type placedWord struct {
+	text  string
+	size  float64
+	color color.RGBA
+	dot   image.Point     // Start of the baseline
+	box   image.Rectangle // Area the word covers
+}
+
+// GenerateWordCloud renders a word cloud of the text with the options and returns the image with a new location to store it at.
+// Options left zero are taken from the renderer.
+func (r *LocalWordCloudRenderer) GenerateWordCloud(ctx context.Context, text string, options WordCloudOptions) ([]byte, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	options, palette, err := r.resolve(options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	faces := r.newFontFaces()
+	defer faces.close()
+
+	words, err := r.layout(wordCloudWords(r.textAnalyzer, text, options), options, palette, faces)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var data []byte
+	switch options.Format {
+	case WordCloudPNG:
+		data, err = r.renderPNG(words, options, faces)
+	case WordCloudSVG:
+		data = r.renderSVG(words, options)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownWordCloudFormat, options.Format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Generate a unique location for the image
+	location := uuid.New().String() + "." + options.Format
+
+	return data, location, nil
+}
+
+// CacheKey identifies the word cloud GenerateWordCloud renders of a text with the options
+func (r *LocalWordCloudRenderer) CacheKey(options WordCloudOptions) (string, error) {
+	options, palette, err := r.resolve(options)
+	if err != nil {
+		return "", err
+	}
+	renderer := fmt.Sprintf("local-%g-%g-%s", r.MinFontSize, r.MaxFontSize, hexColor(r.Background))
+	return wordCloudCacheKey(renderer, options, palette, r.textAnalyzer), nil
+}
+
+// resolve validates the options and fills the options left zero from the renderer
+func (r *LocalWordCloudRenderer) resolve(options WordCloudOptions) (WordCloudOptions, []color.RGBA, error) {
+	defaults := WordCloudOptions{Width: r.Width, Height: r.Height, Format: r.Format, MaxWords: r.MaxWords}
+	return resolveWordCloudOptions(options, defaults, r.Palette)
+}
+
+// layout sizes the words, most frequent first, and places them along a spiral.
+// Words that do not fit anymore are left out.
+func (r *LocalWordCloudRenderer) layout(words []wordCount, options WordCloudOptions, palette []color.RGBA, faces *fontFaces) ([]placedWord, error) {
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	maxCount, minCount := words[0].count, words[len(words)-1].count
+	bounds := image.Rect(0, 0, options.Width, options.Height)
+	center := image.Pt(options.Width/2, options.Height/2)
+	// The spiral is stretched to the shape of the image and ends once it has left the image in every direction
+	aspect := float64(options.Width) / float64(max(options.Height, 1))
+	maxRadius := float64(max(options.Width, options.Height))
+
+	var placed []placedWord
+	for i, word := range words {
+		size := r.MaxFontSize
+		if maxCount > minCount {
+			size = r.MinFontSize + (r.MaxFontSize-r.MinFontSize)*float64(word.count-minCount)/float64(maxCount-minCount)
+		}
+		size = math.Round(size)
+
+		f, err := faces.get(size)
+		if err != nil {
+			return nil, err
+		}
+		width := font.MeasureString(f, word.text).Ceil()
+		// Shrink words too wide for the image
+		if width > options.Width && width > 0 {
+			size = math.Floor(size * float64(options.Width) / float64(width))
+			if size < 1 {
+				continue
+			}
+			if f, err = faces.get(size); err != nil {
+				return nil, err
+			}
+			width = font.MeasureString(f, word.text).Ceil()
+		}
+		metrics := f.Metrics()
+		ascent, height := metrics.Ascent.Ceil(), metrics.Ascent.Ceil()+metrics.Descent.Ceil()
+
+		// Walk an Archimedean spiral outwards in steps of about 8 pixels until the word overlaps no other word
+		for t := 0.0; 2*t <= maxRadius; t += min(0.1, 4/t) {
+			x := center.X + int(aspect*2*t*math.Cos(t)) - width/2
+			y := center.Y + int(2*t*math.Sin(t)) - height/2
+			box := image.Rect(x, y, x+width, y+height)
+			if !box.In(bounds) || overlapsAny(box, placed) {
+				continue
+			}
+
+			placed = append(placed, placedWord{
+				text:  word.text,
+				size:  size,
+				color: palette[i%len(palette)],
+				dot:   image.Pt(x, y+ascent),
+				box:   box,
+			})
+			break
+		}
+	}
+
+	return placed, nil
+}
+
+// overlapsAny reports whether the box overlaps any of the placed words, keeping a margin between words
+func overlapsAny(box image.Rectangle, placed []placedWord) bool {
+	box = box.Inset(-1)
+	for _, word := range placed {
+		if box.Overlaps(word.box) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPNG draws placed words on the background and encodes the image as PNG
+func (r *LocalWordCloudRenderer) renderPNG(words []placedWord, options WordCloudOptions, faces *fontFaces) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, options.Width, options.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+
+	for _, word := range words {
+		f, err := faces.get(word.size)
+		if err != nil {
+			return nil, err
+		}
+		drawer := font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(word.color),
+			Face: f,
+			Dot:  fixed.P(word.dot.X, word.dot.Y),
+		}
+		drawer.DrawString(word.text)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode word cloud: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG writes placed words as SVG text elements. The font is embedded, so the words are shown
+// in the font they were measured with.
+func (r *LocalWordCloudRenderer) renderSVG(words []placedWord, options WordCloudOptions) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		options.Width, options.Height, options.Width, options.Height)
+	fmt.Fprintf(&buf, "<style>@font-face{font-family:\"Go\";src:url(data:font/ttf;base64,%s)}"+
+		"text{font-family:\"Go\",sans-serif}</style>\n", base64.StdEncoding.EncodeToString(goregular.TTF))
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(r.Background))
+
+	for _, word := range words {
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="%s" fill="%s">%s</text>`+"\n",
+			word.dot.X, word.dot.Y, strconv.FormatFloat(word.size, 'f', -1, 64), hexColor(word.color),
+			html.EscapeString(word.text))
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// hexColor formats a color as #rrggbb
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// ParsePalette parses a comma-separated list of #rrggbb colors
+func ParsePalette(value string) ([]color.RGBA, error) {
+	var palette []color.RGBA
+	for _, hex := range strings.Split(value, ",") {
+		hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("invalid color %q, want #rrggbb", hex)
+		}
+		palette = append(palette, color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff})
+	}
+	return palette, nil
+}
@@ -0,0 +1,126 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+const wordCloudText = "Сервис хранит файлы. Сервис анализирует файлы. Сервис строит облако слов. Plagiarism & <report>"
+
+func TestLocalWordCloudRenderer_PNG(t *testing.T) {
+	renderer, err := NewLocalWordCloudRenderer(NewTextAnalyzer())
+	if err != nil {
+		t.Fatalf("NewLocalWordCloudRenderer() error = %v", err)
+	}
+	renderer.Width, renderer.Height = 400, 300
+
+	data, location, err := renderer.GenerateWordCloud(context.Background(), wordCloudText, WordCloudOptions{})
+	if err != nil {
+		t.Fatalf("GenerateWordCloud() error = %v", err)
+	}
+	if !strings.HasSuffix(location, ".png") {
+		t.Errorf("GenerateWordCloud() location = %q, want a .png location", location)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("GenerateWordCloud() did not return a PNG image: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 400 || bounds.Dy() != 300 {
+		t.Errorf("image size = %dx%d, want 400x300", bounds.Dx(), bounds.Dy())
+	}
+
+	// Some pixels are drawn in the first palette color, the color of the most frequent word
+	colored := false
+	for y := 0; y < 300 && !colored; y++ {
+		for x := 0; x < 400 && !colored; x++ {
+			colored = color.RGBAModel.Convert(img.At(x, y)) == DefaultWordCloudPalette[0]
+		}
+	}
+	if !colored {
+		t.Error("image has no pixels in the color of the most frequent word")
+	}
+}
+
+func TestLocalWordCloudRenderer_SVG(t *testing.T) {
+	renderer, err := NewLocalWordCloudRenderer(NewTextAnalyzer())
+	if err != nil {
+		t.Fatalf("NewLocalWordCloudRenderer() error = %v", err)
+	}
+
+	// Options of the request take precedence over the renderer defaults
+	options := WordCloudOptions{Width: 500, Height: 200, Format: WordCloudSVG, ColorScheme: "#123456"}
+	data, location, err := renderer.GenerateWordCloud(context.Background(), wordCloudText, options)
+	if err != nil {
+		t.Fatalf("GenerateWordCloud() error = %v", err)
+	}
+	if !strings.HasSuffix(location, ".svg") {
+		t.Errorf("GenerateWordCloud() location = %q, want a .svg location", location)
+	}
+
+	svg := string(data)
+	for _, want := range []string{`<svg xmlns="http://www.w3.org/2000/svg" width="500" height="200"`, "@font-face", `fill="#123456">сервис</text>`, "&lt;report&gt;"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %q", want)
+		}
+	}
+}
+
+// Test that words are placed without overlapping and inside the image, most frequent first
+func TestLocalWordCloudRenderer_layout(t *testing.T) {
+	renderer, err := NewLocalWordCloudRenderer(NewTextAnalyzer())
+	if err != nil {
+		t.Fatalf("NewLocalWordCloudRenderer() error = %v", err)
+	}
+	options, palette, err := renderer.resolve(WordCloudOptions{Width: 300, Height: 200})
+	if err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+
+	faces := renderer.newFontFaces()
+	defer faces.close()
+
+	words, err := renderer.layout(wordCloudWords(renderer.textAnalyzer, readCorpus(t)["01-01"], options), options, palette, faces)
+	if err != nil {
+		t.Fatalf("layout() error = %v", err)
+	}
+
+	if len(words) < 10 {
+		t.Fatalf("layout() placed %d words, want at least 10", len(words))
+	}
+	for i, word := range words {
+		if !word.box.In(image.Rect(0, 0, 300, 200)) {
+			t.Errorf("word %q at %v is outside the image", word.text, word.box)
+		}
+		for _, other := range words[:i] {
+			if word.box.Overlaps(other.box) {
+				t.Errorf("word %q at %v overlaps %q at %v", word.text, word.box, other.text, other.box)
+			}
+		}
+		if i > 0 && word.size > words[i-1].size {
+			t.Errorf("word %q is larger than the more frequent word %q", word.text, words[i-1].text)
+		}
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	palette, err := ParsePalette("#ff0000, 00ff80")
+	if err != nil {
+		t.Fatalf("ParsePalette() error = %v", err)
+	}
+	want := []color.RGBA{{R: 0xff, A: 0xff}, {G: 0xff, B: 0x80, A: 0xff}}
+	if len(palette) != 2 || palette[0] != want[0] || palette[1] != want[1] {
+		t.Errorf("ParsePalette() = %v, want %v", palette, want)
+	}
+
+	for _, invalid := range []string{"red", "#fff", "#12345g", ""} {
+		if _, err := ParsePalette(invalid); err == nil {
+			t.Errorf("ParsePalette(%q) error = nil, want an error", invalid)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	pb "kr-02/internal/proto/file_storing_service"
 )
@@ -49,19 +54,90 @@ func (c *FileStoringClient) Close() error {
 	return nil
 }
 
-// GetFile retrieves a file from the File Storing Service
+// GetFile retrieves a file from the File Storing Service.
+// The content is downloaded in chunks so files larger than a single gRPC message are supported.
 func (c *FileStoringClient) GetFile(ctx context.Context, fileID string) (string, []byte, error) {
 	// Set a timeout for the request
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 	
 	// Make the request
-	resp, err := c.client.GetFile(ctx, &pb.GetFileRequest{
+	stream, err := c.client.GetFileStream(ctx, &pb.GetFileRequest{
 		FileId: fileID,
 	})
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get file: %w", err)
+		return "", nil, getFileError(fileID, err)
 	}
 	
-	return resp.FileName, resp.Content, nil
-}
\ No newline at end of file
+	// Collect the metadata frame and the content chunks
+	var fileName string
+	var content bytes.Buffer
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, getFileError(fileID, err)
+		}
+		
+		if metadata := resp.GetMetadata(); metadata != nil {
+			fileName = metadata.FileName
+			continue
+		}
+		content.Write(resp.GetChunk())
+	}
+	
+	return fileName, content.Bytes(), nil
+}
+
+// getFileError wraps an error of a file download, telling deleted files apart
+func getFileError(fileID string, err error) error {
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("failed to get file: %w: %s", ErrFileNotFound, fileID)
+	}
+	return fmt.Errorf("failed to get file: %w", err)
+}
+
+// FileUpload is a file of the File Storing Service, when it was uploaded and what for
+type FileUpload struct {
+	FileID     string
+	UploadedAt time.Time
+	Course     string
+	Assignment string
+}
+
+// ListUploads lists the files uploaded at or after the given time, oldest first, or all files if the time is zero
+func (c *FileStoringClient) ListUploads(ctx context.Context, uploadedAfter time.Time) ([]FileUpload, error) {
+	req := &pb.ListFilesRequest{
+		PageSize: 100,
+		SortBy:   pb.FileSortField_FILE_SORT_FIELD_CREATED_AT,
+	}
+	if !uploadedAfter.IsZero() {
+		req.UploadedAfter = timestamppb.New(uploadedAfter)
+	}
+
+	var uploads []FileUpload
+	for {
+		// Set a timeout for each page
+		pageCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		resp, err := c.client.ListFiles(pageCtx, req)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+
+		for _, file := range resp.Files {
+			uploads = append(uploads, FileUpload{
+				FileID:     file.FileId,
+				UploadedAt: file.CreatedAt.AsTime(),
+				Course:     file.GetTags().GetCourse(),
+				Assignment: file.GetTags().GetAssignment(),
+			})
+		}
+		if resp.NextPageToken == "" {
+			return uploads, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
@@ -0,0 +1,19 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFileNotFound is returned when the File Storing Service has no file with the requested ID
+var ErrFileNotFound = errors.New("file not found")
+
+// FileStore defines the operations of the File Storing Service that analyses use
+type FileStore interface {
+	// GetFile retrieves the name and content of a stored file, or returns ErrFileNotFound if it was deleted
+	GetFile(ctx context.Context, fileID string) (string, []byte, error)
+
+	// ListUploads lists the files uploaded at or after the given time, oldest first, or all files if the time is zero
+	ListUploads(ctx context.Context, uploadedAfter time.Time) ([]FileUpload, error)
+}
@@ -2,22 +2,110 @@ package repository
 
 import (
 	"context"
+	"time"
 )
 
 // AnalysisRepository defines the interface for analysis results operations
 type AnalysisRepository interface {
-	// SaveAnalysisResult saves analysis results to the database
-	SaveAnalysisResult(ctx context.Context, fileID string, paragraphCount, wordCount, characterCount int32, isPlagiarism bool, wordCloudLocation string) error
+	// SaveAnalysisResult saves analysis results to the database together with the similar files the analysis found,
+	// in both directions. The similarity records found by an earlier analysis of the file with the same detector
+	// in the same scope are replaced; the ones found by other analyses are kept. Similar files that are not recorded
+	// as stored are skipped, and ErrFileNotStored is returned if the analyzed file is not.
+	SaveAnalysisResult(ctx context.Context, result AnalysisResult, similarFiles []SimilarFile) error
 	
-	// GetAnalysisResult retrieves analysis results by file ID
-	GetAnalysisResult(ctx context.Context, fileID string) (paragraphCount, wordCount, characterCount int32, isPlagiarism bool, wordCloudLocation string, err error)
-	
-	// SaveSimilarFile saves information about a similar file (for plagiarism detection)
-	SaveSimilarFile(ctx context.Context, fileID, similarFileID string) error
+	// GetAnalysisResult retrieves analysis results by file ID, or returns ErrAnalysisNotFound
+	GetAnalysisResult(ctx context.Context, fileID string) (AnalysisResult, error)
+
+	// GetSimilarFileScores retrieves the similar files of a given file ID with their similarity,
+	// upload time and tags, most similar first. Files found similar by several analyses have the highest similarity.
+	GetSimilarFileScores(ctx context.Context, fileID string) ([]SimilarFile, error)
+
+	// GetSimilarityEdges retrieves every pair of similar files matching the filter once,
+	// with the file ID less than the other file ID and the highest similarity found, most similar first
+	GetSimilarityEdges(ctx context.Context, filter SimilarityEdgeFilter) ([]SimilarityEdge, error)
 	
-	// GetSimilarFiles retrieves IDs of similar files for a given file ID
-	GetSimilarFiles(ctx context.Context, fileID string) ([]string, error)
+	// DeleteAnalysisResult deletes analysis results of a file together with its similarity records
+	// in both directions, its word clouds and its upload record, fails its unfinished analysis jobs,
+	// and returns the locations of the word clouds
+	DeleteAnalysisResult(ctx context.Context, fileID string) (wordCloudLocations []string, err error)
 	
-	// GetAllFileIDs retrieves all file IDs in the database
+	// GetAllFileIDs retrieves the IDs of all analyzed and all stored files in the database
 	GetAllFileIDs(ctx context.Context) ([]string, error)
-}
\ No newline at end of file
+
+	// SaveStoredFiles records files of the File Storing Service with their upload times and tags
+	SaveStoredFiles(ctx context.Context, files []StoredFile) error
+
+	// GetStoredFiles retrieves the recorded stored files among the given ones by file ID
+	GetStoredFiles(ctx context.Context, fileIDs []string) (map[string]StoredFile, error)
+
+	// GetLatestUploadTime retrieves the latest upload time of the recorded stored files,
+	// or the zero time if none is recorded
+	GetLatestUploadTime(ctx context.Context) (time.Time, error)
+
+	// GetWordCloudLocations retrieves the storage locations of all word clouds
+	GetWordCloudLocations(ctx context.Context) ([]string, error)
+
+	// GetWordCloud retrieves the location of the word cloud of a file rendered with the options
+	// identified by optionsKey, or returns ErrWordCloudNotFound
+	GetWordCloud(ctx context.Context, fileID, optionsKey string) (location string, err error)
+
+	// SaveWordCloud records the location of the word cloud of a file rendered with the options identified by optionsKey
+	SaveWordCloud(ctx context.Context, fileID, optionsKey, location string) error
+
+	// UpdateWordCloudLocation sets the word cloud location of the analysis results of a file
+	UpdateWordCloudLocation(ctx context.Context, fileID, location string) error
+
+	// SaveFingerprint saves the fingerprint of a file computed by fingerprint.Detector
+	SaveFingerprint(ctx context.Context, fileID string, fingerprint Fingerprint) error
+
+	// FindCandidateFingerprints retrieves the fingerprints of the detector with the given version that share
+	// at least one band key with the given ones, by file ID. Signatures and band keys are not loaded.
+	FindCandidateFingerprints(ctx context.Context, detector, version string, bandKeys []uint64) (map[string]Fingerprint, error)
+
+	// GetFileIDsWithStaleFingerprint retrieves IDs of analyzed or stored files that have no fingerprint
+	// of the detector with the given version, except files whose content failed to be fetched until they are retried
+	GetFileIDsWithStaleFingerprint(ctx context.Context, detector, version string) ([]string, error)
+
+	// SaveFingerprintFailure records that the content of a file failed to be fetched for its fingerprints,
+	// so it is not fetched again before retryAfter
+	SaveFingerprintFailure(ctx context.Context, fileID, errMessage string, retryAfter time.Time) error
+
+	// SaveReferenceDocument saves a reference document
+	SaveReferenceDocument(ctx context.Context, document ReferenceDocument) error
+
+	// GetReferenceDocuments retrieves the reference documents matching the tags, oldest first.
+	// Empty tags match documents with any tag.
+	GetReferenceDocuments(ctx context.Context, tags FileTags) ([]ReferenceDocument, error)
+
+	// DeleteReferenceDocument deletes a reference document by ID, or returns ErrReferenceNotFound
+	DeleteReferenceDocument(ctx context.Context, id string) error
+}
+
+// JobRepository defines the interface for the persistent analysis job queue.
+// Running jobs hold a lease that their worker extends while it makes progress;
+// a job whose lease expires is claimed again by another worker.
+type JobRepository interface {
+	// CreateJob queues a job, or returns the unfinished job of the same file with the same detector and scope
+	// if there is one
+	CreateJob(ctx context.Context, job AnalysisJob) (AnalysisJob, error)
+
+	// GetJob retrieves a job by ID
+	GetJob(ctx context.Context, id string) (AnalysisJob, error)
+
+	// ClaimJob marks the oldest queued job, or a running job with an expired lease, as running
+	// with a new lease and returns it. Jobs that already ran maxAttempts times are failed instead.
+	// ok is false if there is no job to claim.
+	ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (job AnalysisJob, ok bool, err error)
+
+	// UpdateJobProgress records the progress of a running job and extends its lease
+	UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error
+
+	// FinishJob marks a running job as succeeded, or as failed if errMessage is not empty
+	FinishJob(ctx context.Context, id string, errMessage string) error
+
+	// CreateBatch records a batch with its entries and their jobs
+	CreateBatch(ctx context.Context, batch AnalysisBatch) error
+
+	// GetBatch retrieves a batch with its entries by ID, or returns ErrBatchNotFound
+	GetBatch(ctx context.Context, id string) (AnalysisBatch, error)
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAnalysisNotFound is returned when a file has not been analyzed
+var ErrAnalysisNotFound = errors.New("analysis result not found")
+
+// ErrJobNotFound is returned when no analysis job has the requested ID
+var ErrJobNotFound = errors.New("analysis job not found")
+
+// ErrWordCloudNotFound is returned when a file has no word cloud rendered with the requested options
+var ErrWordCloudNotFound = errors.New("word cloud not found")
+
+// ErrBatchNotFound is returned when no analysis batch has the requested ID
+var ErrBatchNotFound = errors.New("analysis batch not found")
+
+// ErrReferenceNotFound is returned when no reference document has the requested ID
+var ErrReferenceNotFound = errors.New("reference document not found")
+
+// ErrFileNotStored is returned when the results of an analysis are saved for a file that is not recorded
+// as stored, such as a file withdrawn while it was analyzed
+var ErrFileNotStored = errors.New("file is not stored")
+
+// AnalysisResult is the stored analysis of a file
+type AnalysisResult struct {
+	FileID                string
+	ParagraphCount        int32
+	WordCount             int32
+	CharacterCount        int32
+	SentenceCount         int32
+	AverageSentenceLength float64 // Words per sentence
+	UniqueWordCount       int32
+	LexicalDensity        float64 // Share of words that are not stop words
+	TopTerms              []TermCount
+	Readability           float64         // Flesch reading ease, from 0 to 100
+	StatsVersion          int             // Version of the statistics, 0 if stored before sentences and terms were counted
+	UploadedAt            time.Time       // Zero if the upload time is not known
+	WordCloudLocation     string          // Word cloud of the latest request; empty if no word cloud was requested
+	Detector              string          // Name of the detector that looked for similar files
+	Scope                 ComparisonScope // Files the file was compared with
+	References            string          // Identifies the reference documents ignored in the comparison; empty if none
+	Tags                  FileTags        // Tags of the file in the File Storing Service, if known
+}
+
+// ComparisonScope limits the files an analyzed file is compared with
+type ComparisonScope string
+
+const (
+	// ScopeGlobal compares with every stored file
+	ScopeGlobal ComparisonScope = "global"
+	// ScopeCourse compares with the files of the same course
+	ScopeCourse ComparisonScope = "course"
+	// ScopeAssignment compares with the files of the same assignment of the same course
+	ScopeAssignment ComparisonScope = "assignment"
+)
+
+// FileTags tell what a file was submitted for; empty tags are not known
+type FileTags struct {
+	Course     string
+	Assignment string
+}
+
+// TermCount is a frequent significant word of an analyzed file and how often it occurs
+type TermCount struct {
+	Term  string
+	Count int32
+}
+
+// JobStatus is the state of an analysis job
+type JobStatus string
+
+const (
+	// JobQueued jobs wait for a worker
+	JobQueued JobStatus = "queued"
+	// JobRunning jobs are being processed by a worker
+	JobRunning JobStatus = "running"
+	// JobSucceeded jobs have stored their analysis results
+	JobSucceeded JobStatus = "succeeded"
+	// JobFailed jobs have stopped with an error
+	JobFailed JobStatus = "failed"
+)
+
+// AnalysisJob describes a queued analysis of a file.
+// StartedAt and FinishedAt are zero until the job has started or finished.
+type AnalysisJob struct {
+	ID                string
+	FileID            string
+	GenerateWordCloud bool
+	WordCloudOptions  WordCloudOptions
+	Detector          string          // Name of the detector to look for similar files with
+	Scope             ComparisonScope // Files to compare with
+	Status            JobStatus
+	Progress          int // Percent
+	Error             string
+	Attempts          int
+	CreatedAt         time.Time
+	StartedAt         time.Time
+	FinishedAt        time.Time
+}
+
+// AnalysisBatch is a group of files analyzed together, such as the reports of an assignment uploaded as one archive
+type AnalysisBatch struct {
+	ID        string
+	Name      string
+	Entries   []BatchEntry // In archive order
+	CreatedAt time.Time
+}
+
+// BatchEntry is a file of a batch. Entries the File Storing Service rejected have an error instead of a file and a job.
+type BatchEntry struct {
+	FileName string
+	FileID   string
+	JobID    string
+	Error    string
+}
+
+// WordCloudOptions are the options a word cloud is rendered with; zero values select the defaults of the renderer
+type WordCloudOptions struct {
+	Width          int
+	Height         int
+	Format         string
+	ColorScheme    string
+	MaxWords       int
+	PreserveCase   bool
+	ExtraStopWords []string
+}
+
+// Fingerprint is the stored preprocessed form of an analyzed file that comparisons work on.
+// Each detector keeps its own fingerprint of a file.
+type Fingerprint struct {
+	Detector    string   // Name of the detector that computed the fingerprint
+	Version     string   // Settings the fingerprint was computed with
+	ContentHash string   // Hash of the preprocessed text
+	NGrams      []uint64 // Sorted distinct hashes the detector compares
+	Signature   []uint64 // MinHash signature of the n-grams
+	BandKeys    []uint64 // LSH band keys of the signature, or the candidate keys of detectors that index their own
+}
+
+// ReferenceDocument is text that the files of an assignment are allowed to share, such as its statement or template.
+// Its n-grams are ignored when files of the assignment are compared.
+type ReferenceDocument struct {
+	ID        string
+	Name      string
+	Tags      FileTags // Assignment, and course if any, of the files the document is shared by
+	Content   string
+	CreatedAt time.Time
+}
+
+// SimilarFile is a file found similar to an analyzed file
+type SimilarFile struct {
+	FileID     string
+	Similarity float64   // 0 if the pair was recorded before similarity scores were kept
+	UploadedAt time.Time // Zero if the upload time is not known
+	Tags       FileTags
+}
+
+// StoredFile is a file of the File Storing Service, when it was uploaded and what for
+type StoredFile struct {
+	FileID     string
+	UploadedAt time.Time
+	Tags       FileTags
+}
+
+// SimilarityEdge is a pair of similar files, recorded once for both directions
+type SimilarityEdge struct {
+	FileID          string
+	OtherFileID     string
+	Similarity      float64   // 0 if the pair was recorded before similarity scores were kept
+	UploadedAt      time.Time // Zero if the upload time of the file is not known
+	OtherUploadedAt time.Time // Zero if the upload time of the other file is not known
+}
+
+// SimilarityEdgeFilter selects pairs of similar files. Zero values mean that the corresponding filter is not applied.
+type SimilarityEdgeFilter struct {
+	MinSimilarity float64
+	// Both files must be uploaded in the range; the start is inclusive and the end exclusive
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+}
@@ -3,8 +3,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"kr-02/internal/pkg/file_analysis/repository"
 )
@@ -19,79 +25,209 @@ func NewAnalysisRepo(db *sql.DB) repository.AnalysisRepository {
 	return &AnalysisRepo{db: db}
 }
 
-// SaveAnalysisResult saves analysis results to the database
-func (r *AnalysisRepo) SaveAnalysisResult(ctx context.Context, fileID string, paragraphCount, wordCount, characterCount int32, isPlagiarism bool, wordCloudLocation string) error {
+// SaveAnalysisResult saves analysis results to the database together with the similar files the analysis found,
+// in both directions. The similarity records found by an earlier analysis of the file with the same detector
+// in the same scope are replaced; the ones found by other analyses are kept. Similar files that are not recorded
+// as stored are skipped, and ErrFileNotStored is returned if the analyzed file is not.
+func (r *AnalysisRepo) SaveAnalysisResult(ctx context.Context, result repository.AnalysisResult, similarFiles []repository.SimilarFile) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the upload records of the files, so withdrawing one of them waits for the results and deletes them,
+	// or the results of files withdrawn during the analysis are not saved
+	fileIDs := []string{result.FileID}
+	for _, similarFile := range similarFiles {
+		fileIDs = append(fileIDs, similarFile.FileID)
+	}
+	stored, err := lockStoredFiles(ctx, tx, fileIDs)
+	if err != nil {
+		return err
+	}
+	if !stored[result.FileID] {
+		return fmt.Errorf("%w: %s", repository.ErrFileNotStored, result.FileID)
+	}
+
+	topTerms, err := marshalTopTerms(result.TopTerms)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO analysis_results (
 			file_id, paragraph_count, word_count, character_count, 
-			is_plagiarism, word_cloud_location, created_at
+			sentence_count, average_sentence_length, unique_word_count, lexical_density, top_terms, readability, stats_version,
+			word_cloud_location, detector, scope, reference_version, created_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, CURRENT_TIMESTAMP)
 		ON CONFLICT (file_id) DO UPDATE SET
 			paragraph_count = $2,
 			word_count = $3,
 			character_count = $4,
-			is_plagiarism = $5,
-			word_cloud_location = $6,
+			sentence_count = $5,
+			average_sentence_length = $6,
+			unique_word_count = $7,
+			lexical_density = $8,
+			top_terms = $9,
+			readability = $10,
+			stats_version = $11,
+			word_cloud_location = $12,
+			detector = $13,
+			scope = $14,
+			reference_version = $15,
 			created_at = CURRENT_TIMESTAMP
 	`
-	_, err := r.db.ExecContext(
-		ctx, query, fileID, paragraphCount, wordCount, characterCount,
-		isPlagiarism, wordCloudLocation,
+	_, err = tx.ExecContext(
+		ctx, query, result.FileID, result.ParagraphCount, result.WordCount, result.CharacterCount,
+		result.SentenceCount, result.AverageSentenceLength, result.UniqueWordCount, result.LexicalDensity, topTerms,
+		result.Readability, result.StatsVersion,
+		result.WordCloudLocation, result.Detector, result.Scope, result.References,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save analysis result: %w", err)
 	}
+
+	// Similar files found by an earlier analysis of the file with the same detector in the same scope
+	query = `DELETE FROM similar_files WHERE source_file_id = $1 AND detector = $2 AND scope = $3`
+	if _, err := tx.ExecContext(ctx, query, result.FileID, result.Detector, result.Scope); err != nil {
+		return fmt.Errorf("failed to delete similar files: %w", err)
+	}
+
+	// Similarity is saved in both directions, so earlier files learn about their copies
+	query = `
+		INSERT INTO similar_files (file_id, similar_file_id, similarity, source_file_id, detector, scope)
+		VALUES ($1, $2, $3, $1, $4, $5), ($2, $1, $3, $1, $4, $5)
+	`
+	for _, similarFile := range similarFiles {
+		if !stored[similarFile.FileID] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, query, result.FileID, similarFile.FileID, similarFile.Similarity, result.Detector, result.Scope)
+		if err != nil {
+			return fmt.Errorf("failed to save similar file: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
-// GetAnalysisResult retrieves analysis results by file ID
-func (r *AnalysisRepo) GetAnalysisResult(ctx context.Context, fileID string) (int32, int32, int32, bool, string, error) {
+// lockStoredFiles locks the upload records of the given files until the transaction ends
+// and reports which of them are recorded
+func lockStoredFiles(ctx context.Context, tx *sql.Tx, fileIDs []string) (map[string]bool, error) {
+	query := `SELECT file_id FROM stored_files WHERE file_id = ANY($1) ORDER BY file_id FOR SHARE`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(fileIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock stored files: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]bool, len(fileIDs))
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan stored file: %w", err)
+		}
+		stored[fileID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stored files: %w", err)
+	}
+	return stored, nil
+}
+
+// GetAnalysisResult retrieves analysis results by file ID with the upload time and tags of the file, if known
+func (r *AnalysisRepo) GetAnalysisResult(ctx context.Context, fileID string) (repository.AnalysisResult, error) {
 	query := `
-		SELECT paragraph_count, word_count, character_count, is_plagiarism, word_cloud_location
-		FROM analysis_results
-		WHERE file_id = $1
+		SELECT a.paragraph_count, a.word_count, a.character_count,
+			a.sentence_count, a.average_sentence_length, a.unique_word_count, a.lexical_density, a.top_terms, a.readability,
+			a.stats_version, s.uploaded_at, COALESCE(s.course, ''), COALESCE(s.assignment, ''),
+			a.word_cloud_location, a.detector, a.scope, a.reference_version
+		FROM analysis_results a
+		LEFT JOIN stored_files s ON s.file_id = a.file_id
+		WHERE a.file_id = $1
 	`
-	var paragraphCount, wordCount, characterCount int32
-	var isPlagiarism bool
+	result := repository.AnalysisResult{FileID: fileID}
 	var wordCloudLocation sql.NullString
+	var uploadedAt sql.NullTime
+	var topTerms []byte
 
 	err := r.db.QueryRowContext(ctx, query, fileID).Scan(
-		&paragraphCount, &wordCount, &characterCount, &isPlagiarism, &wordCloudLocation,
+		&result.ParagraphCount, &result.WordCount, &result.CharacterCount,
+		&result.SentenceCount, &result.AverageSentenceLength, &result.UniqueWordCount, &result.LexicalDensity,
+		&topTerms, &result.Readability,
+		&result.StatsVersion, &uploadedAt, &result.Tags.Course, &result.Tags.Assignment,
+		&wordCloudLocation, &result.Detector, &result.Scope, &result.References,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, 0, 0, false, "", fmt.Errorf("analysis result not found for file ID %s", fileID)
+			return repository.AnalysisResult{}, fmt.Errorf("%w for file ID %s", repository.ErrAnalysisNotFound, fileID)
 		}
-		return 0, 0, 0, false, "", fmt.Errorf("failed to get analysis result: %w", err)
+		return repository.AnalysisResult{}, fmt.Errorf("failed to get analysis result: %w", err)
 	}
 
-	var location string
 	if wordCloudLocation.Valid {
-		location = wordCloudLocation.String
+		result.WordCloudLocation = wordCloudLocation.String
 	}
+	result.UploadedAt = uploadedAt.Time
 
-	return paragraphCount, wordCount, characterCount, isPlagiarism, location, nil
+	if result.TopTerms, err = unmarshalTopTerms(topTerms); err != nil {
+		return repository.AnalysisResult{}, err
+	}
+
+	return result, nil
 }
 
-// SaveSimilarFile saves information about a similar file (for plagiarism detection)
-func (r *AnalysisRepo) SaveSimilarFile(ctx context.Context, fileID, similarFileID string) error {
-	query := `
-		INSERT INTO similar_files (file_id, similar_file_id)
-		VALUES ($1, $2)
-		ON CONFLICT (file_id, similar_file_id) DO NOTHING
-	`
-	_, err := r.db.ExecContext(ctx, query, fileID, similarFileID)
+// storedTerm is the JSON form of a top term in the top_terms column
+type storedTerm struct {
+	Term  string `json:"term"`
+	Count int32  `json:"count"`
+}
+
+// marshalTopTerms encodes top terms for the top_terms column
+func marshalTopTerms(terms []repository.TermCount) ([]byte, error) {
+	stored := make([]storedTerm, len(terms))
+	for i, term := range terms {
+		stored[i] = storedTerm{Term: term.Term, Count: term.Count}
+	}
+
+	data, err := json.Marshal(stored)
 	if err != nil {
-		return fmt.Errorf("failed to save similar file: %w", err)
+		return nil, fmt.Errorf("failed to encode top terms: %w", err)
 	}
-	return nil
+	return data, nil
+}
+
+// unmarshalTopTerms decodes top terms from the top_terms column
+func unmarshalTopTerms(data []byte) ([]repository.TermCount, error) {
+	var stored []storedTerm
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode top terms: %w", err)
+	}
+
+	var terms []repository.TermCount
+	for _, term := range stored {
+		terms = append(terms, repository.TermCount{Term: term.Term, Count: term.Count})
+	}
+	return terms, nil
 }
 
-// GetSimilarFiles retrieves IDs of similar files for a given file ID
-func (r *AnalysisRepo) GetSimilarFiles(ctx context.Context, fileID string) ([]string, error) {
+// GetSimilarFileScores retrieves the similar files of a given file ID with their similarity,
+// upload time and tags, most similar first. Files found similar by several analyses have the highest similarity.
+func (r *AnalysisRepo) GetSimilarFileScores(ctx context.Context, fileID string) ([]repository.SimilarFile, error) {
 	query := `
-		SELECT similar_file_id FROM similar_files WHERE file_id = $1
+		SELECT sf.similar_file_id, COALESCE(sf.similarity, 0), s.uploaded_at, COALESCE(s.course, ''), COALESCE(s.assignment, '')
+		FROM (
+			SELECT similar_file_id, MAX(similarity) AS similarity FROM similar_files
+			WHERE file_id = $1
+			GROUP BY similar_file_id
+		) sf
+		LEFT JOIN stored_files s ON s.file_id = sf.similar_file_id
+		ORDER BY sf.similarity DESC NULLS LAST, sf.similar_file_id
 	`
 	rows, err := r.db.QueryContext(ctx, query, fileID)
 	if err != nil {
@@ -99,26 +235,162 @@ func (r *AnalysisRepo) GetSimilarFiles(ctx context.Context, fileID string) ([]st
 	}
 	defer rows.Close()
 
-	var similarFileIDs []string
+	var similarFiles []repository.SimilarFile
 	for rows.Next() {
-		var similarFileID string
-		if err := rows.Scan(&similarFileID); err != nil {
-			return nil, fmt.Errorf("failed to scan similar file ID: %w", err)
+		var similarFile repository.SimilarFile
+		var uploadedAt sql.NullTime
+		err := rows.Scan(&similarFile.FileID, &similarFile.Similarity, &uploadedAt, &similarFile.Tags.Course, &similarFile.Tags.Assignment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan similar file: %w", err)
 		}
-		similarFileIDs = append(similarFileIDs, similarFileID)
+		similarFile.UploadedAt = uploadedAt.Time
+		similarFiles = append(similarFiles, similarFile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over similar files: %w", err)
+	}
+
+	return similarFiles, nil
+}
+
+// GetSimilarityEdges retrieves every pair of similar files matching the filter once,
+// with the file ID less than the other file ID and the highest similarity found, most similar first
+func (r *AnalysisRepo) GetSimilarityEdges(ctx context.Context, filter repository.SimilarityEdgeFilter) ([]repository.SimilarityEdge, error) {
+	conditions := []string{"sf.file_id < sf.similar_file_id"}
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.MinSimilarity > 0 {
+		addCondition("sf.similarity >= $%d", filter.MinSimilarity)
+	}
+	// Files with unknown upload times are outside of every range
+	if !filter.UploadedAfter.IsZero() {
+		addCondition("s.uploaded_at >= $%[1]d AND o.uploaded_at >= $%[1]d", filter.UploadedAfter.UTC())
+	}
+	if !filter.UploadedBefore.IsZero() {
+		addCondition("s.uploaded_at < $%[1]d AND o.uploaded_at < $%[1]d", filter.UploadedBefore.UTC())
+	}
+
+	query := `
+		SELECT sf.file_id, sf.similar_file_id, COALESCE(sf.similarity, 0), s.uploaded_at, o.uploaded_at FROM (
+			SELECT file_id, similar_file_id, MAX(similarity) AS similarity FROM similar_files
+			GROUP BY file_id, similar_file_id
+		) sf
+		LEFT JOIN stored_files s ON s.file_id = sf.file_id
+		LEFT JOIN stored_files o ON o.file_id = sf.similar_file_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY sf.similarity DESC NULLS LAST, sf.file_id, sf.similar_file_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar files: %w", err)
 	}
+	defer rows.Close()
 
+	var edges []repository.SimilarityEdge
+	for rows.Next() {
+		var edge repository.SimilarityEdge
+		var uploadedAt, otherUploadedAt sql.NullTime
+		if err := rows.Scan(&edge.FileID, &edge.OtherFileID, &edge.Similarity, &uploadedAt, &otherUploadedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan similar files: %w", err)
+		}
+		edge.UploadedAt, edge.OtherUploadedAt = uploadedAt.Time, otherUploadedAt.Time
+		edges = append(edges, edge)
+	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating over similar files: %w", err)
 	}
 
-	return similarFileIDs, nil
+	return edges, nil
 }
 
-// GetAllFileIDs retrieves all file IDs in the database
+// DeleteAnalysisResult deletes analysis results of a file together with its similarity records
+// in both directions, its word clouds and its upload record, fails its unfinished analysis jobs,
+// and returns the locations of the word clouds. The file is not compared with anymore.
+func (r *AnalysisRepo) DeleteAnalysisResult(ctx context.Context, fileID string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The upload record goes first: analyses saving results for the file hold it locked,
+	// so their similarity records are committed before they are deleted below
+	if _, err := tx.ExecContext(ctx, `DELETE FROM stored_files WHERE file_id = $1`, fileID); err != nil {
+		return nil, fmt.Errorf("failed to delete stored file: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE analysis_jobs SET
+			status = 'failed',
+			error = 'file was deleted',
+			finished_at = CURRENT_TIMESTAMP,
+			lease_expires_at = NULL
+		WHERE file_id = $1 AND status IN ('queued', 'running')
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel analysis jobs: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM similar_files WHERE file_id = $1 OR similar_file_id = $1`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete similar files: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM fingerprints WHERE file_id = $1`, fileID); err != nil {
+		return nil, fmt.Errorf("failed to delete fingerprint: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM fingerprint_failures WHERE file_id = $1`, fileID); err != nil {
+		return nil, fmt.Errorf("failed to delete fingerprint failure: %w", err)
+	}
+
+	// The word cloud of the results is also one of the renderings unless it was stored before they were kept
+	rows, err := tx.QueryContext(ctx, `
+		WITH deleted_results AS (
+			DELETE FROM analysis_results WHERE file_id = $1
+			RETURNING word_cloud_location AS location
+		), deleted_word_clouds AS (
+			DELETE FROM word_clouds WHERE file_id = $1
+			RETURNING location
+		)
+		SELECT location FROM deleted_results WHERE location IS NOT NULL AND location <> ''
+		UNION
+		SELECT location FROM deleted_word_clouds
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete analysis result: %w", err)
+	}
+	var wordCloudLocations []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan word cloud location: %w", err)
+		}
+		wordCloudLocations = append(wordCloudLocations, location)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over word cloud locations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return wordCloudLocations, nil
+}
+
+// GetAllFileIDs retrieves the IDs of all analyzed and all stored files in the database
 func (r *AnalysisRepo) GetAllFileIDs(ctx context.Context) ([]string, error) {
 	query := `
 		SELECT file_id FROM analysis_results
+		UNION
+		SELECT file_id FROM stored_files
 	`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -141,3 +413,278 @@ func (r *AnalysisRepo) GetAllFileIDs(ctx context.Context) ([]string, error) {
 
 	return fileIDs, nil
 }
+
+// SaveStoredFiles records files of the File Storing Service with their upload times and tags
+func (r *AnalysisRepo) SaveStoredFiles(ctx context.Context, files []repository.StoredFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	// Upload times are passed as text, since pq arrays do not support time.Time
+	fileIDs := make([]string, len(files))
+	uploadedAt := make([]string, len(files))
+	courses := make([]string, len(files))
+	assignments := make([]string, len(files))
+	for i, file := range files {
+		fileIDs[i] = file.FileID
+		uploadedAt[i] = file.UploadedAt.UTC().Format("2006-01-02 15:04:05.999999")
+		courses[i] = file.Tags.Course
+		assignments[i] = file.Tags.Assignment
+	}
+
+	query := `
+		INSERT INTO stored_files (file_id, uploaded_at, course, assignment)
+		SELECT * FROM UNNEST($1::TEXT[], $2::TIMESTAMP[], $3::TEXT[], $4::TEXT[])
+		ON CONFLICT (file_id) DO UPDATE SET
+			uploaded_at = EXCLUDED.uploaded_at,
+			course = EXCLUDED.course,
+			assignment = EXCLUDED.assignment
+	`
+	_, err := r.db.ExecContext(ctx, query, pq.Array(fileIDs), pq.Array(uploadedAt), pq.Array(courses), pq.Array(assignments))
+	if err != nil {
+		return fmt.Errorf("failed to save stored files: %w", err)
+	}
+	return nil
+}
+
+// GetStoredFiles retrieves the recorded stored files among the given ones by file ID
+func (r *AnalysisRepo) GetStoredFiles(ctx context.Context, fileIDs []string) (map[string]repository.StoredFile, error) {
+	files := make(map[string]repository.StoredFile, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return files, nil
+	}
+
+	query := `SELECT file_id, uploaded_at, course, assignment FROM stored_files WHERE file_id = ANY($1)`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(fileIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file repository.StoredFile
+		if err := rows.Scan(&file.FileID, &file.UploadedAt, &file.Tags.Course, &file.Tags.Assignment); err != nil {
+			return nil, fmt.Errorf("failed to scan stored file: %w", err)
+		}
+		files[file.FileID] = file
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stored files: %w", err)
+	}
+	return files, nil
+}
+
+// GetLatestUploadTime retrieves the latest upload time of the recorded stored files,
+// or the zero time if none is recorded
+func (r *AnalysisRepo) GetLatestUploadTime(ctx context.Context) (time.Time, error) {
+	var uploadedAt sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `SELECT MAX(uploaded_at) FROM stored_files`).Scan(&uploadedAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest upload time: %w", err)
+	}
+	return uploadedAt.Time, nil
+}
+
+// GetWordCloudLocations retrieves the storage locations of all word clouds
+func (r *AnalysisRepo) GetWordCloudLocations(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT word_cloud_location FROM analysis_results
+		WHERE word_cloud_location IS NOT NULL AND word_cloud_location <> ''
+		UNION
+		SELECT location FROM word_clouds
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query word cloud locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			return nil, fmt.Errorf("failed to scan word cloud location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over word cloud locations: %w", err)
+	}
+	return locations, nil
+}
+
+// GetWordCloud retrieves the location of the word cloud of a file rendered with the options identified by optionsKey
+func (r *AnalysisRepo) GetWordCloud(ctx context.Context, fileID, optionsKey string) (string, error) {
+	query := `SELECT location FROM word_clouds WHERE file_id = $1 AND options_key = $2`
+	var location string
+	err := r.db.QueryRowContext(ctx, query, fileID, optionsKey).Scan(&location)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w for file ID %s", repository.ErrWordCloudNotFound, fileID)
+		}
+		return "", fmt.Errorf("failed to get word cloud: %w", err)
+	}
+	return location, nil
+}
+
+// SaveWordCloud records the location of the word cloud of a file rendered with the options identified by optionsKey.
+// A word cloud rendered concurrently with the same options replaces the recorded one.
+func (r *AnalysisRepo) SaveWordCloud(ctx context.Context, fileID, optionsKey, location string) error {
+	query := `
+		INSERT INTO word_clouds (file_id, options_key, location, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (file_id, options_key) DO UPDATE SET
+			location = EXCLUDED.location,
+			created_at = CURRENT_TIMESTAMP
+	`
+	if _, err := r.db.ExecContext(ctx, query, fileID, optionsKey, location); err != nil {
+		return fmt.Errorf("failed to save word cloud: %w", err)
+	}
+	return nil
+}
+
+// UpdateWordCloudLocation sets the word cloud location of the analysis results of a file
+func (r *AnalysisRepo) UpdateWordCloudLocation(ctx context.Context, fileID, location string) error {
+	query := `UPDATE analysis_results SET word_cloud_location = $2 WHERE file_id = $1`
+	if _, err := r.db.ExecContext(ctx, query, fileID, location); err != nil {
+		return fmt.Errorf("failed to update word cloud location: %w", err)
+	}
+	return nil
+}
+
+// SaveFingerprint saves the fingerprint of a file computed by fingerprint.Detector
+func (r *AnalysisRepo) SaveFingerprint(ctx context.Context, fileID string, fingerprint repository.Fingerprint) error {
+	query := `
+		INSERT INTO fingerprints (file_id, detector, version, content_hash, ngrams, signature, band_keys)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (file_id, detector) DO UPDATE SET
+			version = $3,
+			content_hash = $4,
+			ngrams = $5,
+			signature = $6,
+			band_keys = $7
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		fileID, fingerprint.Detector, fingerprint.Version, fingerprint.ContentHash,
+		encodeHashes(fingerprint.NGrams), encodeHashes(fingerprint.Signature),
+		pq.Array(toInt64s(fingerprint.BandKeys)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save fingerprint: %w", err)
+	}
+	return nil
+}
+
+// FindCandidateFingerprints retrieves the fingerprints of the detector with the given version that share
+// at least one band key with the given ones, by file ID
+func (r *AnalysisRepo) FindCandidateFingerprints(ctx context.Context, detector, version string, bandKeys []uint64) (map[string]repository.Fingerprint, error) {
+	fingerprints := make(map[string]repository.Fingerprint)
+	if len(bandKeys) == 0 {
+		return fingerprints, nil
+	}
+
+	query := `
+		SELECT file_id, content_hash, ngrams FROM fingerprints
+		WHERE detector = $1 AND version = $2 AND band_keys && $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, detector, version, pq.Array(toInt64s(bandKeys)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID string
+		var ngrams []byte
+		fingerprint := repository.Fingerprint{Detector: detector, Version: version}
+		if err := rows.Scan(&fileID, &fingerprint.ContentHash, &ngrams); err != nil {
+			return nil, fmt.Errorf("failed to scan fingerprint: %w", err)
+		}
+		fingerprint.NGrams = decodeHashes(ngrams)
+		fingerprints[fileID] = fingerprint
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over fingerprints: %w", err)
+	}
+	return fingerprints, nil
+}
+
+// GetFileIDsWithStaleFingerprint retrieves IDs of analyzed or stored files that have no fingerprint
+// of the detector with the given version, except files whose content failed to be fetched until they are retried
+func (r *AnalysisRepo) GetFileIDsWithStaleFingerprint(ctx context.Context, detector, version string) ([]string, error) {
+	query := `
+		SELECT a.file_id FROM (
+			SELECT file_id FROM analysis_results
+			UNION
+			SELECT file_id FROM stored_files
+		) a
+		LEFT JOIN fingerprints f ON f.file_id = a.file_id AND f.detector = $1
+		LEFT JOIN fingerprint_failures ff ON ff.file_id = a.file_id
+		WHERE (f.file_id IS NULL OR f.version <> $2) AND (ff.file_id IS NULL OR ff.retry_after <= CURRENT_TIMESTAMP)
+	`
+	return r.queryFileIDs(ctx, query, detector, version)
+}
+
+// SaveFingerprintFailure records that the content of a file failed to be fetched for its fingerprints,
+// so it is not fetched again before retryAfter
+func (r *AnalysisRepo) SaveFingerprintFailure(ctx context.Context, fileID, errMessage string, retryAfter time.Time) error {
+	query := `
+		INSERT INTO fingerprint_failures (file_id, error, retry_after)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (file_id) DO UPDATE SET
+			error = EXCLUDED.error,
+			retry_after = EXCLUDED.retry_after
+	`
+	if _, err := r.db.ExecContext(ctx, query, fileID, errMessage, retryAfter.UTC()); err != nil {
+		return fmt.Errorf("failed to save fingerprint failure: %w", err)
+	}
+	return nil
+}
+
+// queryFileIDs runs a query selecting a single column of file IDs
+func (r *AnalysisRepo) queryFileIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var fileIDs []string
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan file ID: %w", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over file IDs: %w", err)
+	}
+	return fileIDs, nil
+}
+
+// toInt64s reinterprets hash values as signed integers, as stored in BIGINT columns
+func toInt64s(values []uint64) []int64 {
+	converted := make([]int64, len(values))
+	for i, value := range values {
+		converted[i] = int64(value)
+	}
+	return converted
+}
+
+// encodeHashes packs hash values into bytes, 8 big-endian bytes each
+func encodeHashes(values []uint64) []byte {
+	encoded := make([]byte, 8*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint64(encoded[8*i:], value)
+	}
+	return encoded
+}
+
+// decodeHashes unpacks hash values packed by encodeHashes
+func decodeHashes(encoded []byte) []uint64 {
+	values := make([]uint64, len(encoded)/8)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint64(encoded[8*i:])
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// CreateBatch records a batch with its entries and their jobs
+func (r *JobRepo) CreateBatch(ctx context.Context, batch repository.AnalysisBatch) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO analysis_batches (id, name, created_at) VALUES ($1, $2, $3)`,
+		batch.ID, batch.Name, batch.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create analysis batch: %w", err)
+	}
+
+	query := `
+		INSERT INTO analysis_batch_entries (batch_id, position, file_name, file_id, job_id, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	for i, entry := range batch.Entries {
+		_, err := tx.ExecContext(ctx, query, batch.ID, i, entry.FileName, entry.FileID, entry.JobID, entry.Error)
+		if err != nil {
+			return fmt.Errorf("failed to save batch entry %s: %w", entry.FileName, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetBatch retrieves a batch with its entries by ID
+func (r *JobRepo) GetBatch(ctx context.Context, id string) (repository.AnalysisBatch, error) {
+	batch := repository.AnalysisBatch{ID: id}
+	err := r.db.QueryRowContext(ctx, `SELECT name, created_at FROM analysis_batches WHERE id = $1`, id).
+		Scan(&batch.Name, &batch.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.AnalysisBatch{}, fmt.Errorf("%w: %s", repository.ErrBatchNotFound, id)
+		}
+		return repository.AnalysisBatch{}, fmt.Errorf("failed to get analysis batch: %w", err)
+	}
+
+	query := `
+		SELECT file_name, file_id, job_id, error FROM analysis_batch_entries
+		WHERE batch_id = $1
+		ORDER BY position
+	`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return repository.AnalysisBatch{}, fmt.Errorf("failed to query batch entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry repository.BatchEntry
+		if err := rows.Scan(&entry.FileName, &entry.FileID, &entry.JobID, &entry.Error); err != nil {
+			return repository.AnalysisBatch{}, fmt.Errorf("failed to scan batch entry: %w", err)
+		}
+		batch.Entries = append(batch.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return repository.AnalysisBatch{}, fmt.Errorf("error iterating over batch entries: %w", err)
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// jobColumns are the columns scanned by scanJob, in order
+const jobColumns = `id, file_id, generate_word_cloud, word_cloud_options, detector, scope, status, progress, error, attempts,
+	created_at, started_at, finished_at`
+
+// JobRepo implements the JobRepository interface using PostgreSQL
+type JobRepo struct {
+	db *sql.DB
+}
+
+// NewJobRepo creates a new JobRepo instance
+func NewJobRepo(db *sql.DB) repository.JobRepository {
+	return &JobRepo{db: db}
+}
+
+// CreateJob queues a job, or returns the unfinished job of the same file if there is one.
+// A word cloud requested by either submission is generated if the job has not reached that step yet,
+// with the options of the submission that requested it first; the detector and scope of the unfinished job are kept.
+func (r *JobRepo) CreateJob(ctx context.Context, job repository.AnalysisJob) (repository.AnalysisJob, error) {
+	options, err := json.Marshal(storedWordCloudOptions(job.WordCloudOptions))
+	if err != nil {
+		return repository.AnalysisJob{}, fmt.Errorf("failed to marshal word cloud options: %w", err)
+	}
+
+	query := `
+		INSERT INTO analysis_jobs (id, file_id, generate_word_cloud, word_cloud_options, detector, scope, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'queued', CURRENT_TIMESTAMP)
+		ON CONFLICT (file_id, detector, scope) WHERE status IN ('queued', 'running') DO UPDATE SET
+			word_cloud_options = CASE WHEN analysis_jobs.generate_word_cloud
+				THEN analysis_jobs.word_cloud_options ELSE EXCLUDED.word_cloud_options END,
+			generate_word_cloud = analysis_jobs.generate_word_cloud OR EXCLUDED.generate_word_cloud
+		RETURNING ` + jobColumns
+	row := r.db.QueryRowContext(ctx, query, job.ID, job.FileID, job.GenerateWordCloud, options, job.Detector, job.Scope)
+	created, err := scanJob(row)
+	if err != nil {
+		return repository.AnalysisJob{}, fmt.Errorf("failed to create analysis job: %w", err)
+	}
+	return created, nil
+}
+
+// GetJob retrieves a job by ID
+func (r *JobRepo) GetJob(ctx context.Context, id string) (repository.AnalysisJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM analysis_jobs WHERE id = $1`
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.AnalysisJob{}, fmt.Errorf("%w: %s", repository.ErrJobNotFound, id)
+		}
+		return repository.AnalysisJob{}, fmt.Errorf("failed to get analysis job: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimJob marks the oldest claimable job as running with a new lease and returns it.
+// Rows locked by other workers are skipped, so concurrent workers never claim the same job.
+func (r *JobRepo) ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (repository.AnalysisJob, bool, error) {
+	// Give up on jobs whose workers keep stopping while processing them
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE analysis_jobs SET
+			status = 'failed',
+			error = 'analysis was interrupted too many times',
+			finished_at = CURRENT_TIMESTAMP,
+			lease_expires_at = NULL
+		WHERE status = 'running' AND lease_expires_at < CURRENT_TIMESTAMP AND attempts >= $1
+	`, maxAttempts)
+	if err != nil {
+		return repository.AnalysisJob{}, false, fmt.Errorf("failed to fail interrupted analysis jobs: %w", err)
+	}
+
+	query := `
+		UPDATE analysis_jobs SET
+			status = 'running',
+			progress = 0,
+			attempts = attempts + 1,
+			started_at = CURRENT_TIMESTAMP,
+			lease_expires_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond'
+		WHERE id = (
+			SELECT id FROM analysis_jobs
+			WHERE status = 'queued' OR (status = 'running' AND lease_expires_at < CURRENT_TIMESTAMP)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, lease.Milliseconds()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.AnalysisJob{}, false, nil
+		}
+		return repository.AnalysisJob{}, false, fmt.Errorf("failed to claim analysis job: %w", err)
+	}
+	return job, true, nil
+}
+
+// UpdateJobProgress records the progress of a running job and extends its lease
+func (r *JobRepo) UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error {
+	query := `
+		UPDATE analysis_jobs SET
+			progress = $2,
+			lease_expires_at = CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond'
+		WHERE id = $1 AND status = 'running'
+	`
+	if _, err := r.db.ExecContext(ctx, query, id, progress, lease.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to update analysis job progress: %w", err)
+	}
+	return nil
+}
+
+// FinishJob marks a running job as succeeded, or as failed if errMessage is not empty
+func (r *JobRepo) FinishJob(ctx context.Context, id string, errMessage string) error {
+	status := repository.JobSucceeded
+	if errMessage != "" {
+		status = repository.JobFailed
+	}
+
+	query := `
+		UPDATE analysis_jobs SET
+			status = $2,
+			error = $3,
+			progress = CASE WHEN $2 = 'succeeded' THEN 100 ELSE progress END,
+			finished_at = CURRENT_TIMESTAMP,
+			lease_expires_at = NULL
+		WHERE id = $1 AND status = 'running'
+	`
+	result, err := r.db.ExecContext(ctx, query, id, string(status), errMessage)
+	if err != nil {
+		return fmt.Errorf("failed to finish analysis job: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to finish analysis job: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s is not running", repository.ErrJobNotFound, id)
+	}
+	return nil
+}
+
+// scanJob scans a row of jobColumns
+func scanJob(row *sql.Row) (repository.AnalysisJob, error) {
+	var job repository.AnalysisJob
+	var status string
+	var options []byte
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.FileID, &job.GenerateWordCloud, &options, &job.Detector, &job.Scope, &status, &job.Progress, &job.Error,
+		&job.Attempts, &job.CreatedAt, &startedAt, &finishedAt,
+	)
+	if err != nil {
+		return repository.AnalysisJob{}, err
+	}
+	var stored storedWordCloudOptions
+	if err := json.Unmarshal(options, &stored); err != nil {
+		return repository.AnalysisJob{}, fmt.Errorf("failed to unmarshal word cloud options: %w", err)
+	}
+	job.WordCloudOptions = repository.WordCloudOptions(stored)
+	job.Status = repository.JobStatus(status)
+	job.StartedAt = startedAt.Time
+	job.FinishedAt = finishedAt.Time
+	return job, nil
+}
+
+// storedWordCloudOptions is the JSON form of word cloud options in the word_cloud_options column
+type storedWordCloudOptions struct {
+	Width          int      `json:"width,omitempty"`
+	Height         int      `json:"height,omitempty"`
+	Format         string   `json:"format,omitempty"`
+	ColorScheme    string   `json:"color_scheme,omitempty"`
+	MaxWords       int      `json:"max_words,omitempty"`
+	PreserveCase   bool     `json:"preserve_case,omitempty"`
+	ExtraStopWords []string `json:"extra_stop_words,omitempty"`
+}
@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// MigrationsComponent names the file analysis schema in the schema_migrations table
+const MigrationsComponent = "file_analysis"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the schema migrations of the file analysis database
+func Migrations() fs.FS {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS similar_files;
+DROP TABLE IF EXISTS analysis_results;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS analysis_results (
+    file_id TEXT PRIMARY KEY,
+    paragraph_count INT NOT NULL,
+    word_count INT NOT NULL,
+    character_count INT NOT NULL,
+    is_plagiarism BOOLEAN NOT NULL,
+    word_cloud_location TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS similar_files (
+    file_id TEXT,
+    similar_file_id TEXT,
+    PRIMARY KEY (file_id, similar_file_id)
+);
@@ -0,0 +1,23 @@
+-- Analysis jobs are queued by SubmitAnalysis and claimed by the workers of any service instance.
+-- A running job whose lease has expired belongs to a worker that stopped and is claimed again.
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+    id TEXT PRIMARY KEY,
+    file_id TEXT NOT NULL,
+    generate_word_cloud BOOLEAN NOT NULL DEFAULT FALSE,
+    status TEXT NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    progress INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    lease_expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+-- At most one unfinished job per file, so repeated submissions share it
+CREATE UNIQUE INDEX analysis_jobs_active_file_idx ON analysis_jobs (file_id)
+    WHERE status IN ('queued', 'running');
+
+CREATE INDEX analysis_jobs_queue_idx ON analysis_jobs (created_at)
+    WHERE status IN ('queued', 'running');
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS minhash_signatures;
@@ -0,0 +1,9 @@
+-- MinHash signatures of analyzed files and the keys of their LSH bands.
+-- The GIN index finds the files sharing a band key with a new file without scanning all signatures.
+CREATE TABLE IF NOT EXISTS minhash_signatures (
+    file_id TEXT PRIMARY KEY,
+    signature BYTEA NOT NULL,
+    band_keys BIGINT[] NOT NULL
+);
+
+CREATE INDEX minhash_signatures_band_keys_idx ON minhash_signatures USING GIN (band_keys);
@@ -0,0 +1,7 @@
+ALTER TABLE fingerprints
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS content_hash,
+    DROP COLUMN IF EXISTS ngrams;
+
+ALTER INDEX fingerprints_band_keys_idx RENAME TO minhash_signatures_band_keys_idx;
+ALTER TABLE fingerprints RENAME TO minhash_signatures;
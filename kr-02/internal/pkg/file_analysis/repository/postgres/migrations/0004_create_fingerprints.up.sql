@@ -0,0 +1,9 @@
+-- Fingerprints hold everything a comparison needs, so the contents of analyzed files are not fetched again.
+-- Signatures saved before get an empty version and are computed again on startup.
+ALTER TABLE minhash_signatures RENAME TO fingerprints;
+ALTER INDEX minhash_signatures_band_keys_idx RENAME TO fingerprints_band_keys_idx;
+
+ALTER TABLE fingerprints
+    ADD COLUMN version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN content_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ngrams BYTEA NOT NULL DEFAULT '';
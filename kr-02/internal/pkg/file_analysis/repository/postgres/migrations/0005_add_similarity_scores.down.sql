@@ -0,0 +1 @@
+ALTER TABLE similar_files DROP COLUMN IF EXISTS similarity;
@@ -0,0 +1,2 @@
+-- Jaccard similarity of each similar pair; NULL for pairs recorded before scores were kept
+ALTER TABLE similar_files ADD COLUMN similarity DOUBLE PRECISION;
@@ -0,0 +1,7 @@
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS detector;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS detector;
+
+DELETE FROM fingerprints WHERE detector <> 'jaccard';
+ALTER TABLE fingerprints DROP CONSTRAINT fingerprints_pkey;
+ALTER TABLE fingerprints ADD CONSTRAINT minhash_signatures_pkey PRIMARY KEY (file_id);
+ALTER TABLE fingerprints DROP COLUMN IF EXISTS detector;
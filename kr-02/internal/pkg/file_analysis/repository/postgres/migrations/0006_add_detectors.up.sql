@@ -0,0 +1,11 @@
+-- Every detector keeps its own fingerprint of a file, so each request can choose the detector.
+-- Everything stored before was computed by the Jaccard detector.
+ALTER TABLE fingerprints ADD COLUMN detector TEXT NOT NULL DEFAULT 'jaccard';
+ALTER TABLE fingerprints DROP CONSTRAINT minhash_signatures_pkey;
+ALTER TABLE fingerprints ADD CONSTRAINT fingerprints_pkey PRIMARY KEY (file_id, detector);
+
+-- The detector that found the similar files of an analysis result
+ALTER TABLE analysis_results ADD COLUMN detector TEXT NOT NULL DEFAULT 'jaccard';
+
+-- The detector an analysis job runs with
+ALTER TABLE analysis_jobs ADD COLUMN detector TEXT NOT NULL DEFAULT 'jaccard';
@@ -0,0 +1,7 @@
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS stats_version;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS readability;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS top_terms;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS lexical_density;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS unique_word_count;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS average_sentence_length;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS sentence_count;
@@ -0,0 +1,9 @@
+-- Statistics beyond the paragraph, word and character counts.
+-- Results stored before keep version 0 and are analyzed again on the next request.
+ALTER TABLE analysis_results ADD COLUMN sentence_count INT NOT NULL DEFAULT 0;
+ALTER TABLE analysis_results ADD COLUMN average_sentence_length DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE analysis_results ADD COLUMN unique_word_count INT NOT NULL DEFAULT 0;
+ALTER TABLE analysis_results ADD COLUMN lexical_density DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE analysis_results ADD COLUMN top_terms JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE analysis_results ADD COLUMN readability DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE analysis_results ADD COLUMN stats_version INT NOT NULL DEFAULT 0;
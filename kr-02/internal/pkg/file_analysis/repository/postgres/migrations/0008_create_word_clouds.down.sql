@@ -0,0 +1,2 @@
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS word_cloud_options;
+DROP TABLE IF EXISTS word_clouds;
@@ -0,0 +1,18 @@
+-- Every rendering of the word cloud of a file, by the key of the options it was rendered with,
+-- so the same file can have word clouds of several sizes, formats and color schemes
+CREATE TABLE IF NOT EXISTS word_clouds (
+    file_id TEXT NOT NULL,
+    options_key TEXT NOT NULL,
+    location TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, options_key)
+);
+
+-- Word clouds rendered before keep an options key no rendering has, so they are never reused,
+-- but are still deleted with their file
+INSERT INTO word_clouds (file_id, options_key, location)
+SELECT file_id, 'legacy', word_cloud_location FROM analysis_results
+WHERE word_cloud_location IS NOT NULL AND word_cloud_location <> '';
+
+-- The word cloud options an analysis job renders with
+ALTER TABLE analysis_jobs ADD COLUMN word_cloud_options JSONB NOT NULL DEFAULT '{}';
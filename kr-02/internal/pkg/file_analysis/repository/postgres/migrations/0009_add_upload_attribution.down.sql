@@ -0,0 +1,5 @@
+ALTER TABLE analysis_results ADD COLUMN is_plagiarism BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE analysis_results a SET is_plagiarism = EXISTS (
+    SELECT 1 FROM similar_files s WHERE s.file_id = a.file_id
+);
+DROP TABLE IF EXISTS stored_files;
@@ -0,0 +1,16 @@
+-- Upload times of the files in the File Storing Service, so analyses compare against every stored file,
+-- analyzed or not, and tell which of two similar files was uploaded first
+CREATE TABLE IF NOT EXISTS stored_files (
+    file_id TEXT PRIMARY KEY,
+    uploaded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX stored_files_uploaded_at_idx ON stored_files (uploaded_at);
+
+-- Similarity is recorded in both directions, so earlier files learn about their copies
+INSERT INTO similar_files (file_id, similar_file_id, similarity)
+SELECT similar_file_id, file_id, similarity FROM similar_files
+ON CONFLICT (file_id, similar_file_id) DO NOTHING;
+
+-- Whether a file copies another one depends on the upload times and is computed when results are read
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS is_plagiarism;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS analysis_batch_entries;
+DROP TABLE IF EXISTS analysis_batches;
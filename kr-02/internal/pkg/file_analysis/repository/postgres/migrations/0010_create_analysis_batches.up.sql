@@ -0,0 +1,18 @@
+-- Batches of files analyzed together, such as the reports of an assignment uploaded as one archive
+CREATE TABLE IF NOT EXISTS analysis_batches (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Entries of a batch in archive order. Entries the File Storing Service rejected have an error
+-- instead of a file and a job.
+CREATE TABLE IF NOT EXISTS analysis_batch_entries (
+    batch_id TEXT NOT NULL REFERENCES analysis_batches (id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    file_name TEXT NOT NULL,
+    file_id TEXT NOT NULL DEFAULT '',
+    job_id TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (batch_id, position)
+);
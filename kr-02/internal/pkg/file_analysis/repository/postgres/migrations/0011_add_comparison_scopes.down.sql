@@ -0,0 +1,5 @@
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS scope;
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS scope;
+DROP INDEX IF EXISTS stored_files_course_idx;
+ALTER TABLE stored_files DROP COLUMN IF EXISTS assignment;
+ALTER TABLE stored_files DROP COLUMN IF EXISTS course;
@@ -0,0 +1,11 @@
+-- Course and assignment of the stored files, so analyses can compare within them.
+-- Files recorded before are recorded again with their tags by the next sync with the File Storing Service.
+ALTER TABLE stored_files ADD COLUMN course TEXT NOT NULL DEFAULT '';
+ALTER TABLE stored_files ADD COLUMN assignment TEXT NOT NULL DEFAULT '';
+DELETE FROM stored_files;
+
+CREATE INDEX stored_files_course_idx ON stored_files (course, assignment);
+
+-- The files an analysis result and an analysis job compare with; everything before compared with all files
+ALTER TABLE analysis_results ADD COLUMN scope TEXT NOT NULL DEFAULT 'global';
+ALTER TABLE analysis_jobs ADD COLUMN scope TEXT NOT NULL DEFAULT 'global';
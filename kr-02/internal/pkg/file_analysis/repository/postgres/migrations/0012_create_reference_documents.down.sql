@@ -0,0 +1,2 @@
+ALTER TABLE analysis_results DROP COLUMN IF EXISTS reference_version;
+DROP TABLE IF EXISTS reference_documents;
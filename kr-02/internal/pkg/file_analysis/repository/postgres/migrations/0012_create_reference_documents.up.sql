@@ -0,0 +1,15 @@
+-- Texts the files of an assignment are allowed to share, such as its statement or template,
+-- whose n-grams are ignored when the files are compared
+CREATE TABLE IF NOT EXISTS reference_documents (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    course TEXT NOT NULL DEFAULT '',
+    assignment TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS reference_documents_course_idx ON reference_documents (course, assignment);
+
+-- The reference documents an analysis result ignored; everything before ignored none
+ALTER TABLE analysis_results ADD COLUMN reference_version TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- Files whose content failed to be fetched to compute their fingerprints,
+-- which fingerprint rebuilds skip until retry_after
+CREATE TABLE IF NOT EXISTS fingerprint_failures (
+    file_id TEXT PRIMARY KEY,
+    error TEXT NOT NULL,
+    retry_after TIMESTAMP NOT NULL
+);
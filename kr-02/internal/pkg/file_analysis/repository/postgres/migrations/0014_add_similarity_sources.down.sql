@@ -0,0 +1,14 @@
+-- Keep the highest similarity of each pair
+DELETE FROM similar_files sf WHERE EXISTS (
+    SELECT 1 FROM similar_files o
+    WHERE o.file_id = sf.file_id AND o.similar_file_id = sf.similar_file_id
+        AND (COALESCE(o.similarity, 0), o.source_file_id, o.detector, o.scope)
+            > (COALESCE(sf.similarity, 0), sf.source_file_id, sf.detector, sf.scope)
+);
+
+DROP INDEX IF EXISTS similar_files_file_id_idx;
+ALTER TABLE similar_files DROP CONSTRAINT similar_files_pkey;
+ALTER TABLE similar_files DROP COLUMN scope;
+ALTER TABLE similar_files DROP COLUMN detector;
+ALTER TABLE similar_files DROP COLUMN source_file_id;
+ALTER TABLE similar_files ADD CONSTRAINT similar_files_pkey PRIMARY KEY (file_id, similar_file_id);
@@ -0,0 +1,25 @@
+-- The analysis that found each similarity record: the analyzed file, its detector and the scope it compared in,
+-- so analyses replace only the records they found. Records found before are attributed to the latest analysis
+-- of either file, since saving an analysis replaced every record of its file.
+ALTER TABLE similar_files ADD COLUMN source_file_id TEXT;
+ALTER TABLE similar_files ADD COLUMN detector TEXT;
+ALTER TABLE similar_files ADD COLUMN scope TEXT;
+
+UPDATE similar_files sf SET source_file_id = a.file_id, detector = a.detector, scope = a.scope
+FROM analysis_results a
+WHERE a.file_id = (
+    SELECT l.file_id FROM analysis_results l
+    WHERE l.file_id IN (sf.file_id, sf.similar_file_id)
+    ORDER BY l.created_at DESC, l.file_id
+    LIMIT 1
+);
+DELETE FROM similar_files WHERE source_file_id IS NULL;
+
+ALTER TABLE similar_files ALTER COLUMN source_file_id SET NOT NULL;
+ALTER TABLE similar_files ALTER COLUMN detector SET NOT NULL;
+ALTER TABLE similar_files ALTER COLUMN scope SET NOT NULL;
+ALTER TABLE similar_files DROP CONSTRAINT similar_files_pkey;
+ALTER TABLE similar_files ADD CONSTRAINT similar_files_pkey
+    PRIMARY KEY (source_file_id, detector, scope, file_id, similar_file_id);
+
+CREATE INDEX similar_files_file_id_idx ON similar_files (file_id, similar_file_id);
@@ -0,0 +1,15 @@
+-- Keep the oldest unfinished job of each file
+UPDATE analysis_jobs j SET
+    status = 'failed',
+    error = 'analysis was canceled by a schema downgrade',
+    finished_at = CURRENT_TIMESTAMP,
+    lease_expires_at = NULL
+WHERE status IN ('queued', 'running') AND EXISTS (
+    SELECT 1 FROM analysis_jobs o
+    WHERE o.file_id = j.file_id AND o.status IN ('queued', 'running')
+        AND (o.created_at, o.id) < (j.created_at, j.id)
+);
+
+DROP INDEX IF EXISTS analysis_jobs_active_file_idx;
+CREATE UNIQUE INDEX analysis_jobs_active_file_idx ON analysis_jobs (file_id)
+    WHERE status IN ('queued', 'running');
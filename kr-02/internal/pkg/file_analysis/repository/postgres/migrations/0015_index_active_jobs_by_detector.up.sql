@@ -0,0 +1,5 @@
+-- At most one unfinished job per file, detector and scope, so repeated submissions share it
+-- while submissions with another detector or scope are analyzed too
+DROP INDEX IF EXISTS analysis_jobs_active_file_idx;
+CREATE UNIQUE INDEX analysis_jobs_active_file_idx ON analysis_jobs (file_id, detector, scope)
+    WHERE status IN ('queued', 'running');
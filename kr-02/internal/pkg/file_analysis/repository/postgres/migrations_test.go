@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"testing"
+
+	"kr-02/internal/pkg/migrate"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := migrate.Load(Migrations())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// SaveReferenceDocument saves a reference document
+func (r *AnalysisRepo) SaveReferenceDocument(ctx context.Context, document repository.ReferenceDocument) error {
+	query := `
+		INSERT INTO reference_documents (id, name, course, assignment, content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.ExecContext(ctx, query, document.ID, document.Name, document.Tags.Course, document.Tags.Assignment,
+		document.Content, document.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save reference document: %w", err)
+	}
+	return nil
+}
+
+// GetReferenceDocuments retrieves the reference documents matching the tags, oldest first.
+// Empty tags match documents with any tag.
+func (r *AnalysisRepo) GetReferenceDocuments(ctx context.Context, tags repository.FileTags) ([]repository.ReferenceDocument, error) {
+	query := `
+		SELECT id, name, course, assignment, content, created_at FROM reference_documents
+		WHERE ($1 = '' OR course = $1) AND ($2 = '' OR assignment = $2)
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.QueryContext(ctx, query, tags.Course, tags.Assignment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reference documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []repository.ReferenceDocument
+	for rows.Next() {
+		var document repository.ReferenceDocument
+		err := rows.Scan(&document.ID, &document.Name, &document.Tags.Course, &document.Tags.Assignment,
+			&document.Content, &document.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reference document: %w", err)
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over reference documents: %w", err)
+	}
+
+	return documents, nil
+}
+
+// DeleteReferenceDocument deletes a reference document by ID
+func (r *AnalysisRepo) DeleteReferenceDocument(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM reference_documents WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete reference document: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete reference document: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", repository.ErrReferenceNotFound, id)
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"kr-02/internal/pkg/file_analysis/analyzer"
 	"kr-02/internal/pkg/file_analysis/clients"
@@ -13,138 +17,410 @@ import (
 // AnalysisService handles the business logic for file analysis operations
 type AnalysisService struct {
 	repo               repository.AnalysisRepository
+	jobs               repository.JobRepository
 	storage            storage.WordCloudStorage
-	fileStoringClient  *clients.FileStoringClient
+	fileStoringClient  clients.FileStore
 	textAnalyzer       *analyzer.TextAnalyzer
 	plagiarismChecker  *analyzer.PlagiarismChecker
-	wordCloudGenerator *analyzer.WordCloudGenerator
+	detectors          *analyzer.Detectors
+	wordCloudGenerator analyzer.WordCloudRenderer
+	comparison         ComparisonConfig
+	jobSubmitted       chan struct{}
 }
 
 // NewAnalysisService creates a new AnalysisService instance
 func NewAnalysisService(
 	repo repository.AnalysisRepository,
+	jobs repository.JobRepository,
 	storage storage.WordCloudStorage,
-	fileStoringClient *clients.FileStoringClient,
+	fileStoringClient clients.FileStore,
 	textAnalyzer *analyzer.TextAnalyzer,
 	plagiarismChecker *analyzer.PlagiarismChecker,
-	wordCloudGenerator *analyzer.WordCloudGenerator,
+	detectors *analyzer.Detectors,
+	wordCloudGenerator analyzer.WordCloudRenderer,
+	comparison ComparisonConfig,
 ) *AnalysisService {
 	return &AnalysisService{
 		repo:               repo,
+		jobs:               jobs,
 		storage:            storage,
 		fileStoringClient:  fileStoringClient,
 		textAnalyzer:       textAnalyzer,
 		plagiarismChecker:  plagiarismChecker,
+		detectors:          detectors,
 		wordCloudGenerator: wordCloudGenerator,
+		comparison:         comparison,
+		jobSubmitted:       make(chan struct{}, 1),
 	}
 }
 
-// AnalyzeFile analyzes a file and returns the analysis results
-func (s *AnalysisService) AnalyzeFile(ctx context.Context, fileID string, generateWordCloud bool) (
-	paragraphCount, wordCount, characterCount int32,
-	isPlagiarism bool,
-	similarFileIDs []string,
-	wordCloudLocation string,
-	err error,
-) {
-	// Try to get existing analysis results
-	paragraphCount, wordCount, characterCount, isPlagiarism, wordCloudLocation, err = s.repo.GetAnalysisResult(ctx, fileID)
-	if err == nil {
-		// Analysis results exist, get similar file IDs if it's plagiarism
-		if isPlagiarism {
-			similarFileIDs, err = s.repo.GetSimilarFiles(ctx, fileID)
-			if err != nil {
-				return 0, 0, 0, false, nil, "", fmt.Errorf("failed to get similar files: %w", err)
+// Analysis is the stored analysis of a file with its similar files and which of them was uploaded first
+type Analysis struct {
+	repository.AnalysisResult
+	IsPlagiarism   bool          // Set if a similar file was uploaded before the file, or the order is not known
+	SimilarFiles   []SimilarFile // Most similar first
+	SimilarFileIDs []string      // IDs of SimilarFiles
+	OriginalFileID string        // File uploaded first among the file and its similar files, if known
+}
+
+// AnalyzeFile analyzes a file and returns the analysis results.
+// Similar files are looked for with the named detector, or with the default detector if the name is empty,
+// among the files in the scope, or in the default scope if the scope is empty.
+// A word cloud is rendered with the options unless the file already has one rendered with the same options.
+func (s *AnalysisService) AnalyzeFile(
+	ctx context.Context,
+	fileID string,
+	generateWordCloud bool,
+	wordCloudOptions analyzer.WordCloudOptions,
+	detector string,
+	scope repository.ComparisonScope,
+) (Analysis, error) {
+	return s.analyzeFile(ctx, fileID, generateWordCloud, wordCloudOptions, detector, scope, func(int) {})
+}
+
+// GetAnalysisResult retrieves the stored analysis results of a file without analyzing it
+func (s *AnalysisService) GetAnalysisResult(ctx context.Context, fileID string) (Analysis, error) {
+	result, err := s.repo.GetAnalysisResult(ctx, fileID)
+	if err != nil {
+		return Analysis{}, err
+	}
+	return s.withSimilarFiles(ctx, result)
+}
+
+// withSimilarFiles retrieves the similar files of a stored analysis result and returns them with the result,
+// attributed by their upload times. Similar files found by analyses of other files are included
+// if they are in the scope of the result.
+func (s *AnalysisService) withSimilarFiles(ctx context.Context, result repository.AnalysisResult) (Analysis, error) {
+	similarFiles, err := s.repo.GetSimilarFileScores(ctx, result.FileID)
+	if err != nil {
+		return Analysis{}, fmt.Errorf("failed to get similar files: %w", err)
+	}
+	similarFiles = slices.DeleteFunc(similarFiles, func(similarFile repository.SimilarFile) bool {
+		return !inScope(sharedScope(result.Tags, similarFile.Tags), result.Scope)
+	})
+
+	analysis := Analysis{
+		AnalysisResult: result,
+		SimilarFiles:   attribute(result.FileID, result.UploadedAt, similarFiles),
+	}
+	for _, similarFile := range similarFiles {
+		analysis.SimilarFileIDs = append(analysis.SimilarFileIDs, similarFile.FileID)
+	}
+	analysis.IsPlagiarism = isCopy(analysis.SimilarFiles)
+	analysis.OriginalFileID = originalFileID(result.FileID, result.UploadedAt, analysis.SimilarFiles)
+	return analysis, nil
+}
+
+// analyzeFile analyzes a file with the named detector in the scope and reports the progress in percent after every step
+func (s *AnalysisService) analyzeFile(
+	ctx context.Context,
+	fileID string,
+	generateWordCloud bool,
+	wordCloudOptions analyzer.WordCloudOptions,
+	detectorName string,
+	requestedScope repository.ComparisonScope,
+	reportProgress func(percent int),
+) (Analysis, error) {
+	detector, err := s.detectors.Get(detectorName)
+	if err != nil {
+		return Analysis{}, err
+	}
+	scope, err := s.comparison.scope(requestedScope)
+	if err != nil {
+		return Analysis{}, err
+	}
+	if err := wordCloudOptions.Validate(); err != nil {
+		return Analysis{}, err
+	}
+
+	// Try to get existing analysis results of the same detector, scope, statistics version and reference documents
+	result, err := s.repo.GetAnalysisResult(ctx, fileID)
+	if err == nil && result.Detector == detector.Name() && result.Scope == scope && result.StatsVersion == analyzer.TextStatsVersion &&
+		s.referencesUnchanged(ctx, result) {
+		if generateWordCloud {
+			if location := s.wordCloud(ctx, fileID, nil, wordCloudOptions); location != "" {
+				if location != result.WordCloudLocation {
+					if err := s.repo.UpdateWordCloudLocation(ctx, fileID, location); err != nil {
+						// Log the error but continue; the word cloud is found by its options on the next request
+						fmt.Printf("Failed to update word cloud of file %s: %v\n", fileID, err)
+					}
+				}
+				result.WordCloudLocation = location
 			}
 		}
-		return paragraphCount, wordCount, characterCount, isPlagiarism, similarFileIDs, wordCloudLocation, nil
+		return s.withSimilarFiles(ctx, result)
 	}
 
 	// Get file content from File Storing Service
 	_, content, err := s.fileStoringClient.GetFile(ctx, fileID)
 	if err != nil {
-		return 0, 0, 0, false, nil, "", fmt.Errorf("failed to get file content: %w", err)
+		return Analysis{}, fmt.Errorf("failed to get file content: %w", err)
 	}
+	reportProgress(progressFileFetched)
 
 	// Convert content to string
 	contentStr := string(content)
 
 	// Analyze text
-	paragraphCount, wordCount, characterCount = s.textAnalyzer.AnalyzeText(contentStr)
+	stats := s.textAnalyzer.AnalyzeText(contentStr)
+
+	// Fingerprint the file with every detector and keep the fingerprints, so later analyses can use any of them
+	var fingerprint repository.Fingerprint
+	for _, d := range s.detectors.All() {
+		f := s.fingerprint(d, contentStr)
+		if d.Name() == detector.Name() {
+			fingerprint = f
+		}
+		if err := s.repo.SaveFingerprint(ctx, fileID, f); err != nil {
+			// Log the error but continue; the fingerprint is computed again by the next analysis
+			fmt.Printf("Failed to save %s fingerprint of file %s: %v\n", f.Detector, fileID, err)
+		}
+	}
 
 	// Check for plagiarism
-	// First, get all other file IDs
-	otherFileIDs, err := s.repo.GetAllFileIDs(ctx)
+	// First, look up the stored fingerprints of files likely to be similar in the LSH index,
+	// or by the candidate keys of detectors that index their own
+	candidates, err := s.repo.FindCandidateFingerprints(ctx, detector.Name(), fingerprint.Version, fingerprint.BandKeys)
 	if err != nil {
-		return 0, 0, 0, false, nil, "", fmt.Errorf("failed to get all file IDs: %w", err)
+		return Analysis{}, fmt.Errorf("failed to find candidate files: %w", err)
 	}
+	reportProgress(progressCandidatesFound)
 
-	// Get content of all other files
-	otherContents := make(map[string]string)
-	for _, otherFileID := range otherFileIDs {
+	// Then keep the candidates in the scope, each held to the threshold of the narrowest scope it shares with the file
+	storedFiles, err := s.repo.GetStoredFiles(ctx, append(slices.Collect(maps.Keys(candidates)), fileID))
+	if err != nil {
+		return Analysis{}, fmt.Errorf("failed to get tags of candidate files: %w", err)
+	}
+	if _, ok := storedFiles[fileID]; !ok {
+		// The file was uploaded since the last sync, so record it to learn its tags
+		if err := s.SyncStoredFiles(ctx); err != nil {
+			// Log the error but continue; the file is compared as a file without tags
+			fmt.Printf("Failed to sync stored files: %v\n", err)
+		} else if storedFiles, err = s.repo.GetStoredFiles(ctx, append(slices.Collect(maps.Keys(candidates)), fileID)); err != nil {
+			return Analysis{}, fmt.Errorf("failed to get tags of candidate files: %w", err)
+		}
+	}
+	// Text the files of the assignment are allowed to share, such as its statement, is left out of the comparison
+	references, err := s.referenceDocuments(ctx, storedFiles[fileID].Tags)
+	if err != nil {
+		return Analysis{}, err
+	}
+	reference := referenceNGrams(detector, references)
+	otherFingerprints := make(map[string]analyzer.Fingerprint, len(candidates))
+	thresholds := make(map[string]float64, len(candidates))
+	for otherFileID, candidate := range candidates {
 		if otherFileID == fileID {
 			continue // Skip the current file
 		}
-
-		_, otherContent, err := s.fileStoringClient.GetFile(ctx, otherFileID)
-		if err != nil {
-			// Log the error but continue with other files
-			fmt.Printf("Failed to get content for file %s: %v\n", otherFileID, err)
+		shared := sharedScope(storedFiles[fileID].Tags, storedFiles[otherFileID].Tags)
+		if !inScope(shared, scope) {
 			continue
 		}
-
-		otherContents[otherFileID] = string(otherContent)
+		otherFingerprints[otherFileID] = analyzer.Fingerprint{ContentHash: candidate.ContentHash, NGrams: candidate.NGrams}.
+			WithoutReference(reference)
+		thresholds[otherFileID] = s.comparison.threshold(detector, shared)
 	}
 
-	// Check for plagiarism
-	isPlagiarism, similarFileIDs = s.plagiarismChecker.CheckPlagiarism(ctx, contentStr, otherContents)
+	// Compare with the candidates
+	similarFiles := analyzer.ScoreFingerprintsWithThresholds(
+		detector,
+		analyzer.Fingerprint{ContentHash: fingerprint.ContentHash, NGrams: fingerprint.NGrams}.WithoutReference(reference),
+		otherFingerprints,
+		thresholds,
+	)
+	reportProgress(progressPlagiarismChecked)
 
 	// Generate word cloud if requested
+	var wordCloudLocation string
 	if generateWordCloud {
-		var text []byte
-		_, text, err = s.fileStoringClient.GetFile(ctx, fileID)
+		wordCloudLocation = s.wordCloud(ctx, fileID, content, wordCloudOptions)
+	}
 
-		if err != nil {
-			return 0, 0, 0, false, nil, "", fmt.Errorf("failed to get file content: %w", err)
+	reportProgress(progressWordCloudGenerated)
+
+	// Save analysis results
+	result = repository.AnalysisResult{
+		FileID:                fileID,
+		ParagraphCount:        stats.ParagraphCount,
+		WordCount:             stats.WordCount,
+		CharacterCount:        stats.CharacterCount,
+		SentenceCount:         stats.SentenceCount,
+		AverageSentenceLength: stats.AverageSentenceLength,
+		UniqueWordCount:       stats.UniqueWordCount,
+		LexicalDensity:        stats.LexicalDensity,
+		Readability:           stats.Readability,
+		StatsVersion:          analyzer.TextStatsVersion,
+		WordCloudLocation:     wordCloudLocation,
+		Detector:              detector.Name(),
+		Scope:                 scope,
+		References:            referenceVersion(references),
+	}
+	for _, term := range stats.TopTerms {
+		result.TopTerms = append(result.TopTerms, repository.TermCount{Term: term.Term, Count: term.Count})
+	}
+	found := make([]repository.SimilarFile, len(similarFiles))
+	for i, similarFile := range similarFiles {
+		found[i] = repository.SimilarFile{FileID: similarFile.FileID, Similarity: similarFile.Similarity}
+	}
+	err = s.repo.SaveAnalysisResult(ctx, result, found)
+	if err != nil {
+		return Analysis{}, fmt.Errorf("failed to save analysis results: %w", err)
+	}
+
+	// Read the results back with the upload times of the file and its similar files
+	if result, err = s.repo.GetAnalysisResult(ctx, fileID); err != nil {
+		return Analysis{}, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+	return s.withSimilarFiles(ctx, result)
+}
+
+// wordCloud returns the location of the word cloud of a file rendered with the options, rendering and saving it
+// unless the file already has one rendered with the same options. The content is fetched if it is nil.
+// Errors are logged, and the location is empty then, so analyses continue without a word cloud.
+func (s *AnalysisService) wordCloud(ctx context.Context, fileID string, content []byte, options analyzer.WordCloudOptions) string {
+	optionsKey, err := s.wordCloudGenerator.CacheKey(options)
+	if err != nil {
+		fmt.Printf("Failed to generate word cloud: %v\n", err)
+		return ""
+	}
+
+	// Reuse the word cloud rendered with the same options before
+	location, err := s.repo.GetWordCloud(ctx, fileID, optionsKey)
+	if err == nil {
+		return location
+	}
+	if !errors.Is(err, repository.ErrWordCloudNotFound) {
+		fmt.Printf("Failed to get word cloud: %v\n", err)
+		return ""
+	}
+
+	if content == nil {
+		if _, content, err = s.fileStoringClient.GetFile(ctx, fileID); err != nil {
+			fmt.Printf("Failed to get file content: %v\n", err)
+			return ""
 		}
+	}
 
-		// Generate word cloud
-		wordCloudImage, location, err := s.wordCloudGenerator.GenerateWordCloud(ctx, string(text))
+	// Generate word cloud
+	wordCloudImage, location, err := s.wordCloudGenerator.GenerateWordCloud(ctx, string(content), options)
+	if err != nil {
+		fmt.Printf("Failed to generate word cloud: %v\n", err)
+		return ""
+	}
+
+	// Save word cloud image
+	if err := s.storage.SaveWordCloud(ctx, location, wordCloudImage); err != nil {
+		fmt.Printf("Failed to save word cloud: %v\n", err)
+		return ""
+	}
+	if err := s.repo.SaveWordCloud(ctx, fileID, optionsKey, location); err != nil {
+		fmt.Printf("Failed to save word cloud: %v\n", err)
+		return ""
+	}
+	return location
+}
+
+// fingerprintRetryDelay is how long fingerprint rebuilds skip a file whose content failed to be fetched,
+// such as while the File Storing Service is unavailable
+const fingerprintRetryDelay = time.Hour
+
+// RebuildFingerprints computes the fingerprints of analyzed and stored files that have none with the current version
+// of a detector, such as files analyzed before the n-gram size or stop words changed or files never analyzed,
+// and returns for how many files fingerprints were computed. Files deleted from the File Storing Service are withdrawn
+func (s *AnalysisService) RebuildFingerprints(ctx context.Context) (int, error) {
+	var fileIDs []string
+	staleDetectors := make(map[string][]analyzer.Detector)
+	for _, detector := range s.detectors.All() {
+		staleFileIDs, err := s.repo.GetFileIDsWithStaleFingerprint(ctx, detector.Name(), s.fingerprintVersion(detector))
 		if err != nil {
-			// Log the error but continue without word cloud
-			fmt.Printf("Failed to generate word cloud: %v\n", err)
-		} else {
-			// Save word cloud image
-			err = s.storage.SaveWordCloud(ctx, location, wordCloudImage)
-			if err != nil {
-				fmt.Printf("Failed to save word cloud: %v\n", err)
-			} else {
-				wordCloudLocation = location
+			return 0, fmt.Errorf("failed to get files with stale fingerprints: %w", err)
+		}
+		for _, fileID := range staleFileIDs {
+			if staleDetectors[fileID] == nil {
+				fileIDs = append(fileIDs, fileID)
 			}
+			staleDetectors[fileID] = append(staleDetectors[fileID], detector)
 		}
 	}
 
-	// Save analysis results
-	err = s.repo.SaveAnalysisResult(ctx, fileID, paragraphCount, wordCount, characterCount, isPlagiarism, wordCloudLocation)
-	if err != nil {
-		return 0, 0, 0, false, nil, "", fmt.Errorf("failed to save analysis results: %w", err)
-	}
+	rebuilt := 0
+	for _, fileID := range fileIDs {
+		_, content, err := s.fileStoringClient.GetFile(ctx, fileID)
+		if errors.Is(err, clients.ErrFileNotFound) {
+			// Recorded again by a sync that listed the file before it was deleted
+			if err := s.DeleteAnalysis(ctx, fileID); err != nil {
+				fmt.Printf("Failed to delete analysis of deleted file %s: %v\n", fileID, err)
+			}
+			continue
+		}
+		if err != nil {
+			// Log the error but continue with other files; the file is skipped by rebuilds until it is retried
+			fmt.Printf("Failed to get content for file %s: %v\n", fileID, err)
+			if err := s.repo.SaveFingerprintFailure(ctx, fileID, err.Error(), time.Now().Add(fingerprintRetryDelay)); err != nil {
+				fmt.Printf("Failed to record fingerprint failure of file %s: %v\n", fileID, err)
+			}
+			continue
+		}
 
-	// Save similar files if plagiarism is detected
-	if isPlagiarism {
-		for _, similarFileID := range similarFileIDs {
-			err = s.repo.SaveSimilarFile(ctx, fileID, similarFileID)
-			if err != nil {
-				// Log the error but continue with other similar files
-				fmt.Printf("Failed to save similar file %s: %v\n", similarFileID, err)
+		for _, detector := range staleDetectors[fileID] {
+			if err := s.repo.SaveFingerprint(ctx, fileID, s.fingerprint(detector, string(content))); err != nil {
+				return rebuilt, err
 			}
 		}
+		rebuilt++
+	}
+
+	return rebuilt, nil
+}
+
+// fingerprint computes the fingerprint of a content with a detector, with its MinHash signature and the keys
+// its candidates are looked up by: the LSH band keys of the signature, or the candidate keys of the detector
+func (s *AnalysisService) fingerprint(detector analyzer.Detector, content string) repository.Fingerprint {
+	fingerprint := detector.Fingerprint(content)
+	signature := s.plagiarismChecker.Signature(fingerprint)
+	bandKeys := s.plagiarismChecker.BandKeys(signature)
+	if indexer, ok := detector.(analyzer.CandidateIndexer); ok {
+		bandKeys = indexer.CandidateKeys(fingerprint)
 	}
+	return repository.Fingerprint{
+		Detector:    detector.Name(),
+		Version:     s.fingerprintVersion(detector),
+		ContentHash: fingerprint.ContentHash,
+		NGrams:      fingerprint.NGrams,
+		Signature:   signature,
+		BandKeys:    bandKeys,
+	}
+}
 
-	return paragraphCount, wordCount, characterCount, isPlagiarism, similarFileIDs, wordCloudLocation, nil
+// fingerprintVersion identifies the settings the fingerprints of a detector, their signatures and the keys
+// their candidates are looked up by are computed with
+func (s *AnalysisService) fingerprintVersion(detector analyzer.Detector) string {
+	minHash := s.plagiarismChecker.MinHash
+	version := fmt.Sprintf("%s-h%d-r%d", detector.Version(), minHash.NumHashes, minHash.RowsPerBand)
+	if _, ok := detector.(analyzer.CandidateIndexer); ok {
+		version += "-keyed"
+	}
+	return version
 }
 
 // GetWordCloud retrieves a word cloud image by its location
 func (s *AnalysisService) GetWordCloud(ctx context.Context, location string) ([]byte, error) {
 	return s.storage.GetWordCloud(ctx, location)
 }
+
+// DeleteAnalysis deletes analysis results, similarity records and the word clouds of a file.
+// Deleting a file that has not been analyzed is not an error.
+func (s *AnalysisService) DeleteAnalysis(ctx context.Context, fileID string) error {
+	wordCloudLocations, err := s.repo.DeleteAnalysisResult(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to delete analysis results: %w", err)
+	}
+
+	for _, location := range wordCloudLocations {
+		if err := s.storage.DeleteWordCloud(ctx, location); err != nil {
+			return fmt.Errorf("failed to delete word cloud: %w", err)
+		}
+	}
+
+	return nil
+}
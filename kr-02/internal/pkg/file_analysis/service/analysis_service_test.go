@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// Test that a word cloud rendered with the same options before is reused instead of rendered again
+func TestAnalysisService_AnalyzeFileReusesWordCloud(t *testing.T) {
+	ctx := context.Background()
+	renderer, err := analyzer.NewLocalWordCloudRenderer(analyzer.NewTextAnalyzer())
+	if err != nil {
+		t.Fatalf("NewLocalWordCloudRenderer() error = %v", err)
+	}
+
+	options := analyzer.WordCloudOptions{Width: 600, Height: 400, Format: analyzer.WordCloudSVG, ColorScheme: "cool"}
+	optionsKey, err := renderer.CacheKey(options)
+	if err != nil {
+		t.Fatalf("CacheKey() error = %v", err)
+	}
+	repo := &fakeAnalysisRepo{
+		results: map[string]repository.AnalysisResult{
+			"file-1": {FileID: "file-1", WordCount: 42, StatsVersion: analyzer.TextStatsVersion, WordCloudLocation: "default.png", Detector: "jaccard", Scope: repository.ScopeGlobal},
+		},
+		wordClouds: map[string]string{"file-1/" + optionsKey: "cool.svg"},
+	}
+	s := newTestService(t, repo, newFakeJobRepo())
+	s.wordCloudGenerator = renderer
+
+	// Neither the file nor the renderer is needed, since both the results and the word cloud are stored
+	analysis, err := s.AnalyzeFile(ctx, "file-1", true, options, "", "")
+	if err != nil {
+		t.Fatalf("AnalyzeFile() error = %v", err)
+	}
+	if analysis.WordCloudLocation != "cool.svg" {
+		t.Errorf("AnalyzeFile() word cloud location = %q, want the stored %q", analysis.WordCloudLocation, "cool.svg")
+	}
+	if location := repo.results["file-1"].WordCloudLocation; location != "cool.svg" {
+		t.Errorf("stored word cloud location = %q, want %q", location, "cool.svg")
+	}
+
+	invalid := analyzer.WordCloudOptions{Width: 1}
+	if _, err := s.AnalyzeFile(ctx, "file-1", true, invalid, "", ""); !errors.Is(err, analyzer.ErrInvalidWordCloudOptions) {
+		t.Errorf("AnalyzeFile() with invalid word cloud options error = %v, want ErrInvalidWordCloudOptions", err)
+	}
+}
+
+// readCorpus reads the sample texts of the repository in name order
+func readCorpus(t *testing.T) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("..", "..", "..", "..", "texts", "*.txt"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("failed to find the texts corpus: %v", err)
+	}
+
+	texts := make([]string, len(paths))
+	for i, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		texts[i] = string(content)
+	}
+	return texts
+}
+
+// Test that the winnowing detector finds a short text copied into a file much longer than it,
+// whose MinHash signature estimates a Jaccard similarity too low to share an LSH band with the short text
+func TestAnalysisService_AnalyzeFileFindsEmbeddedText(t *testing.T) {
+	ctx := context.Background()
+	texts := readCorpus(t)
+	files := fakeFileStore{"corpus": strings.Join(texts, "\n\n")}
+	for i, text := range texts {
+		files[fmt.Sprintf("text-%02d", i)] = text
+	}
+
+	repo := &fakeAnalysisRepo{results: make(map[string]repository.AnalysisResult)}
+	s := newTestService(t, repo, newFakeJobRepo())
+	s.fileStoringClient = files
+
+	if _, err := s.AnalyzeFile(ctx, "corpus", false, analyzer.WordCloudOptions{}, "winnowing", ""); err != nil {
+		t.Fatalf("AnalyzeFile() of the corpus error = %v", err)
+	}
+	for i := range texts {
+		fileID := fmt.Sprintf("text-%02d", i)
+		analysis, err := s.AnalyzeFile(ctx, fileID, false, analyzer.WordCloudOptions{}, "winnowing", "")
+		if err != nil {
+			t.Fatalf("AnalyzeFile() of %s error = %v", fileID, err)
+		}
+		i := slices.IndexFunc(analysis.SimilarFiles, func(similarFile SimilarFile) bool { return similarFile.FileID == "corpus" })
+		if i < 0 || analysis.SimilarFiles[i].Similarity != 1 {
+			t.Errorf("AnalyzeFile() of %s similar files = %+v, want the corpus with similarity 1", fileID, analysis.SimilarFiles)
+		}
+	}
+}
+
+// Test that stored files are fingerprinted once, and a file whose content fails to be fetched
+// is not fetched again by every rebuild, while a deleted file is withdrawn
+func TestAnalysisService_RebuildFingerprints(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeAnalysisRepo{tags: map[string]repository.FileTags{"file-1": {}, "deleted": {}, "unavailable": {}}}
+	s := newTestService(t, repo, newFakeJobRepo())
+	s.fileStoringClient = unavailableFileStore{
+		fakeFileStore: fakeFileStore{"file-1": "Сервис анализа файлов сравнивает работы студентов"},
+		fileID:        "unavailable",
+	}
+
+	if rebuilt, err := s.RebuildFingerprints(ctx); err != nil || rebuilt != 1 {
+		t.Errorf("RebuildFingerprints() = %d, %v, want 1", rebuilt, err)
+	}
+	if len(repo.fingerprints) != 2 {
+		t.Errorf("RebuildFingerprints() saved %d fingerprints, want one per detector of file-1", len(repo.fingerprints))
+	}
+	if retryAfter := repo.failures["unavailable"]; !retryAfter.After(time.Now()) {
+		t.Errorf("RebuildFingerprints() retries the unavailable file at %v, want later", retryAfter)
+	}
+	if _, ok := repo.tags["deleted"]; ok {
+		t.Error("RebuildFingerprints() kept the deleted file as stored")
+	}
+
+	if rebuilt, err := s.RebuildFingerprints(ctx); err != nil || rebuilt != 0 {
+		t.Errorf("RebuildFingerprints() again = %d, %v, want 0", rebuilt, err)
+	}
+	for _, detector := range s.detectors.All() {
+		stale, err := repo.GetFileIDsWithStaleFingerprint(ctx, detector.Name(), s.fingerprintVersion(detector))
+		if err != nil || len(stale) != 0 {
+			t.Errorf("%s stale fingerprints after rebuilding = %v, %v, want none", detector.Name(), stale, err)
+		}
+	}
+}
+
+// unavailableFileStore is a fakeFileStore that fails to return one of the files
+type unavailableFileStore struct {
+	fakeFileStore
+	fileID string
+}
+
+func (f unavailableFileStore) GetFile(ctx context.Context, fileID string) (string, []byte, error) {
+	if fileID == f.fileID {
+		return "", nil, errors.New("file storing service is unavailable")
+	}
+	return f.fakeFileStore.GetFile(ctx, fileID)
+}
+
+// Test that analyzing a file keeps the similar files found by analyses of other files,
+// here a copy found by a global analysis of the other file, while comparing only within the assignment
+func TestAnalysisService_AnalyzeFileKeepsSimilarFilesOfOtherAnalyses(t *testing.T) {
+	ctx := context.Background()
+	text := readCorpus(t)[0]
+	repo := &fakeAnalysisRepo{
+		results: make(map[string]repository.AnalysisResult),
+		tags: map[string]repository.FileTags{
+			"kr-01": {Course: "se", Assignment: "kr-01"},
+			"kr-02": {Course: "se", Assignment: "kr-02"},
+		},
+	}
+	s := newTestService(t, repo, newFakeJobRepo())
+	s.fileStoringClient = fakeFileStore{"kr-01": text, "kr-02": text}
+
+	for _, analysis := range []struct {
+		fileID string
+		scope  repository.ComparisonScope
+	}{
+		{"kr-01", repository.ScopeGlobal},
+		{"kr-02", repository.ScopeGlobal},
+		{"kr-01", repository.ScopeAssignment},
+	} {
+		if _, err := s.AnalyzeFile(ctx, analysis.fileID, false, analyzer.WordCloudOptions{}, "winnowing", analysis.scope); err != nil {
+			t.Fatalf("AnalyzeFile() of %s in %s scope error = %v", analysis.fileID, analysis.scope, err)
+		}
+	}
+
+	analysis, err := s.GetAnalysisResult(ctx, "kr-02")
+	if err != nil {
+		t.Fatalf("GetAnalysisResult() error = %v", err)
+	}
+	if len(analysis.SimilarFiles) != 1 || analysis.SimilarFiles[0].FileID != "kr-01" || analysis.SimilarFiles[0].Similarity != 1 {
+		t.Errorf("GetAnalysisResult() similar files = %+v, want kr-01 with similarity 1", analysis.SimilarFiles)
+	}
+}
+
+// withdrawingRepo withdraws a file right after the first candidate fingerprints are found,
+// as if the file was deleted while another job was analyzing
+type withdrawingRepo struct {
+	*fakeAnalysisRepo
+	withdraw func()
+}
+
+func (r *withdrawingRepo) FindCandidateFingerprints(ctx context.Context, detector, version string, bandKeys []uint64) (map[string]repository.Fingerprint, error) {
+	candidates, err := r.fakeAnalysisRepo.FindCandidateFingerprints(ctx, detector, version, bandKeys)
+	if r.withdraw != nil {
+		r.withdraw()
+		r.withdraw = nil
+	}
+	return candidates, err
+}
+
+// Test that a job finishing after its file or a similar file was withdrawn leaves no similarity records behind
+func TestAnalysisService_AnalyzeFileWithdrawnDuringJob(t *testing.T) {
+	text := readCorpus(t)[0]
+	tests := []struct {
+		name      string
+		jobFileID string
+	}{
+		{name: "analyzed file withdrawn", jobFileID: "kr-01"},
+		{name: "similar file withdrawn", jobFileID: "kr-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := &withdrawingRepo{fakeAnalysisRepo: &fakeAnalysisRepo{
+				results: make(map[string]repository.AnalysisResult),
+				tags:    map[string]repository.FileTags{"kr-01": {}, "kr-02": {}},
+			}}
+			s := newTestService(t, repo, newFakeJobRepo())
+			files := fakeFileStore{"kr-01": text, "kr-02": text}
+			s.fileStoringClient = files
+			config := WorkerConfig{Workers: 1, PollInterval: time.Second, Lease: time.Minute, MaxAttempts: 3}
+
+			// The other copy is fingerprinted by its own analysis before the job starts
+			otherFileID := "kr-02"
+			if tt.jobFileID == otherFileID {
+				otherFileID = "kr-01"
+			}
+			if _, err := s.AnalyzeFile(ctx, otherFileID, false, analyzer.WordCloudOptions{}, "winnowing", repository.ScopeGlobal); err != nil {
+				t.Fatalf("AnalyzeFile() of %s error = %v", otherFileID, err)
+			}
+
+			job, err := s.SubmitAnalysis(ctx, tt.jobFileID, false, analyzer.WordCloudOptions{}, "winnowing", "")
+			if err != nil {
+				t.Fatalf("SubmitAnalysis() error = %v", err)
+			}
+			repo.withdraw = func() {
+				if err := s.DeleteAnalysis(ctx, "kr-01"); err != nil {
+					t.Errorf("DeleteAnalysis() error = %v", err)
+				}
+				delete(files, "kr-01")
+			}
+			if _, err := s.processNextJob(ctx, config); err != nil {
+				t.Fatalf("processNextJob() error = %v", err)
+			}
+
+			job, err = s.GetAnalysisJob(ctx, job.ID)
+			if err != nil {
+				t.Fatalf("GetAnalysisJob() error = %v", err)
+			}
+			wantStatus := repository.JobSucceeded
+			if tt.jobFileID == "kr-01" {
+				wantStatus = repository.JobFailed
+			}
+			if job.Status != wantStatus {
+				t.Errorf("GetAnalysisJob() status = %s (%s), want %s", job.Status, job.Error, wantStatus)
+			}
+			if _, err := s.GetAnalysisResult(ctx, "kr-01"); !errors.Is(err, repository.ErrAnalysisNotFound) {
+				t.Errorf("GetAnalysisResult() of the withdrawn file error = %v, want ErrAnalysisNotFound", err)
+			}
+			if edges := repo.allEdges(); len(edges) != 0 {
+				t.Errorf("similarity records after the withdrawal = %+v, want none", edges)
+			}
+			analysis, err := s.GetAnalysisResult(ctx, "kr-02")
+			if err != nil {
+				t.Fatalf("GetAnalysisResult() of kr-02 error = %v", err)
+			}
+			if len(analysis.SimilarFiles) != 0 {
+				t.Errorf("GetAnalysisResult() of kr-02 similar files = %+v, want none", analysis.SimilarFiles)
+			}
+		})
+	}
+}
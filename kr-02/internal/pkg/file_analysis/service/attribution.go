@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// Attribution tells which of two similar files was uploaded first
+type Attribution int
+
+const (
+	// AttributionUnknown is used when the upload time of either file is not known
+	AttributionUnknown Attribution = iota
+	// AttributionEarlier means the similar file was uploaded first, so the analyzed file may copy it
+	AttributionEarlier
+	// AttributionLater means the analyzed file was uploaded first, so the similar file may copy it
+	AttributionLater
+)
+
+// SimilarFile is a file similar to an analyzed file, with which of them was uploaded first
+type SimilarFile struct {
+	repository.SimilarFile
+	Attribution Attribution
+}
+
+// attribute tells for each similar file whether it was uploaded before or after the file.
+// Files uploaded at the same time are ordered by their IDs, so exactly one of two files is the earlier one.
+func attribute(fileID string, uploadedAt time.Time, similarFiles []repository.SimilarFile) []SimilarFile {
+	attributed := make([]SimilarFile, len(similarFiles))
+	for i, similarFile := range similarFiles {
+		attributed[i] = SimilarFile{SimilarFile: similarFile}
+		switch {
+		case uploadedAt.IsZero() || similarFile.UploadedAt.IsZero():
+			attributed[i].Attribution = AttributionUnknown
+		case uploadedBefore(similarFile.FileID, similarFile.UploadedAt, fileID, uploadedAt):
+			attributed[i].Attribution = AttributionEarlier
+		default:
+			attributed[i].Attribution = AttributionLater
+		}
+	}
+	return attributed
+}
+
+// uploadedBefore reports whether file a was uploaded before file b
+func uploadedBefore(a string, aUploadedAt time.Time, b string, bUploadedAt time.Time) bool {
+	if !aUploadedAt.Equal(bUploadedAt) {
+		return aUploadedAt.Before(bUploadedAt)
+	}
+	return a < b
+}
+
+// isCopy reports whether a file may copy one of its similar files: some similar file was uploaded first,
+// or the order is not known
+func isCopy(similarFiles []SimilarFile) bool {
+	for _, similarFile := range similarFiles {
+		if similarFile.Attribution != AttributionLater {
+			return true
+		}
+	}
+	return false
+}
+
+// originalFileID returns the ID of the file uploaded first among a file and its similar files,
+// or an empty string if the file has no similar files or their upload times are not known
+func originalFileID(fileID string, uploadedAt time.Time, similarFiles []SimilarFile) string {
+	if len(similarFiles) == 0 || uploadedAt.IsZero() {
+		return ""
+	}
+
+	original, originalUploadedAt := fileID, uploadedAt
+	for _, similarFile := range similarFiles {
+		if similarFile.UploadedAt.IsZero() {
+			return ""
+		}
+		if uploadedBefore(similarFile.FileID, similarFile.UploadedAt, original, originalUploadedAt) {
+			original, originalUploadedAt = similarFile.FileID, similarFile.UploadedAt
+		}
+	}
+	return original
+}
+
+// syncOverlap is how long before the latest recorded upload the files are listed again on every sync.
+// Upload times are set when an upload commits, so an upload committing while a sync lists the files
+// can be listed by the next sync with a time before the latest one it recorded.
+const syncOverlap = time.Minute
+
+// SyncStoredFiles records the files uploaded since shortly before the latest recorded upload with their tags,
+// so analyses compare against every stored file, analyzed or not, once it is fingerprinted
+func (s *AnalysisService) SyncStoredFiles(ctx context.Context) error {
+	latest, err := s.repo.GetLatestUploadTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !latest.IsZero() {
+		latest = latest.Add(-syncOverlap)
+	}
+
+	uploads, err := s.fileStoringClient.ListUploads(ctx, latest)
+	if err != nil {
+		return err
+	}
+	files := make([]repository.StoredFile, len(uploads))
+	for i, upload := range uploads {
+		files[i] = repository.StoredFile{
+			FileID:     upload.FileID,
+			UploadedAt: upload.UploadedAt,
+			Tags:       repository.FileTags{Course: upload.Course, Assignment: upload.Assignment},
+		}
+	}
+	return s.repo.SaveStoredFiles(ctx, files)
+}
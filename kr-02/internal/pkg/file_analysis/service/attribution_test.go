@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/clients"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+func TestAttribute(t *testing.T) {
+	noon := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+	similar := func(fileID string, uploadedAt time.Time) repository.SimilarFile {
+		return repository.SimilarFile{FileID: fileID, Similarity: 0.5, UploadedAt: uploadedAt}
+	}
+
+	tests := []struct {
+		name         string
+		uploadedAt   time.Time
+		similarFiles []repository.SimilarFile
+		want         []Attribution
+		isCopy       bool
+		original     string
+	}{
+		{
+			name:       "no similar files",
+			uploadedAt: noon,
+			want:       []Attribution{},
+			original:   "",
+		},
+		{
+			name:         "similar file uploaded earlier",
+			uploadedAt:   noon,
+			similarFiles: []repository.SimilarFile{similar("file-a", noon.Add(-time.Hour))},
+			want:         []Attribution{AttributionEarlier},
+			isCopy:       true,
+			original:     "file-a",
+		},
+		{
+			name:         "similar files uploaded later",
+			uploadedAt:   noon,
+			similarFiles: []repository.SimilarFile{similar("file-a", noon.Add(time.Hour)), similar("file-c", noon.Add(time.Minute))},
+			want:         []Attribution{AttributionLater, AttributionLater},
+			original:     "file-m",
+		},
+		{
+			name:         "earliest of several",
+			uploadedAt:   noon,
+			similarFiles: []repository.SimilarFile{similar("file-a", noon.Add(-time.Minute)), similar("file-z", noon.Add(-time.Hour)), similar("file-b", noon.Add(time.Hour))},
+			want:         []Attribution{AttributionEarlier, AttributionEarlier, AttributionLater},
+			isCopy:       true,
+			original:     "file-z",
+		},
+		{
+			name:         "same upload time ordered by ID",
+			uploadedAt:   noon,
+			similarFiles: []repository.SimilarFile{similar("file-a", noon), similar("file-z", noon)},
+			want:         []Attribution{AttributionEarlier, AttributionLater},
+			isCopy:       true,
+			original:     "file-a",
+		},
+		{
+			name:         "unknown upload time of the file",
+			similarFiles: []repository.SimilarFile{similar("file-z", noon)},
+			want:         []Attribution{AttributionUnknown},
+			isCopy:       true,
+			original:     "",
+		},
+		{
+			name:         "unknown upload time of a similar file",
+			uploadedAt:   noon,
+			similarFiles: []repository.SimilarFile{similar("file-z", noon.Add(time.Hour)), similar("file-a", time.Time{})},
+			want:         []Attribution{AttributionLater, AttributionUnknown},
+			isCopy:       true,
+			original:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributed := attribute("file-m", tt.uploadedAt, tt.similarFiles)
+			if len(attributed) != len(tt.want) {
+				t.Fatalf("attribute() returned %d files, want %d", len(attributed), len(tt.want))
+			}
+			for i, similarFile := range attributed {
+				if similarFile.FileID != tt.similarFiles[i].FileID {
+					t.Errorf("attribute()[%d] file = %s, want %s", i, similarFile.FileID, tt.similarFiles[i].FileID)
+				}
+				if similarFile.Attribution != tt.want[i] {
+					t.Errorf("attribute()[%d] attribution = %d, want %d", i, similarFile.Attribution, tt.want[i])
+				}
+			}
+
+			if got := isCopy(attributed); got != tt.isCopy {
+				t.Errorf("isCopy() = %v, want %v", got, tt.isCopy)
+			}
+			if got := originalFileID("file-m", tt.uploadedAt, attributed); got != tt.original {
+				t.Errorf("originalFileID() = %q, want %q", got, tt.original)
+			}
+		})
+	}
+}
+
+// Test that of two similar files exactly one is attributed as the earlier one, whichever is analyzed
+func TestAttribute_Symmetric(t *testing.T) {
+	noon := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{noon.Add(-time.Hour), noon, noon.Add(time.Hour)}
+
+	for _, aTime := range times {
+		for _, bTime := range times {
+			fromA := attribute("file-a", aTime, []repository.SimilarFile{{FileID: "file-b", UploadedAt: bTime}})
+			fromB := attribute("file-b", bTime, []repository.SimilarFile{{FileID: "file-a", UploadedAt: aTime}})
+			if isCopy(fromA) == isCopy(fromB) {
+				t.Errorf("uploaded at %v and %v: isCopy() = %v for both files, want exactly one copy", aTime, bTime, isCopy(fromA))
+			}
+			if a, b := originalFileID("file-a", aTime, fromA), originalFileID("file-b", bTime, fromB); a != b {
+				t.Errorf("uploaded at %v and %v: originalFileID() = %q and %q, want the same original", aTime, bTime, a, b)
+			}
+		}
+	}
+}
+
+// fakeUploads is a FileStore that lists its uploads and stores no contents
+type fakeUploads []clients.FileUpload
+
+func (f fakeUploads) GetFile(ctx context.Context, fileID string) (string, []byte, error) {
+	return "", nil, fmt.Errorf("file %s not found", fileID)
+}
+
+func (f fakeUploads) ListUploads(ctx context.Context, uploadedAfter time.Time) ([]clients.FileUpload, error) {
+	var uploads []clients.FileUpload
+	for _, upload := range f {
+		if !upload.UploadedAt.Before(uploadedAfter) {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
+
+// Test that an upload that commits while the files are listed, and so is listed after a later upload
+// was recorded, is recorded by the next sync
+func TestAnalysisService_SyncStoredFiles(t *testing.T) {
+	ctx := context.Background()
+	noon := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+	repo := &fakeAnalysisRepo{}
+	s := newTestService(t, repo, newFakeJobRepo())
+
+	s.fileStoringClient = fakeUploads{{FileID: "fast", UploadedAt: noon.Add(10 * time.Second)}}
+	if err := s.SyncStoredFiles(ctx); err != nil {
+		t.Fatalf("SyncStoredFiles() error = %v", err)
+	}
+
+	s.fileStoringClient = fakeUploads{
+		{FileID: "slow", UploadedAt: noon.Add(9 * time.Second), Course: "se", Assignment: "kr-02"},
+		{FileID: "fast", UploadedAt: noon.Add(10 * time.Second)},
+	}
+	if err := s.SyncStoredFiles(ctx); err != nil {
+		t.Fatalf("SyncStoredFiles() error = %v", err)
+	}
+
+	want := repository.FileTags{Course: "se", Assignment: "kr-02"}
+	if uploadedAt := repo.uploads["slow"]; !uploadedAt.Equal(noon.Add(9*time.Second)) || repo.tags["slow"] != want {
+		t.Errorf("SyncStoredFiles() recorded the slow upload at %v with tags %+v, want %v with %+v",
+			uploadedAt, repo.tags["slow"], noon.Add(9*time.Second), want)
+	}
+}
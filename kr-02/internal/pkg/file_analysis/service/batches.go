@@ -0,0 +1,159 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// ErrEmptyBatch is returned when a batch has no entries
+var ErrEmptyBatch = errors.New("batch has no files")
+
+// BatchReport is the consolidated report of the analyses of a batch
+type BatchReport struct {
+	repository.AnalysisBatch
+	Finished bool              // Set once the jobs of all entries have finished
+	Files    []BatchFileReport // In archive order
+	Pairs    []BatchPair       // Similar files within the batch, most similar first
+}
+
+// BatchFileReport reports on an entry of a batch
+type BatchFileReport struct {
+	repository.BatchEntry
+	Job                 *repository.AnalysisJob // Nil for rejected entries
+	Analysis            *Analysis               // Set once the job has succeeded, unless the results have been deleted since
+	SimilarBatchFileIDs []string                // Similar files of the same batch, most similar first
+	SimilarOtherFileIDs []string                // Similar files outside of the batch, most similar first
+	MaxSimilarity       float64
+}
+
+// BatchPair is a pair of similar files of a batch
+type BatchPair struct {
+	FileID      string
+	OtherFileID string
+	Similarity  float64
+}
+
+// SubmitBatch queues analyses of the stored entries of a batch with the named detector, or with the default
+// detector if the name is empty, in the scope, or in the default scope if the scope is empty, and records the batch. Rejected entries are recorded with their errors.
+// Since every analysis compares with all stored files, the files of the batch are compared with each other
+// as well as with earlier submissions.
+func (s *AnalysisService) SubmitBatch(
+	ctx context.Context,
+	name string,
+	entries []repository.BatchEntry,
+	generateWordCloud bool,
+	wordCloudOptions analyzer.WordCloudOptions,
+	detectorName string,
+	scope repository.ComparisonScope,
+) (BatchReport, error) {
+	if len(entries) == 0 {
+		return BatchReport{}, ErrEmptyBatch
+	}
+
+	batch := repository.AnalysisBatch{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Entries:   slices.Clone(entries),
+		CreatedAt: time.Now(),
+	}
+	for i, entry := range batch.Entries {
+		if entry.FileID == "" || entry.Error != "" {
+			continue
+		}
+		job, err := s.SubmitAnalysis(ctx, entry.FileID, generateWordCloud, wordCloudOptions, detectorName, scope)
+		if err != nil {
+			return BatchReport{}, err
+		}
+		batch.Entries[i].JobID = job.ID
+	}
+
+	if err := s.jobs.CreateBatch(ctx, batch); err != nil {
+		return BatchReport{}, err
+	}
+	return s.batchReport(ctx, batch)
+}
+
+// GetBatchReport builds the report of a batch from the current state of its jobs and analysis results
+func (s *AnalysisService) GetBatchReport(ctx context.Context, id string) (BatchReport, error) {
+	batch, err := s.jobs.GetBatch(ctx, id)
+	if err != nil {
+		return BatchReport{}, err
+	}
+	return s.batchReport(ctx, batch)
+}
+
+// batchReport builds the report of a batch
+func (s *AnalysisService) batchReport(ctx context.Context, batch repository.AnalysisBatch) (BatchReport, error) {
+	inBatch := make(map[string]bool, len(batch.Entries))
+	for _, entry := range batch.Entries {
+		if entry.FileID != "" {
+			inBatch[entry.FileID] = true
+		}
+	}
+
+	report := BatchReport{AnalysisBatch: batch, Finished: true}
+	// Similarity of each pair of files of the batch, keyed by the smaller file ID first
+	pairs := make(map[[2]string]float64)
+	for _, entry := range batch.Entries {
+		file := BatchFileReport{BatchEntry: entry}
+		report.Files = append(report.Files, file)
+		if entry.JobID == "" {
+			continue
+		}
+
+		job, err := s.jobs.GetJob(ctx, entry.JobID)
+		if err != nil {
+			return BatchReport{}, fmt.Errorf("failed to get analysis job of %s: %w", entry.FileName, err)
+		}
+		file.Job = &job
+		if job.Status != repository.JobSucceeded && job.Status != repository.JobFailed {
+			report.Finished = false
+		}
+
+		if job.Status == repository.JobSucceeded {
+			analysis, err := s.GetAnalysisResult(ctx, entry.FileID)
+			switch {
+			case errors.Is(err, repository.ErrAnalysisNotFound):
+				// The file has been deleted since
+			case err != nil:
+				return BatchReport{}, fmt.Errorf("failed to get analysis results of %s: %w", entry.FileName, err)
+			default:
+				file.Analysis = &analysis
+			}
+		}
+
+		if file.Analysis != nil {
+			for _, similarFile := range file.Analysis.SimilarFiles {
+				file.MaxSimilarity = max(file.MaxSimilarity, similarFile.Similarity)
+				if !inBatch[similarFile.FileID] {
+					file.SimilarOtherFileIDs = append(file.SimilarOtherFileIDs, similarFile.FileID)
+					continue
+				}
+				file.SimilarBatchFileIDs = append(file.SimilarBatchFileIDs, similarFile.FileID)
+				key := [2]string{min(entry.FileID, similarFile.FileID), max(entry.FileID, similarFile.FileID)}
+				pairs[key] = similarFile.Similarity
+			}
+		}
+		report.Files[len(report.Files)-1] = file
+	}
+
+	for key, similarity := range pairs {
+		report.Pairs = append(report.Pairs, BatchPair{FileID: key[0], OtherFileID: key[1], Similarity: similarity})
+	}
+	slices.SortFunc(report.Pairs, func(a, b BatchPair) int {
+		if c := cmp.Compare(b.Similarity, a.Similarity); c != 0 {
+			return c
+		}
+		return cmp.Or(cmp.Compare(a.FileID, b.FileID), cmp.Compare(a.OtherFileID, b.OtherFileID))
+	})
+	return report, nil
+}
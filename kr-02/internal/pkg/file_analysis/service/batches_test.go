@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+func TestAnalysisService_BatchReport(t *testing.T) {
+	ctx := context.Background()
+	jobs := newFakeJobRepo()
+	// The files have been analyzed before, so the jobs only look up the results
+	repo := &fakeAnalysisRepo{
+		results: map[string]repository.AnalysisResult{
+			"file-1": {FileID: "file-1", WordCount: 10, StatsVersion: analyzer.TextStatsVersion, Detector: "jaccard", Scope: repository.ScopeGlobal},
+			"file-2": {FileID: "file-2", WordCount: 20, StatsVersion: analyzer.TextStatsVersion, Detector: "jaccard", Scope: repository.ScopeGlobal},
+			"file-3": {FileID: "file-3", WordCount: 30, StatsVersion: analyzer.TextStatsVersion, Detector: "jaccard", Scope: repository.ScopeGlobal},
+		},
+		edges: []repository.SimilarityEdge{
+			{FileID: "file-1", OtherFileID: "earlier", Similarity: 0.9},
+			{FileID: "file-2", OtherFileID: "file-3", Similarity: 0.7},
+			{FileID: "file-1", OtherFileID: "file-3", Similarity: 0.4},
+		},
+	}
+	s := newTestService(t, repo, jobs)
+	config := WorkerConfig{Workers: 1, PollInterval: time.Second, Lease: time.Minute, MaxAttempts: 3}
+
+	entries := []repository.BatchEntry{
+		{FileName: "ivanov.txt", FileID: "file-1"},
+		{FileName: "petrov.pdf", Error: "file type is not allowed"},
+		{FileName: "sidorov.txt", FileID: "file-2"},
+		{FileName: "smirnov.txt", FileID: "file-3"},
+	}
+	submitted, err := s.SubmitBatch(ctx, "Assignment 1", entries, false, analyzer.WordCloudOptions{}, "", "")
+	if err != nil {
+		t.Fatalf("SubmitBatch() error = %v", err)
+	}
+	if submitted.Finished {
+		t.Error("SubmitBatch() report is finished, want the jobs queued")
+	}
+	if len(submitted.Files) != len(entries) {
+		t.Fatalf("SubmitBatch() reported %d files, want %d", len(submitted.Files), len(entries))
+	}
+	if rejected := submitted.Files[1]; rejected.Job != nil || rejected.Error == "" {
+		t.Errorf("SubmitBatch() rejected entry = %+v, want its error and no job", rejected)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if file := submitted.Files[i]; file.Job == nil || file.Job.Status != repository.JobQueued || file.Job.FileID != entries[i].FileID {
+			t.Errorf("SubmitBatch() entry %s has job %+v, want a queued job of %s", file.FileName, file.Job, entries[i].FileID)
+		}
+	}
+
+	for range 3 {
+		if _, err := s.processNextJob(ctx, config); err != nil {
+			t.Fatalf("processNextJob() error = %v", err)
+		}
+	}
+
+	report, err := s.GetBatchReport(ctx, submitted.ID)
+	if err != nil {
+		t.Fatalf("GetBatchReport() error = %v", err)
+	}
+	if !report.Finished || report.Name != "Assignment 1" {
+		t.Errorf("GetBatchReport() = %q finished %v, want %q finished", report.Name, report.Finished, "Assignment 1")
+	}
+
+	first := report.Files[0]
+	if first.Analysis == nil || first.Analysis.WordCount != 10 {
+		t.Fatalf("GetBatchReport() first file analysis = %+v, want the stored results", first.Analysis)
+	}
+	if !reflect.DeepEqual(first.SimilarBatchFileIDs, []string{"file-3"}) || !reflect.DeepEqual(first.SimilarOtherFileIDs, []string{"earlier"}) {
+		t.Errorf("GetBatchReport() first file similar to %v in the batch and %v outside, want [file-3] and [earlier]",
+			first.SimilarBatchFileIDs, first.SimilarOtherFileIDs)
+	}
+	if first.MaxSimilarity != 0.9 {
+		t.Errorf("GetBatchReport() first file max similarity = %v, want 0.9", first.MaxSimilarity)
+	}
+
+	wantPairs := []BatchPair{
+		{FileID: "file-2", OtherFileID: "file-3", Similarity: 0.7},
+		{FileID: "file-1", OtherFileID: "file-3", Similarity: 0.4},
+	}
+	if !reflect.DeepEqual(report.Pairs, wantPairs) {
+		t.Errorf("GetBatchReport() pairs = %+v, want %+v", report.Pairs, wantPairs)
+	}
+
+	if _, err := s.SubmitBatch(ctx, "", nil, false, analyzer.WordCloudOptions{}, "", ""); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("SubmitBatch() without entries error = %v, want ErrEmptyBatch", err)
+	}
+	if _, err := s.GetBatchReport(ctx, "missing"); !errors.Is(err, repository.ErrBatchNotFound) {
+		t.Errorf("GetBatchReport() error = %v, want ErrBatchNotFound", err)
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// ErrInvalidGraphFilter is returned when a filter of the similarity graph is out of range
+var ErrInvalidGraphFilter = errors.New("invalid similarity graph filter")
+
+// ClusterMethod chooses how similar files are grouped into plagiarism clusters
+type ClusterMethod int
+
+const (
+	// ClusterComponents groups files connected by a chain of similar pairs, so every file is in exactly one cluster
+	ClusterComponents ClusterMethod = iota
+	// ClusterCliques groups files that are all similar to each other (maximal cliques), so a file may be in several clusters
+	ClusterCliques
+)
+
+// SimilarityGraph is the graph of similar files: files are its nodes and pairs of similar files its edges
+type SimilarityGraph struct {
+	Files []GraphFile                 // In upload order, files with unknown upload times last
+	Edges []repository.SimilarityEdge // Most similar first
+
+	// Similarity of each pair of similar files, in both directions
+	neighbours map[string]map[string]float64
+}
+
+// GraphFile is a file of the similarity graph
+type GraphFile struct {
+	FileID     string
+	UploadedAt time.Time // Zero if the upload time is not known
+	Component  int       // 1-based number of the connected component of the file, in upload order of their first files
+}
+
+// PlagiarismCluster is a group of files that share text
+type PlagiarismCluster struct {
+	FileIDs           []string // In upload order, files with unknown upload times last
+	OriginalFileID    string   // File uploaded first, or empty if the upload time of a file is not known
+	EdgeCount         int      // Pairs of similar files within the cluster
+	MaxSimilarity     float64
+	AverageSimilarity float64
+}
+
+// GetSimilarityGraph retrieves the graph of the similar files matching the filter
+func (s *AnalysisService) GetSimilarityGraph(ctx context.Context, filter repository.SimilarityEdgeFilter) (SimilarityGraph, error) {
+	if err := validateGraphFilter(filter); err != nil {
+		return SimilarityGraph{}, err
+	}
+
+	edges, err := s.repo.GetSimilarityEdges(ctx, filter)
+	if err != nil {
+		return SimilarityGraph{}, fmt.Errorf("failed to get similar files: %w", err)
+	}
+	return newSimilarityGraph(edges), nil
+}
+
+// GetPlagiarismClusters groups the similar files matching the filter into clusters,
+// largest first and most similar first among clusters of the same size
+func (s *AnalysisService) GetPlagiarismClusters(
+	ctx context.Context,
+	filter repository.SimilarityEdgeFilter,
+	method ClusterMethod,
+) ([]PlagiarismCluster, error) {
+	graph, err := s.GetSimilarityGraph(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case ClusterComponents:
+		return graph.clusters(graph.components()), nil
+	case ClusterCliques:
+		return graph.clusters(graph.cliques()), nil
+	default:
+		return nil, fmt.Errorf("unknown cluster method %d", method)
+	}
+}
+
+// validateGraphFilter checks that the minimum similarity is a similarity and the upload time range is not empty
+func validateGraphFilter(filter repository.SimilarityEdgeFilter) error {
+	if filter.MinSimilarity < 0 || filter.MinSimilarity > 1 {
+		return fmt.Errorf("%w: min similarity must be from 0 to 1", ErrInvalidGraphFilter)
+	}
+	if !filter.UploadedAfter.IsZero() && !filter.UploadedBefore.IsZero() && !filter.UploadedAfter.Before(filter.UploadedBefore) {
+		return fmt.Errorf("%w: uploaded after must be before uploaded before", ErrInvalidGraphFilter)
+	}
+	return nil
+}
+
+// newSimilarityGraph builds the similarity graph of pairs of similar files
+func newSimilarityGraph(edges []repository.SimilarityEdge) SimilarityGraph {
+	graph := SimilarityGraph{Edges: edges, neighbours: make(map[string]map[string]float64)}
+
+	uploadedAt := make(map[string]time.Time)
+	addFile := func(fileID, otherFileID string, fileUploadedAt time.Time, similarity float64) {
+		if graph.neighbours[fileID] == nil {
+			graph.neighbours[fileID] = make(map[string]float64)
+			graph.Files = append(graph.Files, GraphFile{FileID: fileID})
+		}
+		graph.neighbours[fileID][otherFileID] = similarity
+		uploadedAt[fileID] = fileUploadedAt
+	}
+	for _, edge := range edges {
+		addFile(edge.FileID, edge.OtherFileID, edge.UploadedAt, edge.Similarity)
+		addFile(edge.OtherFileID, edge.FileID, edge.OtherUploadedAt, edge.Similarity)
+	}
+	for i := range graph.Files {
+		graph.Files[i].UploadedAt = uploadedAt[graph.Files[i].FileID]
+	}
+	slices.SortFunc(graph.Files, uploadOrder)
+
+	// Number the components by walking from each file not reached yet, in upload order
+	components := make(map[string]int)
+	component := 0
+	for _, file := range graph.Files {
+		if components[file.FileID] != 0 {
+			continue
+		}
+		component++
+		pending := []string{file.FileID}
+		components[file.FileID] = component
+		for len(pending) > 0 {
+			fileID := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			for neighbour := range graph.neighbours[fileID] {
+				if components[neighbour] == 0 {
+					components[neighbour] = component
+					pending = append(pending, neighbour)
+				}
+			}
+		}
+	}
+	for i := range graph.Files {
+		graph.Files[i].Component = components[graph.Files[i].FileID]
+	}
+	return graph
+}
+
+// uploadOrder orders files by upload time and then by ID, files with unknown upload times last
+func uploadOrder(a, b GraphFile) int {
+	switch {
+	case a.UploadedAt.IsZero() != b.UploadedAt.IsZero():
+		if a.UploadedAt.IsZero() {
+			return 1
+		}
+		return -1
+	case a.FileID == b.FileID:
+		return 0
+	case uploadedBefore(a.FileID, a.UploadedAt, b.FileID, b.UploadedAt):
+		return -1
+	default:
+		return 1
+	}
+}
+
+// components returns the files of each connected component of the graph, in upload order
+func (g SimilarityGraph) components() [][]string {
+	var components [][]string
+	for _, file := range g.Files {
+		if file.Component > len(components) {
+			components = append(components, nil)
+		}
+		components[file.Component-1] = append(components[file.Component-1], file.FileID)
+	}
+	return components
+}
+
+// cliques returns the files of each maximal clique of the graph, in upload order.
+// It uses the Bron–Kerbosch algorithm with pivoting.
+func (g SimilarityGraph) cliques() [][]string {
+	files := make([]string, len(g.Files))
+	order := make(map[string]int, len(g.Files))
+	for i, file := range g.Files {
+		files[i] = file.FileID
+		order[file.FileID] = i
+	}
+	neighboursOf := func(fileID string, among []string) []string {
+		var neighbours []string
+		for _, other := range among {
+			if _, ok := g.neighbours[fileID][other]; ok {
+				neighbours = append(neighbours, other)
+			}
+		}
+		return neighbours
+	}
+
+	var cliques [][]string
+	var extend func(clique, candidates, excluded []string)
+	extend = func(clique, candidates, excluded []string) {
+		if len(candidates) == 0 {
+			if len(excluded) == 0 && len(clique) > 1 {
+				cliques = append(cliques, slices.Clone(clique))
+			}
+			return
+		}
+
+		// Cliques containing a neighbour of the pivot but not the pivot are not maximal,
+		// so only the pivot and files not similar to it need to be tried
+		pivot, pivotNeighbours := "", -1
+		for _, fileID := range slices.Concat(candidates, excluded) {
+			if count := len(neighboursOf(fileID, candidates)); count > pivotNeighbours {
+				pivot, pivotNeighbours = fileID, count
+			}
+		}
+
+		for _, fileID := range slices.Clone(candidates) {
+			if _, ok := g.neighbours[pivot][fileID]; ok {
+				continue
+			}
+			extend(append(clique, fileID), neighboursOf(fileID, candidates), neighboursOf(fileID, excluded))
+			candidates = slices.DeleteFunc(candidates, func(other string) bool { return other == fileID })
+			excluded = append(excluded, fileID)
+		}
+	}
+	extend(nil, files, nil)
+
+	for _, clique := range cliques {
+		slices.SortFunc(clique, func(a, b string) int { return order[a] - order[b] })
+	}
+	return cliques
+}
+
+// clusters describes groups of files of the graph as plagiarism clusters,
+// largest first and most similar first among clusters of the same size
+func (g SimilarityGraph) clusters(groups [][]string) []PlagiarismCluster {
+	uploadedAt := make(map[string]time.Time, len(g.Files))
+	for _, file := range g.Files {
+		uploadedAt[file.FileID] = file.UploadedAt
+	}
+
+	clusters := make([]PlagiarismCluster, 0, len(groups))
+	for _, fileIDs := range groups {
+		cluster := PlagiarismCluster{FileIDs: fileIDs}
+		if !uploadedAt[fileIDs[0]].IsZero() && !uploadedAt[fileIDs[len(fileIDs)-1]].IsZero() {
+			cluster.OriginalFileID = fileIDs[0]
+		}
+
+		var total float64
+		for i, fileID := range fileIDs {
+			for _, other := range fileIDs[i+1:] {
+				if similarity, ok := g.neighbours[fileID][other]; ok {
+					cluster.EdgeCount++
+					cluster.MaxSimilarity = max(cluster.MaxSimilarity, similarity)
+					total += similarity
+				}
+			}
+		}
+		if cluster.EdgeCount > 0 {
+			cluster.AverageSimilarity = total / float64(cluster.EdgeCount)
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	slices.SortStableFunc(clusters, func(a, b PlagiarismCluster) int {
+		if len(a.FileIDs) != len(b.FileIDs) {
+			return len(b.FileIDs) - len(a.FileIDs)
+		}
+		return cmp.Compare(b.MaxSimilarity, a.MaxSimilarity)
+	})
+	return clusters
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// testSimilarityEdges is a triangle a-b-c with d similar only to c, a pair e-f and a pair g-h with an unknown upload time
+func testSimilarityEdges() []repository.SimilarityEdge {
+	noon := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+	uploadedAt := map[string]time.Time{
+		"a": noon.Add(time.Hour),
+		"b": noon.Add(2 * time.Hour),
+		"c": noon,
+		"d": noon.Add(3 * time.Hour),
+		"e": noon.Add(-time.Hour),
+		"f": noon.Add(-time.Hour),
+		"g": noon,
+	}
+	edge := func(fileID, otherFileID string, similarity float64) repository.SimilarityEdge {
+		return repository.SimilarityEdge{
+			FileID:          fileID,
+			OtherFileID:     otherFileID,
+			Similarity:      similarity,
+			UploadedAt:      uploadedAt[fileID],
+			OtherUploadedAt: uploadedAt[otherFileID],
+		}
+	}
+	return []repository.SimilarityEdge{
+		edge("e", "f", 0.9),
+		edge("a", "b", 0.8),
+		edge("g", "h", 0.7),
+		edge("a", "c", 0.6),
+		edge("b", "c", 0.4),
+		edge("c", "d", 0.3),
+	}
+}
+
+func TestNewSimilarityGraph(t *testing.T) {
+	graph := newSimilarityGraph(testSimilarityEdges())
+
+	var fileIDs []string
+	components := make(map[string]int)
+	for _, file := range graph.Files {
+		fileIDs = append(fileIDs, file.FileID)
+		components[file.FileID] = file.Component
+	}
+	// Files uploaded at the same time are ordered by ID, and files with unknown upload times come last
+	if want := []string{"e", "f", "c", "g", "a", "b", "d", "h"}; !reflect.DeepEqual(fileIDs, want) {
+		t.Errorf("newSimilarityGraph() files = %v, want %v", fileIDs, want)
+	}
+	wantComponents := map[string]int{"e": 1, "f": 1, "c": 2, "a": 2, "b": 2, "d": 2, "g": 3, "h": 3}
+	if !reflect.DeepEqual(components, wantComponents) {
+		t.Errorf("newSimilarityGraph() components = %v, want %v", components, wantComponents)
+	}
+}
+
+func TestAnalysisService_GetPlagiarismClusters(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, &fakeAnalysisRepo{edges: testSimilarityEdges()}, newFakeJobRepo())
+
+	tests := []struct {
+		name   string
+		method ClusterMethod
+		want   []PlagiarismCluster
+	}{
+		{
+			name:   "components",
+			method: ClusterComponents,
+			want: []PlagiarismCluster{
+				{FileIDs: []string{"c", "a", "b", "d"}, OriginalFileID: "c", EdgeCount: 4, MaxSimilarity: 0.8, AverageSimilarity: 0.525},
+				{FileIDs: []string{"e", "f"}, OriginalFileID: "e", EdgeCount: 1, MaxSimilarity: 0.9, AverageSimilarity: 0.9},
+				{FileIDs: []string{"g", "h"}, EdgeCount: 1, MaxSimilarity: 0.7, AverageSimilarity: 0.7},
+			},
+		},
+		{
+			name:   "cliques",
+			method: ClusterCliques,
+			want: []PlagiarismCluster{
+				{FileIDs: []string{"c", "a", "b"}, OriginalFileID: "c", EdgeCount: 3, MaxSimilarity: 0.8, AverageSimilarity: 0.6},
+				{FileIDs: []string{"e", "f"}, OriginalFileID: "e", EdgeCount: 1, MaxSimilarity: 0.9, AverageSimilarity: 0.9},
+				{FileIDs: []string{"g", "h"}, EdgeCount: 1, MaxSimilarity: 0.7, AverageSimilarity: 0.7},
+				{FileIDs: []string{"c", "d"}, OriginalFileID: "c", EdgeCount: 1, MaxSimilarity: 0.3, AverageSimilarity: 0.3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters, err := s.GetPlagiarismClusters(ctx, repository.SimilarityEdgeFilter{}, tt.method)
+			if err != nil {
+				t.Fatalf("GetPlagiarismClusters() error = %v", err)
+			}
+			// Averages are compared rounded, since sums of similarities are not exact
+			for i := range clusters {
+				clusters[i].AverageSimilarity = float64(int(clusters[i].AverageSimilarity*1000+0.5)) / 1000
+			}
+			if !reflect.DeepEqual(clusters, tt.want) {
+				t.Errorf("GetPlagiarismClusters() = %+v, want %+v", clusters, tt.want)
+			}
+		})
+	}
+
+	invalid := []repository.SimilarityEdgeFilter{
+		{MinSimilarity: 1.5},
+		{UploadedAfter: time.Now(), UploadedBefore: time.Now().Add(-time.Hour)},
+	}
+	for _, filter := range invalid {
+		if _, err := s.GetPlagiarismClusters(ctx, filter, ClusterComponents); !errors.Is(err, ErrInvalidGraphFilter) {
+			t.Errorf("GetPlagiarismClusters(%+v) error = %v, want ErrInvalidGraphFilter", filter, err)
+		}
+	}
+}
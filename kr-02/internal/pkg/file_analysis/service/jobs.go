@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// Progress in percent reported by analyzeFile after each step
+const (
+	progressFileFetched        = 10
+	progressCandidatesFound    = 50
+	progressPlagiarismChecked  = 80
+	progressWordCloudGenerated = 95
+)
+
+// WorkerConfig configures the workers processing analysis jobs
+type WorkerConfig struct {
+	Workers      int           // Number of jobs processed concurrently
+	PollInterval time.Duration // How often idle workers look for jobs queued by other instances
+	Lease        time.Duration // How long a job may go without progress before another worker takes it over
+	MaxAttempts  int           // How many times a job is taken over before it is failed
+	SyncInterval time.Duration // How often files uploaded since the last sync are recorded and fingerprinted
+}
+
+// SubmitAnalysis queues an analysis of a file with the named detector, or with the default detector
+// if the name is empty, in the scope, or in the default scope if the scope is empty, and returns the job.
+// If the file already has an unfinished job with the same detector and scope, that job is returned instead.
+func (s *AnalysisService) SubmitAnalysis(
+	ctx context.Context,
+	fileID string,
+	generateWordCloud bool,
+	wordCloudOptions analyzer.WordCloudOptions,
+	detectorName string,
+	requestedScope repository.ComparisonScope,
+) (repository.AnalysisJob, error) {
+	detector, err := s.detectors.Get(detectorName)
+	if err != nil {
+		return repository.AnalysisJob{}, err
+	}
+	scope, err := s.comparison.scope(requestedScope)
+	if err != nil {
+		return repository.AnalysisJob{}, err
+	}
+	if err := wordCloudOptions.Validate(); err != nil {
+		return repository.AnalysisJob{}, err
+	}
+
+	job, err := s.jobs.CreateJob(ctx, repository.AnalysisJob{
+		ID:                uuid.New().String(),
+		FileID:            fileID,
+		GenerateWordCloud: generateWordCloud,
+		WordCloudOptions:  repository.WordCloudOptions(wordCloudOptions),
+		Detector:          detector.Name(),
+		Scope:             scope,
+	})
+	if err != nil {
+		return repository.AnalysisJob{}, fmt.Errorf("failed to queue analysis: %w", err)
+	}
+
+	// Wake up an idle worker of this instance
+	select {
+	case s.jobSubmitted <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// GetAnalysisJob retrieves an analysis job by ID
+func (s *AnalysisService) GetAnalysisJob(ctx context.Context, id string) (repository.AnalysisJob, error) {
+	return s.jobs.GetJob(ctx, id)
+}
+
+// RunWorkers processes queued analysis jobs until ctx is canceled, while keeping the recorded stored files
+// and their fingerprints up to date in the background.
+// Jobs interrupted by the cancellation keep their state and are taken over once their lease expires.
+func (s *AnalysisService) RunWorkers(ctx context.Context, config WorkerConfig) {
+	var wg sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx, config)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runSync(ctx, config.SyncInterval)
+	}()
+	wg.Wait()
+}
+
+// runSync records the files uploaded since the last sync and computes their missing fingerprints,
+// first on startup and then every interval, so analyses compare with them without fetching them
+func (s *AnalysisService) runSync(ctx context.Context, interval time.Duration) {
+	for {
+		if err := s.SyncStoredFiles(ctx); err != nil {
+			fmt.Printf("Failed to sync stored files: %v\n", err)
+		}
+		rebuilt, err := s.RebuildFingerprints(ctx)
+		if err != nil {
+			fmt.Printf("Failed to rebuild fingerprints: %v\n", err)
+		}
+		if rebuilt > 0 {
+			fmt.Printf("Rebuilt fingerprints of %d files\n", rebuilt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runWorker processes jobs one by one, waiting for new ones whenever the queue is empty
+func (s *AnalysisService) runWorker(ctx context.Context, config WorkerConfig) {
+	for {
+		processed, err := s.processNextJob(ctx, config)
+		if err != nil {
+			fmt.Printf("Failed to process analysis job: %v\n", err)
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.jobSubmitted:
+		case <-time.After(config.PollInterval):
+		}
+	}
+}
+
+// processNextJob claims a job and runs its analysis. It returns false if there was no job to claim.
+func (s *AnalysisService) processNextJob(ctx context.Context, config WorkerConfig) (bool, error) {
+	job, ok, err := s.jobs.ClaimJob(ctx, config.Lease, config.MaxAttempts)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	reportProgress := func(percent int) {
+		if err := s.jobs.UpdateJobProgress(ctx, job.ID, percent, config.Lease); err != nil {
+			fmt.Printf("Failed to update progress of analysis job %s: %v\n", job.ID, err)
+		}
+	}
+
+	wordCloudOptions := analyzer.WordCloudOptions(job.WordCloudOptions)
+	_, err = s.analyzeFile(ctx, job.FileID, job.GenerateWordCloud, wordCloudOptions, job.Detector, job.Scope, reportProgress)
+	if ctx.Err() != nil {
+		// Shutting down; the job is taken over once its lease expires
+		return true, nil
+	}
+
+	var errMessage string
+	if err != nil {
+		fmt.Printf("Analysis job %s failed: %v\n", job.ID, err)
+		errMessage = err.Error()
+	}
+	if err := s.jobs.FinishJob(ctx, job.ID, errMessage); err != nil {
+		return true, err
+	}
+	return true, nil
+}
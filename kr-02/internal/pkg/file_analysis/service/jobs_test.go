@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/clients"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// fakeAnalysisRepo is an in-memory AnalysisRepository holding only analysis results, word clouds, similar pairs,
+// fingerprints, the tags of stored files, fingerprint failures and reference documents
+type fakeAnalysisRepo struct {
+	results      map[string]repository.AnalysisResult
+	wordClouds   map[string]string                      // Locations by file ID and options key
+	edges        []repository.SimilarityEdge            // Most similar first; returned for every filter
+	found        map[string][]repository.SimilarityEdge // Edges found by analyses by file ID, detector and scope
+	fingerprints map[string]repository.Fingerprint      // By file ID and detector
+	tags         map[string]repository.FileTags         // Tags of stored files by file ID
+	uploads      map[string]time.Time                   // Upload times of stored files by file ID
+	failures     map[string]time.Time                   // Retry times of files whose content failed to be fetched
+	references   []repository.ReferenceDocument         // Oldest first
+}
+
+func (r *fakeAnalysisRepo) SaveAnalysisResult(ctx context.Context, result repository.AnalysisResult, similarFiles []repository.SimilarFile) error {
+	if _, ok := r.tags[result.FileID]; !ok {
+		return fmt.Errorf("%w: %s", repository.ErrFileNotStored, result.FileID)
+	}
+	r.results[result.FileID] = result
+	if r.found == nil {
+		r.found = make(map[string][]repository.SimilarityEdge)
+	}
+	key := result.FileID + "/" + result.Detector + "/" + string(result.Scope)
+	r.found[key] = nil
+	for _, similarFile := range similarFiles {
+		if _, ok := r.tags[similarFile.FileID]; !ok {
+			continue
+		}
+		r.found[key] = append(r.found[key], repository.SimilarityEdge{FileID: result.FileID, OtherFileID: similarFile.FileID, Similarity: similarFile.Similarity})
+	}
+	return nil
+}
+
+// allEdges returns the preset edges followed by the edges found by analyses, each pair once with its highest similarity
+func (r *fakeAnalysisRepo) allEdges() []repository.SimilarityEdge {
+	edges := slices.Clone(r.edges)
+	for _, key := range slices.Sorted(maps.Keys(r.found)) {
+		for _, edge := range r.found[key] {
+			i := slices.IndexFunc(edges, func(other repository.SimilarityEdge) bool {
+				return other.FileID == edge.FileID && other.OtherFileID == edge.OtherFileID ||
+					other.FileID == edge.OtherFileID && other.OtherFileID == edge.FileID
+			})
+			switch {
+			case i < 0:
+				edges = append(edges, edge)
+			case edge.Similarity > edges[i].Similarity:
+				edges[i].Similarity = edge.Similarity
+			}
+		}
+	}
+	return edges
+}
+
+func (r *fakeAnalysisRepo) GetAnalysisResult(ctx context.Context, fileID string) (repository.AnalysisResult, error) {
+	result, ok := r.results[fileID]
+	if !ok {
+		return repository.AnalysisResult{}, repository.ErrAnalysisNotFound
+	}
+	result.Tags = r.tags[fileID]
+	return result, nil
+}
+
+func (r *fakeAnalysisRepo) GetSimilarFileScores(ctx context.Context, fileID string) ([]repository.SimilarFile, error) {
+	var similarFiles []repository.SimilarFile
+	for _, edge := range r.allEdges() {
+		switch fileID {
+		case edge.FileID:
+			similarFiles = append(similarFiles, repository.SimilarFile{FileID: edge.OtherFileID, Similarity: edge.Similarity, UploadedAt: edge.OtherUploadedAt, Tags: r.tags[edge.OtherFileID]})
+		case edge.OtherFileID:
+			similarFiles = append(similarFiles, repository.SimilarFile{FileID: edge.FileID, Similarity: edge.Similarity, UploadedAt: edge.UploadedAt, Tags: r.tags[edge.FileID]})
+		}
+	}
+	return similarFiles, nil
+}
+
+func (r *fakeAnalysisRepo) GetSimilarityEdges(ctx context.Context, filter repository.SimilarityEdgeFilter) ([]repository.SimilarityEdge, error) {
+	return r.allEdges(), nil
+}
+
+func (r *fakeAnalysisRepo) DeleteAnalysisResult(ctx context.Context, fileID string) ([]string, error) {
+	delete(r.results, fileID)
+	delete(r.tags, fileID)
+	delete(r.uploads, fileID)
+	delete(r.failures, fileID)
+	for key := range r.fingerprints {
+		if strings.HasPrefix(key, fileID+"/") {
+			delete(r.fingerprints, key)
+		}
+	}
+	for key, edges := range r.found {
+		if strings.HasPrefix(key, fileID+"/") {
+			delete(r.found, key)
+			continue
+		}
+		r.found[key] = slices.DeleteFunc(edges, func(edge repository.SimilarityEdge) bool { return edge.OtherFileID == fileID })
+	}
+	return nil, nil
+}
+
+func (r *fakeAnalysisRepo) GetAllFileIDs(ctx context.Context) ([]string, error) {
+	var fileIDs []string
+	for fileID := range r.results {
+		fileIDs = append(fileIDs, fileID)
+	}
+	return fileIDs, nil
+}
+
+func (r *fakeAnalysisRepo) SaveStoredFiles(ctx context.Context, files []repository.StoredFile) error {
+	if r.tags == nil {
+		r.tags = make(map[string]repository.FileTags)
+	}
+	if r.uploads == nil {
+		r.uploads = make(map[string]time.Time)
+	}
+	for _, file := range files {
+		r.tags[file.FileID] = file.Tags
+		r.uploads[file.FileID] = file.UploadedAt
+	}
+	return nil
+}
+
+func (r *fakeAnalysisRepo) GetStoredFiles(ctx context.Context, fileIDs []string) (map[string]repository.StoredFile, error) {
+	files := make(map[string]repository.StoredFile)
+	for _, fileID := range fileIDs {
+		if tags, ok := r.tags[fileID]; ok {
+			files[fileID] = repository.StoredFile{FileID: fileID, Tags: tags}
+		}
+	}
+	return files, nil
+}
+
+func (r *fakeAnalysisRepo) GetLatestUploadTime(ctx context.Context) (time.Time, error) {
+	var latest time.Time
+	for _, uploadedAt := range r.uploads {
+		if uploadedAt.After(latest) {
+			latest = uploadedAt
+		}
+	}
+	return latest, nil
+}
+
+func (r *fakeAnalysisRepo) GetWordCloudLocations(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeAnalysisRepo) GetWordCloud(ctx context.Context, fileID, optionsKey string) (string, error) {
+	location, ok := r.wordClouds[fileID+"/"+optionsKey]
+	if !ok {
+		return "", repository.ErrWordCloudNotFound
+	}
+	return location, nil
+}
+
+func (r *fakeAnalysisRepo) SaveWordCloud(ctx context.Context, fileID, optionsKey, location string) error {
+	if r.wordClouds == nil {
+		r.wordClouds = make(map[string]string)
+	}
+	r.wordClouds[fileID+"/"+optionsKey] = location
+	return nil
+}
+
+func (r *fakeAnalysisRepo) UpdateWordCloudLocation(ctx context.Context, fileID, location string) error {
+	result := r.results[fileID]
+	result.WordCloudLocation = location
+	r.results[fileID] = result
+	return nil
+}
+
+func (r *fakeAnalysisRepo) SaveFingerprint(ctx context.Context, fileID string, fingerprint repository.Fingerprint) error {
+	if r.fingerprints == nil {
+		r.fingerprints = make(map[string]repository.Fingerprint)
+	}
+	r.fingerprints[fileID+"/"+fingerprint.Detector] = fingerprint
+	return nil
+}
+
+func (r *fakeAnalysisRepo) FindCandidateFingerprints(ctx context.Context, detector, version string, bandKeys []uint64) (map[string]repository.Fingerprint, error) {
+	candidates := make(map[string]repository.Fingerprint)
+	for key, fingerprint := range r.fingerprints {
+		fileID, fingerprintDetector, _ := strings.Cut(key, "/")
+		if fingerprintDetector != detector || fingerprint.Version != version {
+			continue
+		}
+		if slices.ContainsFunc(fingerprint.BandKeys, func(key uint64) bool { return slices.Contains(bandKeys, key) }) {
+			candidates[fileID] = fingerprint
+		}
+	}
+	return candidates, nil
+}
+
+func (r *fakeAnalysisRepo) GetFileIDsWithStaleFingerprint(ctx context.Context, detector, version string) ([]string, error) {
+	var fileIDs []string
+	for fileID := range r.tags {
+		if fingerprint, ok := r.fingerprints[fileID+"/"+detector]; ok && fingerprint.Version == version {
+			continue
+		}
+		if retryAfter, ok := r.failures[fileID]; ok && retryAfter.After(time.Now()) {
+			continue
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	slices.Sort(fileIDs)
+	return fileIDs, nil
+}
+
+func (r *fakeAnalysisRepo) SaveFingerprintFailure(ctx context.Context, fileID, errMessage string, retryAfter time.Time) error {
+	if r.failures == nil {
+		r.failures = make(map[string]time.Time)
+	}
+	r.failures[fileID] = retryAfter
+	return nil
+}
+
+func (r *fakeAnalysisRepo) SaveReferenceDocument(ctx context.Context, document repository.ReferenceDocument) error {
+	r.references = append(r.references, document)
+	return nil
+}
+
+func (r *fakeAnalysisRepo) GetReferenceDocuments(ctx context.Context, tags repository.FileTags) ([]repository.ReferenceDocument, error) {
+	var documents []repository.ReferenceDocument
+	for _, document := range r.references {
+		if (tags.Course == "" || document.Tags.Course == tags.Course) && (tags.Assignment == "" || document.Tags.Assignment == tags.Assignment) {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+func (r *fakeAnalysisRepo) DeleteReferenceDocument(ctx context.Context, id string) error {
+	for i, document := range r.references {
+		if document.ID == id {
+			r.references = slices.Delete(r.references, i, i+1)
+			return nil
+		}
+	}
+	return repository.ErrReferenceNotFound
+}
+
+// newTestService creates an AnalysisService without file storage or word clouds, defaulting to the Jaccard detector
+func newTestService(t *testing.T, repo repository.AnalysisRepository, jobs repository.JobRepository) *AnalysisService {
+	t.Helper()
+
+	plagiarismChecker := analyzer.NewPlagiarismChecker()
+	detectors, err := analyzer.NewDetectors("jaccard", plagiarismChecker, analyzer.NewWinnowingDetector())
+	if err != nil {
+		t.Fatalf("NewDetectors() error = %v", err)
+	}
+	return NewAnalysisService(repo, jobs, nil, nil, analyzer.NewTextAnalyzer(), plagiarismChecker, detectors, nil, ComparisonConfig{})
+}
+
+// fakeFileStore is an in-memory FileStore of file contents by file ID that lists its files as untagged uploads
+type fakeFileStore map[string]string
+
+func (f fakeFileStore) GetFile(ctx context.Context, fileID string) (string, []byte, error) {
+	content, ok := f[fileID]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", clients.ErrFileNotFound, fileID)
+	}
+	return fileID + ".txt", []byte(content), nil
+}
+
+func (f fakeFileStore) ListUploads(ctx context.Context, uploadedAfter time.Time) ([]clients.FileUpload, error) {
+	var uploads []clients.FileUpload
+	for _, fileID := range slices.Sorted(maps.Keys(f)) {
+		uploads = append(uploads, clients.FileUpload{FileID: fileID})
+	}
+	return uploads, nil
+}
+
+// fakeJobRepo is an in-memory JobRepository that claims jobs in submission order
+type fakeJobRepo struct {
+	jobs    map[string]repository.AnalysisJob
+	order   []string
+	batches map[string]repository.AnalysisBatch
+}
+
+func newFakeJobRepo() *fakeJobRepo {
+	return &fakeJobRepo{jobs: make(map[string]repository.AnalysisJob), batches: make(map[string]repository.AnalysisBatch)}
+}
+
+func (r *fakeJobRepo) CreateJob(ctx context.Context, job repository.AnalysisJob) (repository.AnalysisJob, error) {
+	for _, id := range r.order {
+		existing := r.jobs[id]
+		if existing.FileID == job.FileID && existing.Detector == job.Detector && existing.Scope == job.Scope &&
+			(existing.Status == repository.JobQueued || existing.Status == repository.JobRunning) {
+			if !existing.GenerateWordCloud {
+				existing.WordCloudOptions = job.WordCloudOptions
+			}
+			existing.GenerateWordCloud = existing.GenerateWordCloud || job.GenerateWordCloud
+			r.jobs[id] = existing
+			return existing, nil
+		}
+	}
+	job.Status = repository.JobQueued
+	job.CreatedAt = time.Now()
+	r.jobs[job.ID] = job
+	r.order = append(r.order, job.ID)
+	return job, nil
+}
+
+func (r *fakeJobRepo) GetJob(ctx context.Context, id string) (repository.AnalysisJob, error) {
+	job, ok := r.jobs[id]
+	if !ok {
+		return repository.AnalysisJob{}, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (r *fakeJobRepo) ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (repository.AnalysisJob, bool, error) {
+	for _, id := range r.order {
+		job := r.jobs[id]
+		if job.Status == repository.JobQueued {
+			job.Status = repository.JobRunning
+			job.Attempts++
+			job.StartedAt = time.Now()
+			r.jobs[id] = job
+			return job, true, nil
+		}
+	}
+	return repository.AnalysisJob{}, false, nil
+}
+
+func (r *fakeJobRepo) UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error {
+	job := r.jobs[id]
+	job.Progress = progress
+	r.jobs[id] = job
+	return nil
+}
+
+func (r *fakeJobRepo) FinishJob(ctx context.Context, id string, errMessage string) error {
+	job, ok := r.jobs[id]
+	if !ok || job.Status != repository.JobRunning {
+		return repository.ErrJobNotFound
+	}
+	job.Status = repository.JobSucceeded
+	job.Progress = 100
+	if errMessage != "" {
+		job.Status = repository.JobFailed
+	}
+	job.Error = errMessage
+	job.FinishedAt = time.Now()
+	r.jobs[id] = job
+	return nil
+}
+
+func (r *fakeJobRepo) CreateBatch(ctx context.Context, batch repository.AnalysisBatch) error {
+	r.batches[batch.ID] = batch
+	return nil
+}
+
+func (r *fakeJobRepo) GetBatch(ctx context.Context, id string) (repository.AnalysisBatch, error) {
+	batch, ok := r.batches[id]
+	if !ok {
+		return repository.AnalysisBatch{}, repository.ErrBatchNotFound
+	}
+	return batch, nil
+}
+
+func TestAnalysisService_SubmitAnalysis(t *testing.T) {
+	ctx := context.Background()
+	jobs := newFakeJobRepo()
+	s := newTestService(t, &fakeAnalysisRepo{results: map[string]repository.AnalysisResult{}}, jobs)
+
+	first, err := s.SubmitAnalysis(ctx, "file-1", false, analyzer.WordCloudOptions{}, "", "")
+	if err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+	if first.Status != repository.JobQueued {
+		t.Errorf("SubmitAnalysis() status = %q, want %q", first.Status, repository.JobQueued)
+	}
+	if first.Detector != "jaccard" {
+		t.Errorf("SubmitAnalysis() detector = %q, want the default %q", first.Detector, "jaccard")
+	}
+
+	// An unfinished job is shared by repeated submissions with the same detector and scope
+	options := analyzer.WordCloudOptions{Format: analyzer.WordCloudSVG, MaxWords: 20}
+	second, err := s.SubmitAnalysis(ctx, "file-1", true, options, "jaccard", repository.ScopeGlobal)
+	if err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("SubmitAnalysis() created job %s, want existing job %s", second.ID, first.ID)
+	}
+	if !second.GenerateWordCloud {
+		t.Error("SubmitAnalysis() did not request a word cloud for the existing job")
+	}
+	if second.WordCloudOptions.Format != analyzer.WordCloudSVG || second.WordCloudOptions.MaxWords != 20 {
+		t.Errorf("SubmitAnalysis() word cloud options = %+v, want the options of the submission requesting it", second.WordCloudOptions)
+	}
+
+	// Submissions with another detector or scope get their own job
+	for _, tt := range []struct {
+		detector string
+		scope    repository.ComparisonScope
+	}{
+		{"winnowing", repository.ScopeGlobal},
+		{"jaccard", repository.ScopeAssignment},
+	} {
+		job, err := s.SubmitAnalysis(ctx, "file-1", false, analyzer.WordCloudOptions{}, tt.detector, tt.scope)
+		if err != nil {
+			t.Fatalf("SubmitAnalysis() error = %v", err)
+		}
+		if job.ID == first.ID || job.Detector != tt.detector || job.Scope != tt.scope {
+			t.Errorf("SubmitAnalysis() with %s in %s scope = job %s with %s in %s scope, want a new job with them",
+				tt.detector, tt.scope, job.ID, job.Detector, job.Scope)
+		}
+	}
+
+	other, err := s.SubmitAnalysis(ctx, "file-2", false, analyzer.WordCloudOptions{}, "winnowing", "")
+	if err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+	if other.ID == first.ID {
+		t.Error("SubmitAnalysis() shared a job between different files")
+	}
+	if other.Detector != "winnowing" {
+		t.Errorf("SubmitAnalysis() detector = %q, want %q", other.Detector, "winnowing")
+	}
+
+	if _, err := s.SubmitAnalysis(ctx, "file-3", false, analyzer.WordCloudOptions{}, "moss", ""); !errors.Is(err, analyzer.ErrUnknownDetector) {
+		t.Errorf("SubmitAnalysis() with an unknown detector error = %v, want ErrUnknownDetector", err)
+	}
+	invalid := analyzer.WordCloudOptions{Format: "gif"}
+	if _, err := s.SubmitAnalysis(ctx, "file-3", true, invalid, "", ""); !errors.Is(err, analyzer.ErrInvalidWordCloudOptions) {
+		t.Errorf("SubmitAnalysis() with invalid word cloud options error = %v, want ErrInvalidWordCloudOptions", err)
+	}
+
+	// Submissions wake up an idle worker
+	select {
+	case <-s.jobSubmitted:
+	default:
+		t.Error("SubmitAnalysis() did not notify the workers")
+	}
+}
+
+func TestAnalysisService_ProcessNextJob(t *testing.T) {
+	ctx := context.Background()
+	jobs := newFakeJobRepo()
+	// The file has been analyzed with the same detector before, so the job only looks up the results
+	s := newTestService(t, &fakeAnalysisRepo{results: map[string]repository.AnalysisResult{
+		"file-1": {FileID: "file-1", ParagraphCount: 1, WordCount: 42, StatsVersion: analyzer.TextStatsVersion, Detector: "winnowing", Scope: repository.ScopeGlobal},
+	}}, jobs)
+	config := WorkerConfig{Workers: 1, PollInterval: time.Second, Lease: time.Minute, MaxAttempts: 3}
+
+	job, err := s.SubmitAnalysis(ctx, "file-1", false, analyzer.WordCloudOptions{}, "winnowing", "")
+	if err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+
+	processed, err := s.processNextJob(ctx, config)
+	if err != nil {
+		t.Fatalf("processNextJob() error = %v", err)
+	}
+	if !processed {
+		t.Fatal("processNextJob() did not process the queued job")
+	}
+
+	job, err = s.GetAnalysisJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetAnalysisJob() error = %v", err)
+	}
+	if job.Status != repository.JobSucceeded || job.Progress != 100 || job.Attempts != 1 {
+		t.Errorf("GetAnalysisJob() = %+v, want succeeded after 1 attempt with progress 100", job)
+	}
+	if analysis, err := s.GetAnalysisResult(ctx, job.FileID); err != nil || analysis.WordCount != 42 {
+		t.Errorf("GetAnalysisResult() word count = %d, %v, want 42", analysis.WordCount, err)
+	}
+
+	// The queue is empty now
+	processed, err = s.processNextJob(ctx, config)
+	if err != nil {
+		t.Fatalf("processNextJob() error = %v", err)
+	}
+	if processed {
+		t.Error("processNextJob() processed a job from an empty queue")
+	}
+
+	if _, err := s.GetAnalysisJob(ctx, "missing"); !errors.Is(err, repository.ErrJobNotFound) {
+		t.Errorf("GetAnalysisJob() error = %v, want ErrJobNotFound", err)
+	}
+}
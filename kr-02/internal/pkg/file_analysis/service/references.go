@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// ErrInvalidReference is returned when a reference document has no assignment or no text
+var ErrInvalidReference = errors.New("invalid reference document")
+
+// UploadReferenceDocument stores text that the files of an assignment are allowed to share, such as its statement
+// or template. The course is optional, as in the tags of files. Files of the assignment analyzed before
+// are compared without the document when they are analyzed again.
+func (s *AnalysisService) UploadReferenceDocument(
+	ctx context.Context,
+	name string,
+	tags repository.FileTags,
+	content []byte,
+) (repository.ReferenceDocument, error) {
+	tags = repository.FileTags{Course: strings.TrimSpace(tags.Course), Assignment: strings.TrimSpace(tags.Assignment)}
+	if tags.Assignment == "" {
+		return repository.ReferenceDocument{}, fmt.Errorf("%w: assignment is required", ErrInvalidReference)
+	}
+	if !utf8.Valid(content) {
+		return repository.ReferenceDocument{}, fmt.Errorf("%w: text is not valid UTF-8", ErrInvalidReference)
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return repository.ReferenceDocument{}, fmt.Errorf("%w: text is empty", ErrInvalidReference)
+	}
+
+	document := repository.ReferenceDocument{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(name),
+		Tags:      tags,
+		Content:   string(content),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.SaveReferenceDocument(ctx, document); err != nil {
+		return repository.ReferenceDocument{}, err
+	}
+	return document, nil
+}
+
+// ListReferenceDocuments retrieves the reference documents of the course and assignment of the tags, oldest first.
+// Empty tags match documents of any course or assignment.
+func (s *AnalysisService) ListReferenceDocuments(ctx context.Context, tags repository.FileTags) ([]repository.ReferenceDocument, error) {
+	return s.repo.GetReferenceDocuments(ctx, tags)
+}
+
+// DeleteReferenceDocument deletes a reference document. Files of its assignment analyzed before
+// are compared with its text again when they are analyzed again.
+func (s *AnalysisService) DeleteReferenceDocument(ctx context.Context, id string) error {
+	return s.repo.DeleteReferenceDocument(ctx, id)
+}
+
+// referenceDocuments retrieves the reference documents of the assignment of a file with the tags.
+// Files without an assignment have none.
+func (s *AnalysisService) referenceDocuments(ctx context.Context, tags repository.FileTags) ([]repository.ReferenceDocument, error) {
+	if tags.Assignment == "" {
+		return nil, nil
+	}
+	documents, err := s.repo.GetReferenceDocuments(ctx, tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reference documents: %w", err)
+	}
+	// Documents of an assignment without a course are not shared by the assignments of the same name of courses
+	return slices.DeleteFunc(documents, func(document repository.ReferenceDocument) bool {
+		return document.Tags != tags
+	}), nil
+}
+
+// referencesUnchanged reports whether the reference documents of the assignment of an analyzed file
+// are the ones its stored analysis ignored
+func (s *AnalysisService) referencesUnchanged(ctx context.Context, result repository.AnalysisResult) bool {
+	documents, err := s.referenceDocuments(ctx, result.Tags)
+	if err != nil {
+		// Log the error and analyze the file again
+		fmt.Printf("Failed to check reference documents of file %s: %v\n", result.FileID, err)
+		return false
+	}
+	return referenceVersion(documents) == result.References
+}
+
+// referenceNGrams computes the hashes of the reference documents with the detector
+func referenceNGrams(detector analyzer.Detector, documents []repository.ReferenceDocument) []uint64 {
+	contents := make([]string, len(documents))
+	for i, document := range documents {
+		contents[i] = document.Content
+	}
+	return analyzer.ReferenceNGrams(detector, contents)
+}
+
+// referenceVersion identifies a set of reference documents by their IDs, empty if there are none.
+// Documents are never modified, so the IDs identify their texts.
+func referenceVersion(documents []repository.ReferenceDocument) string {
+	if len(documents) == 0 {
+		return ""
+	}
+	ids := make([]string, len(documents))
+	for i, document := range documents {
+		ids[i] = document.ID
+	}
+	slices.Sort(ids)
+	hash := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+func TestAnalysisService_ReferenceDocuments(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeAnalysisRepo{}
+	s := newTestService(t, repo, newFakeJobRepo())
+
+	kr02 := repository.FileTags{Course: "se", Assignment: "kr-02"}
+	statement, err := s.UploadReferenceDocument(ctx, " statement.txt ", repository.FileTags{Course: " se ", Assignment: "kr-02"}, []byte("Реализуйте сервис анализа файлов"))
+	if err != nil {
+		t.Fatalf("UploadReferenceDocument() error = %v", err)
+	}
+	if statement.ID == "" || statement.Name != "statement.txt" || statement.Tags != kr02 {
+		t.Errorf("UploadReferenceDocument() = %+v, want an ID, the trimmed name and tags %+v", statement, kr02)
+	}
+	if _, err := s.UploadReferenceDocument(ctx, "template.txt", repository.FileTags{Assignment: "kr-02"}, []byte("Титульный лист")); err != nil {
+		t.Fatalf("UploadReferenceDocument() error = %v", err)
+	}
+
+	invalid := map[string]struct {
+		tags    repository.FileTags
+		content []byte
+	}{
+		"without assignment": {repository.FileTags{Course: "se"}, []byte("Реализуйте сервис")},
+		"empty":              {kr02, []byte(" \n ")},
+		"not UTF-8":          {kr02, []byte{0xcf, 0xf0, 0xe8}},
+	}
+	for name, tt := range invalid {
+		if _, err := s.UploadReferenceDocument(ctx, name, tt.tags, tt.content); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("UploadReferenceDocument() %s error = %v, want ErrInvalidReference", name, err)
+		}
+	}
+
+	if documents, err := s.ListReferenceDocuments(ctx, repository.FileTags{Assignment: "kr-02"}); err != nil || len(documents) != 2 {
+		t.Errorf("ListReferenceDocuments() = %d documents, %v, want both documents of kr-02", len(documents), err)
+	}
+
+	// Only the documents of the same assignment of the same course are left out of comparisons
+	documents, err := s.referenceDocuments(ctx, kr02)
+	if err != nil || len(documents) != 1 || documents[0].ID != statement.ID {
+		t.Errorf("referenceDocuments() = %+v, %v, want the statement", documents, err)
+	}
+	if documents, err := s.referenceDocuments(ctx, repository.FileTags{Course: "se"}); err != nil || len(documents) != 0 {
+		t.Errorf("referenceDocuments() without an assignment = %+v, %v, want none", documents, err)
+	}
+
+	// Stored analyses are redone once the reference documents of their assignment change
+	result := repository.AnalysisResult{FileID: "file-1", Tags: kr02, References: referenceVersion(documents)}
+	if !s.referencesUnchanged(ctx, result) {
+		t.Error("referencesUnchanged() = false, want true for the current documents")
+	}
+	if err := s.DeleteReferenceDocument(ctx, statement.ID); err != nil {
+		t.Fatalf("DeleteReferenceDocument() error = %v", err)
+	}
+	if s.referencesUnchanged(ctx, result) {
+		t.Error("referencesUnchanged() = true after the statement was deleted, want false")
+	}
+	if result.References = ""; !s.referencesUnchanged(ctx, result) {
+		t.Error("referencesUnchanged() = false without documents, want true")
+	}
+
+	if err := s.DeleteReferenceDocument(ctx, statement.ID); !errors.Is(err, repository.ErrReferenceNotFound) {
+		t.Errorf("DeleteReferenceDocument() of a deleted document error = %v, want ErrReferenceNotFound", err)
+	}
+}
+
+// Test that analyses leave the statement of the assignment out of the comparison once it is uploaded
+// as a reference document: reports sharing only the statement stop being similar, while copies stay similar
+func TestAnalysisService_AnalyzeFileWithoutReference(t *testing.T) {
+	ctx := context.Background()
+	texts := readCorpus(t)
+	statement := texts[12] // 03-03
+	kr02 := repository.FileTags{Course: "se", Assignment: "kr-02"}
+	repo := &fakeAnalysisRepo{
+		results: make(map[string]repository.AnalysisResult),
+		tags:    map[string]repository.FileTags{"ivanov": kr02, "petrov": kr02, "sidorov": kr02},
+	}
+	s := newTestService(t, repo, newFakeJobRepo())
+	s.fileStoringClient = fakeFileStore{
+		"ivanov":  statement + "\n\n" + texts[0], // 01-01
+		"petrov":  statement + "\n\n" + texts[1], // 01-02, a copy of 01-01
+		"sidorov": statement + "\n\n" + texts[5], // 02-01, unrelated
+	}
+
+	similarFileIDs := func() []string {
+		t.Helper()
+		analysis, err := s.AnalyzeFile(ctx, "ivanov", false, analyzer.WordCloudOptions{}, "jaccard", repository.ScopeAssignment)
+		if err != nil {
+			t.Fatalf("AnalyzeFile() error = %v", err)
+		}
+		var fileIDs []string
+		for _, similarFile := range analysis.SimilarFiles {
+			fileIDs = append(fileIDs, similarFile.FileID)
+		}
+		slices.Sort(fileIDs)
+		return fileIDs
+	}
+
+	// Fingerprint the other reports
+	for _, fileID := range []string{"petrov", "sidorov"} {
+		if _, err := s.AnalyzeFile(ctx, fileID, false, analyzer.WordCloudOptions{}, "jaccard", repository.ScopeAssignment); err != nil {
+			t.Fatalf("AnalyzeFile() of %s error = %v", fileID, err)
+		}
+	}
+
+	if got, want := similarFileIDs(), []string{"petrov", "sidorov"}; !slices.Equal(got, want) {
+		t.Errorf("AnalyzeFile() with the statement similar files = %v, want %v", got, want)
+	}
+
+	if _, err := s.UploadReferenceDocument(ctx, "statement.txt", kr02, []byte(statement)); err != nil {
+		t.Fatalf("UploadReferenceDocument() error = %v", err)
+	}
+	if got, want := similarFileIDs(), []string{"petrov"}; !slices.Equal(got, want) {
+		t.Errorf("AnalyzeFile() without the statement similar files = %v, want %v", got, want)
+	}
+
+	// The report does not show the statement as copied: passages start after its n-grams at the earliest
+	report, err := s.GetPlagiarismReport(ctx, "ivanov")
+	if err != nil {
+		t.Fatalf("GetPlagiarismReport() error = %v", err)
+	}
+	if len(report.Matches) != 1 || len(report.Matches[0].Passages) == 0 {
+		t.Fatalf("GetPlagiarismReport() matches = %+v, want petrov with passages", report.Matches)
+	}
+	for _, passage := range report.Matches[0].Passages {
+		if passage.SourceStart < len([]rune(statement))/2 {
+			t.Errorf("GetPlagiarismReport() passage %q starts in the statement", passage.Text)
+		}
+	}
+}
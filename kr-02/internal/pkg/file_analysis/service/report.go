@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+)
+
+// maxReportPassages is the number of matching passages reported per similar file
+const maxReportPassages = 5
+
+// PlagiarismReport shows how similar an analyzed file is to each similar file, and where
+type PlagiarismReport struct {
+	FileID         string
+	IsPlagiarism   bool
+	OriginalFileID string            // File uploaded first among the file and its similar files, if known
+	Matches        []PlagiarismMatch // Most similar first
+}
+
+// PlagiarismMatch is a file similar to the reported file
+type PlagiarismMatch struct {
+	FileID      string
+	Similarity  float64
+	UploadedAt  time.Time // Zero if the upload time is not known
+	Attribution Attribution
+	Passages    []analyzer.Passage // Longest first; empty if the content of the file is not available
+}
+
+// GetPlagiarismReport builds the plagiarism report of an analyzed file.
+// Passages are computed from the contents of the file and each similar file, leaving out the text
+// of the reference documents of its assignment.
+func (s *AnalysisService) GetPlagiarismReport(ctx context.Context, fileID string) (PlagiarismReport, error) {
+	analysis, err := s.GetAnalysisResult(ctx, fileID)
+	if err != nil {
+		return PlagiarismReport{}, err
+	}
+
+	detector, err := s.detectors.Get(analysis.Detector)
+	if err != nil {
+		return PlagiarismReport{}, err
+	}
+
+	report := PlagiarismReport{
+		FileID:         fileID,
+		IsPlagiarism:   analysis.IsPlagiarism,
+		OriginalFileID: analysis.OriginalFileID,
+	}
+	if len(analysis.SimilarFiles) == 0 {
+		return report, nil
+	}
+
+	_, content, err := s.fileStoringClient.GetFile(ctx, fileID)
+	if err != nil {
+		return PlagiarismReport{}, fmt.Errorf("failed to get file content: %w", err)
+	}
+
+	documents, err := s.referenceDocuments(ctx, analysis.Tags)
+	if err != nil {
+		return PlagiarismReport{}, err
+	}
+	references := make([]string, len(documents))
+	for i, document := range documents {
+		references[i] = document.Content
+	}
+	reference := referenceNGrams(detector, documents)
+
+	for _, similarFile := range analysis.SimilarFiles {
+		match := PlagiarismMatch{
+			FileID:      similarFile.FileID,
+			Similarity:  similarFile.Similarity,
+			UploadedAt:  similarFile.UploadedAt,
+			Attribution: similarFile.Attribution,
+		}
+
+		_, otherContent, err := s.fileStoringClient.GetFile(ctx, similarFile.FileID)
+		if err != nil {
+			// Log the error but report the match without passages
+			fmt.Printf("Failed to get content for file %s: %v\n", similarFile.FileID, err)
+			report.Matches = append(report.Matches, match)
+			continue
+		}
+
+		// Pairs recorded before similarity scores were kept are scored now
+		if match.Similarity == 0 {
+			match.Similarity = detector.Similarity(
+				detector.Fingerprint(string(content)).WithoutReference(reference),
+				detector.Fingerprint(string(otherContent)).WithoutReference(reference),
+			)
+		}
+		match.Passages = s.plagiarismChecker.MatchingPassages(string(content), string(otherContent), references, maxReportPassages)
+		report.Matches = append(report.Matches, match)
+	}
+
+	return report, nil
+}
+
+// ComparedFile is one side of a file comparison
+type ComparedFile struct {
+	FileID   string
+	FileName string
+	Content  string
+}
+
+// FileComparison describes how similar two files are and which passages they share
+type FileComparison struct {
+	File      ComparedFile
+	OtherFile ComparedFile
+	analyzer.Comparison
+}
+
+// CompareFiles compares two stored files, which do not have to be analyzed
+func (s *AnalysisService) CompareFiles(ctx context.Context, fileID, otherFileID string) (FileComparison, error) {
+	fileName, content, err := s.fileStoringClient.GetFile(ctx, fileID)
+	if err != nil {
+		return FileComparison{}, fmt.Errorf("failed to get file content: %w", err)
+	}
+
+	otherFileName, otherContent, err := s.fileStoringClient.GetFile(ctx, otherFileID)
+	if err != nil {
+		return FileComparison{}, fmt.Errorf("failed to get file content: %w", err)
+	}
+
+	return FileComparison{
+		File:       ComparedFile{FileID: fileID, FileName: fileName, Content: string(content)},
+		OtherFile:  ComparedFile{FileID: otherFileID, FileName: otherFileName, Content: string(otherContent)},
+		Comparison: s.plagiarismChecker.Compare(string(content), string(otherContent)),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+// ErrUnknownScope is returned when a comparison scope is requested that does not exist
+var ErrUnknownScope = errors.New("unknown comparison scope")
+
+// scopes are the comparison scopes from the narrowest to the widest
+var scopes = []repository.ComparisonScope{repository.ScopeAssignment, repository.ScopeCourse, repository.ScopeGlobal}
+
+// ComparisonConfig configures which files analyses compare with and how similar they must be
+type ComparisonConfig struct {
+	DefaultScope repository.ComparisonScope // Used when a request does not choose a scope; global if empty
+	// Thresholds are the similarities from which two files are considered similar, by the narrowest scope they share.
+	// Scopes without a threshold use the threshold of the detector.
+	Thresholds map[repository.ComparisonScope]float64
+}
+
+// Validate checks that the default scope and the scopes of the thresholds exist and the thresholds are from 0 to 1
+func (c ComparisonConfig) Validate() error {
+	if _, err := c.scope(""); err != nil {
+		return err
+	}
+	for scope, threshold := range c.Thresholds {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("%s threshold %v is not from 0 to 1", scope, threshold)
+		}
+	}
+	return nil
+}
+
+// scope returns the requested scope, or the default scope if the request is empty
+func (c ComparisonConfig) scope(requested repository.ComparisonScope) (repository.ComparisonScope, error) {
+	scope := cmp.Or(requested, c.DefaultScope, repository.ScopeGlobal)
+	if !slices.Contains(scopes, scope) {
+		return "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+	}
+	return scope, nil
+}
+
+// threshold returns the similarity from which two files sharing the scope, and no narrower one,
+// are considered similar by the detector
+func (c ComparisonConfig) threshold(detector analyzer.Detector, shared repository.ComparisonScope) float64 {
+	if threshold, ok := c.Thresholds[shared]; ok {
+		return threshold
+	}
+	return detector.Threshold()
+}
+
+// sharedScope returns the narrowest scope two files share. Files share an assignment if they are tagged
+// with the same assignment of the same course, or with the same assignment and no course.
+func sharedScope(a, b repository.FileTags) repository.ComparisonScope {
+	switch {
+	case a.Assignment != "" && a.Assignment == b.Assignment && a.Course == b.Course:
+		return repository.ScopeAssignment
+	case a.Course != "" && a.Course == b.Course:
+		return repository.ScopeCourse
+	default:
+		return repository.ScopeGlobal
+	}
+}
+
+// inScope reports whether files sharing a scope are compared in the scope of an analysis
+func inScope(shared, scope repository.ComparisonScope) bool {
+	return slices.Index(scopes, shared) <= slices.Index(scopes, cmp.Or(scope, repository.ScopeGlobal))
+}
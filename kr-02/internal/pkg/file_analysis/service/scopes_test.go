@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"kr-02/internal/pkg/file_analysis/analyzer"
+	"kr-02/internal/pkg/file_analysis/repository"
+)
+
+func TestSharedScope(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b repository.FileTags
+		want repository.ComparisonScope
+	}{
+		{"same assignment", repository.FileTags{Course: "se", Assignment: "kr-02"}, repository.FileTags{Course: "se", Assignment: "kr-02"}, repository.ScopeAssignment},
+		{"same assignment without course", repository.FileTags{Assignment: "kr-02"}, repository.FileTags{Assignment: "kr-02"}, repository.ScopeAssignment},
+		{"same assignment of other courses", repository.FileTags{Course: "se", Assignment: "kr-02"}, repository.FileTags{Course: "db", Assignment: "kr-02"}, repository.ScopeGlobal},
+		{"other assignment of the course", repository.FileTags{Course: "se", Assignment: "kr-01"}, repository.FileTags{Course: "se", Assignment: "kr-02"}, repository.ScopeCourse},
+		{"same course without assignments", repository.FileTags{Course: "se"}, repository.FileTags{Course: "se"}, repository.ScopeCourse},
+		{"untagged", repository.FileTags{}, repository.FileTags{}, repository.ScopeGlobal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sharedScope(tt.a, tt.b); got != tt.want {
+				t.Errorf("sharedScope() = %q, want %q", got, tt.want)
+			}
+			if got := sharedScope(tt.b, tt.a); got != tt.want {
+				t.Errorf("sharedScope() reversed = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComparisonConfig(t *testing.T) {
+	config := ComparisonConfig{
+		DefaultScope: repository.ScopeCourse,
+		Thresholds:   map[repository.ComparisonScope]float64{repository.ScopeAssignment: 0.6},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if scope, err := config.scope(""); err != nil || scope != repository.ScopeCourse {
+		t.Errorf("scope(\"\") = %q, %v, want the default %q", scope, err, repository.ScopeCourse)
+	}
+	if scope, err := (ComparisonConfig{}).scope(""); err != nil || scope != repository.ScopeGlobal {
+		t.Errorf("scope(\"\") without a default = %q, %v, want %q", scope, err, repository.ScopeGlobal)
+	}
+	if _, err := config.scope("faculty"); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("scope(%q) error = %v, want ErrUnknownScope", "faculty", err)
+	}
+
+	detector := analyzer.NewPlagiarismChecker()
+	if got := config.threshold(detector, repository.ScopeAssignment); got != 0.6 {
+		t.Errorf("threshold(assignment) = %v, want the configured 0.6", got)
+	}
+	if got := config.threshold(detector, repository.ScopeGlobal); got != detector.Threshold() {
+		t.Errorf("threshold(global) = %v, want the detector threshold %v", got, detector.Threshold())
+	}
+
+	invalid := []ComparisonConfig{
+		{DefaultScope: "faculty"},
+		{Thresholds: map[repository.ComparisonScope]float64{"faculty": 0.5}},
+		{Thresholds: map[repository.ComparisonScope]float64{repository.ScopeCourse: 1.5}},
+	}
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) error = nil, want an error", config)
+		}
+	}
+}
+
+func TestAnalysisService_ScopedSimilarFiles(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeAnalysisRepo{
+		results: map[string]repository.AnalysisResult{
+			"file-1": {FileID: "file-1", Detector: "jaccard"},
+		},
+		edges: []repository.SimilarityEdge{
+			{FileID: "file-1", OtherFileID: "same-assignment", Similarity: 0.9},
+			{FileID: "file-1", OtherFileID: "same-course", Similarity: 0.8},
+			{FileID: "file-1", OtherFileID: "other-course", Similarity: 0.7},
+		},
+		tags: map[string]repository.FileTags{
+			"file-1":          {Course: "se", Assignment: "kr-02"},
+			"same-assignment": {Course: "se", Assignment: "kr-02"},
+			"same-course":     {Course: "se", Assignment: "kr-01"},
+			"other-course":    {Course: "db", Assignment: "kr-02"},
+		},
+	}
+	s := newTestService(t, repo, newFakeJobRepo())
+
+	tests := []struct {
+		scope repository.ComparisonScope
+		want  []string
+	}{
+		{repository.ScopeAssignment, []string{"same-assignment"}},
+		{repository.ScopeCourse, []string{"same-assignment", "same-course"}},
+		{repository.ScopeGlobal, []string{"same-assignment", "same-course", "other-course"}},
+	}
+	for _, tt := range tests {
+		result := repo.results["file-1"]
+		result.Scope = tt.scope
+		repo.results["file-1"] = result
+
+		analysis, err := s.GetAnalysisResult(ctx, "file-1")
+		if err != nil {
+			t.Fatalf("GetAnalysisResult() error = %v", err)
+		}
+		if !reflect.DeepEqual(analysis.SimilarFileIDs, tt.want) {
+			t.Errorf("GetAnalysisResult() in scope %s similar files = %v, want %v", tt.scope, analysis.SimilarFileIDs, tt.want)
+		}
+	}
+}
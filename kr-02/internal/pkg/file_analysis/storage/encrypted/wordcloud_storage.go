@@ -0,0 +1,86 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"kr-02/internal/pkg/encryption"
+	"kr-02/internal/pkg/file_analysis/storage"
+)
+
+// EncryptedStorage implements the WordCloudStorage interface by encrypting images before they reach another storage.
+// Images stored before encryption was enabled are read as plaintext until they are re-encrypted.
+type EncryptedStorage struct {
+	storage storage.WordCloudStorage
+	keyring *encryption.Keyring
+}
+
+// NewEncryptedStorage creates a new EncryptedStorage instance wrapping the given storage
+func NewEncryptedStorage(storage storage.WordCloudStorage, keyring *encryption.Keyring) *EncryptedStorage {
+	return &EncryptedStorage{
+		storage: storage,
+		keyring: keyring,
+	}
+}
+
+// SaveWordCloud encrypts a word cloud image and saves it to the wrapped storage
+func (s *EncryptedStorage) SaveWordCloud(ctx context.Context, location string, image []byte) error {
+	encrypted, err := s.keyring.Encrypt(bytes.NewReader(image))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt word cloud: %w", err)
+	}
+	data, err := io.ReadAll(encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt word cloud: %w", err)
+	}
+	return s.storage.SaveWordCloud(ctx, location, data)
+}
+
+// GetWordCloud retrieves a word cloud image from the wrapped storage and decrypts it
+func (s *EncryptedStorage) GetWordCloud(ctx context.Context, location string) ([]byte, error) {
+	data, err := s.storage.GetWordCloud(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := s.keyring.Decrypt(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt word cloud: %w", err)
+	}
+	image, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt word cloud: %w", err)
+	}
+	return image, nil
+}
+
+// DeleteWordCloud removes a word cloud image from the wrapped storage
+func (s *EncryptedStorage) DeleteWordCloud(ctx context.Context, location string) error {
+	return s.storage.DeleteWordCloud(ctx, location)
+}
+
+// ReEncrypt rewrites a word cloud image with the primary key unless it is already encrypted with it
+func (s *EncryptedStorage) ReEncrypt(ctx context.Context, location string) (bool, error) {
+	data, err := s.storage.GetWordCloud(ctx, location)
+	if err != nil {
+		return false, err
+	}
+	keyID, encrypted, err := encryption.KeyID(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	if encrypted && keyID == s.keyring.PrimaryKeyID() {
+		return false, nil
+	}
+
+	image, err := s.GetWordCloud(ctx, location)
+	if err != nil {
+		return false, err
+	}
+	if err := s.SaveWordCloud(ctx, location, image); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -11,4 +11,7 @@ type WordCloudStorage interface {
 	
 	// GetWordCloud retrieves a word cloud image from storage
 	GetWordCloud(ctx context.Context, location string) ([]byte, error)
+
+	// DeleteWordCloud removes a word cloud image from storage
+	DeleteWordCloud(ctx context.Context, location string) error
 }
\ No newline at end of file
@@ -23,7 +23,9 @@ func NewLocalStorage(basePath string) (storage.WordCloudStorage, error) {
 	return &LocalStorage{basePath: basePath}, nil
 }
 
-// SaveWordCloud saves a word cloud image to the local filesystem
+// SaveWordCloud saves a word cloud image to the local filesystem.
+// The image is written to a temporary file that replaces the target only once it is complete,
+// so a crash never leaves a truncated image behind.
 func (s *LocalStorage) SaveWordCloud(ctx context.Context, location string, image []byte) error {
 	fullPath := filepath.Join(s.basePath, location)
 	
@@ -33,10 +35,35 @@ func (s *LocalStorage) SaveWordCloud(ctx context.Context, location string, image
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 	
-	// Write the file
-	if err := os.WriteFile(fullPath, image, 0644); err != nil {
+	// Write the temporary file
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*.partial")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	tempPath := file.Name()
+
+	if _, err := file.Write(image); err != nil {
+		file.Close()
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	// Put it in place
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
 	
 	return nil
 }
@@ -55,4 +82,16 @@ func (s *LocalStorage) GetWordCloud(ctx context.Context, location string) ([]byt
 	}
 	
 	return image, nil
-}
\ No newline at end of file
+}
+
+// DeleteWordCloud removes a word cloud image from the local filesystem
+func (s *LocalStorage) DeleteWordCloud(ctx context.Context, location string) error {
+	fullPath := filepath.Join(s.basePath, location)
+
+	// Missing images are treated as already deleted
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package detect
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Text encodings reported by Detector
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingWindows1251 = "windows-1251"
+	EncodingUnknown     = "unknown"
+)
+
+// sniffLen is the number of leading bytes used to detect the MIME type
+const sniffLen = 512
+
+// Detector collects the size, MIME type and text encoding of content written to it.
+// It is meant to be fed through io.TeeReader while the content is streamed elsewhere,
+// so it never keeps more than the first few hundred bytes.
+type Detector struct {
+	size int64
+	head []byte
+
+	// pending holds an incomplete UTF-8 sequence at the end of the last write
+	pending   []byte
+	validUTF8 bool
+
+	// Bytes outside ASCII and, among them, the ones that are Cyrillic letters in Windows-1251
+	highBytes     int64
+	cyrillicBytes int64
+}
+
+// NewDetector creates a new Detector instance
+func NewDetector() *Detector {
+	return &Detector{validUTF8: true}
+}
+
+// Write inspects the next chunk of content
+func (d *Detector) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+
+	if len(d.head) < sniffLen {
+		d.head = append(d.head, p[:min(len(p), sniffLen-len(d.head))]...)
+	}
+
+	for _, b := range p {
+		if b < 0x80 {
+			continue
+		}
+		d.highBytes++
+		// А-я are 0xC0-0xFF, Ё and ё are 0xA8 and 0xB8
+		if b >= 0xC0 || b == 0xA8 || b == 0xB8 {
+			d.cyrillicBytes++
+		}
+	}
+
+	if d.validUTF8 {
+		d.checkUTF8(p)
+	}
+
+	return len(p), nil
+}
+
+// checkUTF8 validates the chunk, carrying a rune split between chunks over to the next write
+func (d *Detector) checkUTF8(p []byte) {
+	buf := p
+	if len(d.pending) > 0 {
+		buf = append(d.pending, p...)
+	}
+
+	// Find an incomplete rune at the end of the chunk
+	end := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+
+	if !utf8.Valid(buf[:end]) {
+		d.validUTF8 = false
+	}
+	d.pending = append(d.pending[:0], buf[end:]...)
+}
+
+// Size returns the number of bytes written so far
+func (d *Detector) Size() int64 {
+	return d.size
+}
+
+// MIMEType returns the detected MIME type; plain text includes the detected charset
+func (d *Detector) MIMEType() string {
+	contentType := http.DetectContentType(d.head)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "text/plain" {
+		return contentType
+	}
+
+	if encoding := d.Encoding(); encoding != EncodingUnknown {
+		return mime.FormatMediaType(mediaType, map[string]string{"charset": encoding})
+	}
+	return mediaType
+}
+
+// Encoding returns the detected text encoding, or an empty string for content that is not text
+func (d *Detector) Encoding() string {
+	if !strings.HasPrefix(http.DetectContentType(d.head), "text/") {
+		return ""
+	}
+
+	switch {
+	case d.validUTF8 && len(d.pending) == 0:
+		return EncodingUTF8
+	// Russian text in Windows-1251 consists almost entirely of Cyrillic letters outside ASCII
+	case d.highBytes > 0 && d.cyrillicBytes*10 >= d.highBytes*9:
+		return EncodingWindows1251
+	default:
+		return EncodingUnknown
+	}
+}
@@ -0,0 +1,99 @@
+package detect
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// toWindows1251 encodes Russian text in Windows-1251
+func toWindows1251(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			out = append(out, byte(r))
+		case r >= 'А' && r <= 'я':
+			out = append(out, byte(0xC0+r-'А'))
+		case r == 'Ё':
+			out = append(out, 0xA8)
+		case r == 'ё':
+			out = append(out, 0xB8)
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// detect feeds content to a Detector in small chunks, so runes are split between writes
+func detect(content []byte) *Detector {
+	d := NewDetector()
+	io.CopyBuffer(d, onlyReader{bytes.NewReader(content)}, make([]byte, 7))
+	return d
+}
+
+// onlyReader hides WriterTo, so io.CopyBuffer uses the given buffer
+type onlyReader struct{ io.Reader }
+
+func TestDetector(t *testing.T) {
+	report, err := os.ReadFile("../../../../texts/01-01.txt")
+	if err != nil {
+		t.Fatalf("failed to read sample report: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		content  []byte
+		mimeType string
+		encoding string
+	}{
+		{
+			name:     "UTF-8 report",
+			content:  report,
+			mimeType: "text/plain; charset=utf-8",
+			encoding: EncodingUTF8,
+		},
+		{
+			name:     "Windows-1251 report",
+			content:  toWindows1251(string(report)),
+			mimeType: "text/plain; charset=windows-1251",
+			encoding: EncodingWindows1251,
+		},
+		{
+			name:     "ASCII text",
+			content:  []byte("Plain English report."),
+			mimeType: "text/plain; charset=utf-8",
+			encoding: EncodingUTF8,
+		},
+		{
+			name:     "Binary",
+			content:  []byte{0x00, 0x01, 0x02, 0x03, 0xFF},
+			mimeType: "application/octet-stream",
+			encoding: "",
+		},
+		{
+			name:     "PDF",
+			content:  []byte("%PDF-1.4\n%\xE2\xE3\xCF\xD3"),
+			mimeType: "application/pdf",
+			encoding: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := detect(tt.content)
+
+			if got := d.Size(); got != int64(len(tt.content)) {
+				t.Errorf("Size() = %d, want %d", got, len(tt.content))
+			}
+			if got := d.MIMEType(); got != tt.mimeType {
+				t.Errorf("MIMEType() = %q, want %q", got, tt.mimeType)
+			}
+			if got := d.Encoding(); got != tt.encoding {
+				t.Errorf("Encoding() = %q, want %q", got, tt.encoding)
+			}
+		})
+	}
+}
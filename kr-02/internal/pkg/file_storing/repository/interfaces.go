@@ -2,16 +2,44 @@ package repository
 
 import (
 	"context"
+	"time"
 )
 
-// FileRepository defines the interface for file metadata operations
+// FileRepository defines the interface for file metadata operations.
+// An upload is recorded as a pending file first and only becomes visible once it is committed,
+// so uploads interrupted at any step can be found and cleaned up.
 type FileRepository interface {
-	// SaveFile saves file metadata to the database
-	SaveFile(ctx context.Context, id, name, hash, location string) error
-	
-	// GetFileByID retrieves file metadata by ID
-	GetFileByID(ctx context.Context, id string) (name string, location string, err error)
+	// CreatePendingFile records the ID, name and tags of a new upload before its content is stored
+	CreatePendingFile(ctx context.Context, file FileInfo) error
+
+	// PrepareFile saves the content details of a pending file and adds its reference to the blob
+	// with the given hash in one transaction, creating the blob record if needed
+	PrepareFile(ctx context.Context, file FileInfo) error
+
+	// CommitFile marks a pending file as committed once its content is in place, and sets its upload time
+	// to the commit time
+	CommitFile(ctx context.Context, id string) error
+
+	// ListPendingFiles retrieves pending files created before the given time
+	ListPendingFiles(ctx context.Context, createdBefore time.Time) ([]FileInfo, error)
 	
-	// GetFileByHash retrieves file metadata by hash
-	GetFileByHash(ctx context.Context, hash string) (id string, err error)
-}
\ No newline at end of file
+	// GetFileByID retrieves metadata of a committed file by ID
+	GetFileByID(ctx context.Context, id string) (FileInfo, error)
+
+	// DeleteFile deletes file metadata, pending or committed, and removes its reference to the blob
+	DeleteFile(ctx context.Context, id string) error
+
+	// ListFiles retrieves metadata of committed files matching the filters, sorted and limited as requested
+	ListFiles(ctx context.Context, params ListFilesParams) ([]FileInfo, error)
+
+	// RepairBlobRefCounts sets the reference count of every blob to the number of files referring to it
+	// and returns how many were wrong
+	RepairBlobRefCounts(ctx context.Context) (int, error)
+
+	// DeleteUnreferencedBlobs removes the records of blobs that no file refers to.
+	// deleteContent is called for each blob before its record is removed; if it fails, the blob is kept.
+	DeleteUnreferencedBlobs(ctx context.Context, deleteContent func(location string) error) (int, error)
+
+	// ListBlobLocations retrieves the storage locations of all blobs
+	ListBlobLocations(ctx context.Context) ([]string, error)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFileNotFound is returned when no file has the requested ID
+var ErrFileNotFound = errors.New("file not found")
+
+// FileTags identify who uploaded a file and for which course and assignment
+type FileTags struct {
+	Uploader   string
+	Course     string
+	Assignment string
+}
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	ID        string
+	Name      string
+	Hash      string
+	Location  string
+	Size      int64
+	MIMEType  string
+	Encoding  string
+	Tags      FileTags
+	CreatedAt time.Time
+}
+
+// SortField is a column files can be sorted by
+type SortField int
+
+const (
+	// SortByCreatedAt sorts files by upload time
+	SortByCreatedAt SortField = iota
+	// SortByName sorts files by name
+	SortByName
+	// SortBySize sorts files by size
+	SortBySize
+)
+
+// FileCursor points at the last file of a page; the next page starts right after it
+type FileCursor struct {
+	// Value is the sort field value of the last file, formatted as text
+	Value string
+	ID    string
+}
+
+// ListFilesParams contains the filters, sorting and pagination of a file listing.
+// Zero values mean that the corresponding filter is not applied.
+type ListFilesParams struct {
+	NameContains   string
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	MinSize        int64
+	MaxSize        int64
+
+	SortBy     SortField
+	Descending bool
+
+	Limit int
+	After *FileCursor
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"kr-02/internal/pkg/file_storing/repository"
 )
@@ -19,47 +21,350 @@ func NewFileRepo(db *sql.DB) repository.FileRepository {
 	return &FileRepo{db: db}
 }
 
-// SaveFile saves file metadata to the database
-func (r *FileRepo) SaveFile(ctx context.Context, id, name, hash, location string) error {
+// fileColumns are the columns scanned by scanFile
+const fileColumns = `id, name, hash, location, size, mime_type, encoding, uploader, course, assignment, created_at`
+
+// CreatePendingFile records the ID, name and tags of a new upload before its content is stored
+func (r *FileRepo) CreatePendingFile(ctx context.Context, file repository.FileInfo) error {
 	query := `
-		INSERT INTO files (id, name, hash, location, created_at)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		INSERT INTO files (id, name, hash, location, uploader, course, assignment, state, created_at)
+		VALUES ($1, $2, '', '', $3, $4, $5, 'pending', CURRENT_TIMESTAMP)
+	`
+	_, err := r.db.ExecContext(ctx, query, file.ID, file.Name, file.Tags.Uploader, file.Tags.Course, file.Tags.Assignment)
+	if err != nil {
+		return fmt.Errorf("failed to create pending file: %w", err)
+	}
+	return nil
+}
+
+// PrepareFile saves the content details of a pending file and adds its reference to the blob in one transaction
+func (r *FileRepo) PrepareFile(ctx context.Context, file repository.FileInfo) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE files SET hash = $2, location = $3, size = $4, mime_type = $5, encoding = $6
+		WHERE id = $1 AND state = 'pending' AND hash = ''
+	`
+	result, err := tx.ExecContext(ctx, query, file.ID, file.Hash, file.Location, file.Size, file.MIMEType, file.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to save file details: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("%w: no pending file with id %s", repository.ErrFileNotFound, file.ID)
+	}
+
+	query = `
+		INSERT INTO blobs (hash, location, ref_count, created_at)
+		VALUES ($1, $2, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (hash) DO UPDATE SET
+			ref_count = blobs.ref_count + 1
 	`
-	_, err := r.db.ExecContext(ctx, query, id, name, hash, location)
+	if _, err := tx.ExecContext(ctx, query, file.Hash, file.Location); err != nil {
+		return fmt.Errorf("failed to acquire blob: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CommitFile marks a pending file as committed once its content is in place. The file counts as uploaded
+// from then on, so its upload time is the commit time: files listed by upload time appear in commit order,
+// however long their contents took to store.
+func (r *FileRepo) CommitFile(ctx context.Context, id string) error {
+	query := `UPDATE files SET state = 'committed', created_at = CURRENT_TIMESTAMP WHERE id = $1 AND hash <> ''`
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to save file metadata: %w", err)
+		return fmt.Errorf("failed to commit file: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("%w: no prepared file with id %s", repository.ErrFileNotFound, id)
 	}
 	return nil
 }
 
+// ListPendingFiles retrieves pending files created before the given time
+func (r *FileRepo) ListPendingFiles(ctx context.Context, createdBefore time.Time) ([]repository.FileInfo, error) {
+	query := `
+		SELECT ` + fileColumns + ` FROM files
+		WHERE state = 'pending' AND created_at < $1
+		ORDER BY created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []repository.FileInfo
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending file: %w", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over pending files: %w", err)
+	}
+	return files, nil
+}
+
 // GetFileByID retrieves file metadata by ID
-func (r *FileRepo) GetFileByID(ctx context.Context, id string) (string, string, error) {
+func (r *FileRepo) GetFileByID(ctx context.Context, id string) (repository.FileInfo, error) {
 	query := `
-		SELECT name, location FROM files WHERE id = $1
+		SELECT ` + fileColumns + ` FROM files WHERE id = $1 AND state = 'committed'
 	`
-	var name, location string
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&name, &location)
+	file, err := scanFile(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", "", fmt.Errorf("file not found with id %s", id)
+			return repository.FileInfo{}, fmt.Errorf("%w with id %s", repository.ErrFileNotFound, id)
 		}
-		return "", "", fmt.Errorf("failed to get file by id: %w", err)
+		return repository.FileInfo{}, fmt.Errorf("failed to get file by id: %w", err)
 	}
-	return name, location, nil
+	return file, nil
+}
+
+// scanFile scans a row of fileColumns
+func scanFile(row interface{ Scan(dest ...any) error }) (repository.FileInfo, error) {
+	var file repository.FileInfo
+	err := row.Scan(
+		&file.ID, &file.Name, &file.Hash, &file.Location, &file.Size, &file.MIMEType, &file.Encoding,
+		&file.Tags.Uploader, &file.Tags.Course, &file.Tags.Assignment, &file.CreatedAt,
+	)
+	return file, err
 }
 
-// GetFileByHash retrieves file metadata by hash
-func (r *FileRepo) GetFileByHash(ctx context.Context, hash string) (string, error) {
+// DeleteFile deletes file metadata and removes its reference to the blob in one transaction
+func (r *FileRepo) DeleteFile(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hash string
+	err = tx.QueryRowContext(ctx, `DELETE FROM files WHERE id = $1 RETURNING hash`, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w with id %s", repository.ErrFileNotFound, id)
+		}
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+
 	query := `
-		SELECT id FROM files WHERE hash = $1
+		UPDATE blobs SET ref_count = ref_count - 1
+		WHERE hash = $1 AND ref_count > 0
 	`
-	var id string
-	err := r.db.QueryRowContext(ctx, query, hash).Scan(&id)
+	if _, err := tx.ExecContext(ctx, query, hash); err != nil {
+		return fmt.Errorf("failed to release blob: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// sortColumns maps sort fields to their columns and the SQL types cursor values are cast to
+var sortColumns = map[repository.SortField][2]string{
+	repository.SortByCreatedAt: {"created_at", "TIMESTAMP"},
+	repository.SortByName:      {"name", "TEXT"},
+	repository.SortBySize:      {"size", "BIGINT"},
+}
+
+// ListFiles retrieves metadata of files matching the filters, sorted and limited as requested.
+// Pagination is keyset-based: the next page starts after the (sort value, id) pair of the cursor.
+func (r *FileRepo) ListFiles(ctx context.Context, params repository.ListFilesParams) ([]repository.FileInfo, error) {
+	column, ok := sortColumns[params.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %d", params.SortBy)
+	}
+
+	conditions := []string{"state = 'committed'"}
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if params.NameContains != "" {
+		addCondition("name ILIKE '%%' || $%d || '%%'", escapeLike(params.NameContains))
+	}
+	if !params.UploadedAfter.IsZero() {
+		addCondition("created_at >= $%d", params.UploadedAfter)
+	}
+	if !params.UploadedBefore.IsZero() {
+		addCondition("created_at < $%d", params.UploadedBefore)
+	}
+	if params.MinSize > 0 {
+		addCondition("size >= $%d", params.MinSize)
+	}
+	if params.MaxSize > 0 {
+		addCondition("size <= $%d", params.MaxSize)
+	}
+
+	direction, comparison := "ASC", ">"
+	if params.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if params.After != nil {
+		args = append(args, params.After.Value, params.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			column[0], comparison, len(args)-1, column[1], len(args)))
+	}
+
+	query := `SELECT ` + fileColumns + ` FROM files WHERE ` + strings.Join(conditions, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", column[0], direction, direction)
+	if params.Limit > 0 {
+		args = append(args, params.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []repository.FileInfo
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over files: %w", err)
+	}
+
+	return files, nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// RepairBlobRefCounts sets the reference count of every blob to the number of files referring to it.
+// The blobs table is locked meanwhile, so no upload or deletion changes a count in between.
+func (r *FileRepo) RepairBlobRefCounts(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE blobs IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return 0, fmt.Errorf("failed to lock blobs: %w", err)
+	}
+
+	query := `
+		UPDATE blobs b SET ref_count = c.count
+		FROM (
+			SELECT b.hash, COUNT(f.id) AS count
+			FROM blobs b LEFT JOIN files f ON f.hash = b.hash
+			GROUP BY b.hash
+		) c
+		WHERE b.hash = c.hash AND b.ref_count <> c.count
+	`
+	result, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to repair blob reference counts: %w", err)
+	}
+	repaired, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to repair blob reference counts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(repaired), nil
+}
+
+// ListBlobLocations retrieves the storage locations of all blobs
+func (r *FileRepo) ListBlobLocations(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT location FROM blobs ORDER BY hash`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blob locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			return nil, fmt.Errorf("failed to scan blob location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over blob locations: %w", err)
+	}
+	return locations, nil
+}
+
+// DeleteUnreferencedBlobs removes the records of blobs that no file refers to.
+// Each blob is locked while its content is deleted, so a concurrent PrepareFile
+// waits for the deletion to finish and then recreates the record.
+func (r *FileRepo) DeleteUnreferencedBlobs(ctx context.Context, deleteContent func(location string) error) (int, error) {
+	query := `
+		SELECT hash, location FROM blobs b
+		WHERE ref_count <= 0
+			AND NOT EXISTS (SELECT 1 FROM files f WHERE f.hash = b.hash)
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`
+	deleted := 0
+	for {
+		done, err := r.deleteUnreferencedBlob(ctx, query, deleteContent)
+		if err != nil {
+			return deleted, err
+		}
+		if done {
+			return deleted, nil
+		}
+		deleted++
+	}
+}
+
+// deleteUnreferencedBlob deletes a single unreferenced blob in its own transaction.
+// It reports done when there are no more blobs to delete.
+func (r *FileRepo) deleteUnreferencedBlob(ctx context.Context, query string, deleteContent func(location string) error) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hash, location string
+	err = tx.QueryRowContext(ctx, query).Scan(&hash, &location)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", nil // No error, just no file with this hash
+			return true, nil
 		}
-		return "", fmt.Errorf("failed to get file by hash: %w", err)
+		return false, fmt.Errorf("failed to find unreferenced blob: %w", err)
 	}
-	return id, nil
-}
\ No newline at end of file
+
+	if err := deleteContent(location); err != nil {
+		return false, fmt.Errorf("failed to delete content of blob %s: %w", hash, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blobs WHERE hash = $1`, hash); err != nil {
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return false, nil
+}
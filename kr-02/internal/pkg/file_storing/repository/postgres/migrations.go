@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// MigrationsComponent names the file storing schema in the schema_migrations table
+const MigrationsComponent = "file_storing"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the schema migrations of the file storing database
+func Migrations() fs.FS {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blobs;
+
+DROP INDEX IF EXISTS files_hash_idx;
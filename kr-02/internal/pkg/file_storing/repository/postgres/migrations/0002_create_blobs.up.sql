@@ -0,0 +1,13 @@
+CREATE INDEX IF NOT EXISTS files_hash_idx ON files (hash);
+
+CREATE TABLE IF NOT EXISTS blobs (
+    hash TEXT PRIMARY KEY,
+    location TEXT NOT NULL,
+    ref_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Register blobs of files uploaded before contents were stored by hash
+INSERT INTO blobs (hash, location, ref_count)
+SELECT hash, MIN(location), COUNT(*) FROM files GROUP BY hash
+ON CONFLICT (hash) DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS files_name_trgm_idx;
+DROP INDEX IF EXISTS files_size_idx;
+DROP INDEX IF EXISTS files_name_idx;
+DROP INDEX IF EXISTS files_created_at_idx;
+
+ALTER TABLE files DROP COLUMN IF EXISTS size;
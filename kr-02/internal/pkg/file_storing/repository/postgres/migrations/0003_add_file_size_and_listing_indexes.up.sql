@@ -0,0 +1,11 @@
+-- Size is needed for listing and filtering files
+ALTER TABLE files ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+
+-- Indexes for listing files sorted by each supported field
+CREATE INDEX IF NOT EXISTS files_created_at_idx ON files (created_at, id);
+CREATE INDEX IF NOT EXISTS files_name_idx ON files (name, id);
+CREATE INDEX IF NOT EXISTS files_size_idx ON files (size, id);
+
+-- Trigram index for searching by name substring
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS files_name_trgm_idx ON files USING gin (name gin_trgm_ops);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS files_course_assignment_idx;
+
+ALTER TABLE files
+    DROP COLUMN IF EXISTS assignment,
+    DROP COLUMN IF EXISTS course,
+    DROP COLUMN IF EXISTS uploader,
+    DROP COLUMN IF EXISTS encoding,
+    DROP COLUMN IF EXISTS mime_type;
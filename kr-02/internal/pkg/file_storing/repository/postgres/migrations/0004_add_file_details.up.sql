@@ -0,0 +1,9 @@
+ALTER TABLE files
+    ADD COLUMN mime_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN encoding TEXT NOT NULL DEFAULT '',
+    ADD COLUMN uploader TEXT NOT NULL DEFAULT '',
+    ADD COLUMN course TEXT NOT NULL DEFAULT '',
+    ADD COLUMN assignment TEXT NOT NULL DEFAULT '';
+
+-- Files are often listed per course and assignment
+CREATE INDEX files_course_assignment_idx ON files (course, assignment);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS files_pending_idx;
+
+-- Pending uploads were never visible and would appear complete without the state
+DELETE FROM files WHERE state = 'pending';
+
+ALTER TABLE files DROP COLUMN IF EXISTS state;
@@ -0,0 +1,5 @@
+-- Existing files are complete; new uploads are inserted as pending and committed once their content is in place
+ALTER TABLE files ADD COLUMN state TEXT NOT NULL DEFAULT 'committed'
+    CHECK (state IN ('pending', 'committed'));
+
+CREATE INDEX files_pending_idx ON files (created_at) WHERE state = 'pending';
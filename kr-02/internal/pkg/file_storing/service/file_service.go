@@ -5,75 +5,224 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"path"
+
 	"github.com/google/uuid"
 
+	"kr-02/internal/pkg/file_storing/detect"
 	"kr-02/internal/pkg/file_storing/repository"
 	"kr-02/internal/pkg/file_storing/storage"
+	"kr-02/internal/pkg/file_storing/validation"
 )
 
 // FileService handles the business logic for file operations
 type FileService struct {
-	repo    repository.FileRepository
-	storage storage.FileStorage
+	repo      repository.FileRepository
+	storage   storage.FileStorage
+	validator validation.Validator
 }
 
-// NewFileService creates a new FileService instance
-func NewFileService(repo repository.FileRepository, storage storage.FileStorage) *FileService {
+// NewFileService creates a new FileService instance.
+// Uploads rejected by the validator are discarded and reported as *validation.Error.
+func NewFileService(repo repository.FileRepository, storage storage.FileStorage, validator validation.Validator) *FileService {
 	return &FileService{
-		repo:    repo,
-		storage: storage,
+		repo:      repo,
+		storage:   storage,
+		validator: validator,
 	}
 }
 
-// UploadFile handles the file upload process
-func (s *FileService) UploadFile(ctx context.Context, fileName string, content []byte) (string, error) {
-	// Calculate file hash
-	hash := sha256.Sum256(content)
-	hashStr := hex.EncodeToString(hash[:])
+// UploadFile handles the file upload process.
+// The content is streamed to a temporary location while its hash is calculated, so it is never held in memory.
+// Contents are stored once per hash, while every upload gets its own file record referencing the blob.
+// Size, MIME type and encoding are detected from the content itself.
+// The file record is pending until the content is in place; Reconcile cleans up after interrupted uploads.
+func (s *FileService) UploadFile(ctx context.Context, fileName string, tags repository.FileTags, content io.Reader) (string, error) {
+	// Generate a new file ID and record the upload before anything is stored
+	file := repository.FileInfo{
+		ID:   uuid.New().String(),
+		Name: fileName,
+		Tags: tags,
+	}
+	if err := s.repo.CreatePendingFile(ctx, file); err != nil {
+		return "", fmt.Errorf("failed to save file metadata: %w", err)
+	}
 
-	// Check if file with this hash already exists
-	fileID, err := s.repo.GetFileByHash(ctx, hashStr)
-	if err != nil {
-		return "", fmt.Errorf("failed to check file existence: %w", err)
+	// Enforce the size limit while reading, so oversized uploads are never stored in full
+	var limited *validation.LimitedReader
+	if limiter, ok := s.validator.(validation.SizeLimiter); ok && limiter.SizeLimit() > 0 {
+		limited = &validation.LimitedReader{R: content, N: limiter.SizeLimit()}
+		content = limited
 	}
 
-	// If file exists, return its ID
-	if fileID != "" {
-		return fileID, nil
+	// Save file content to a temporary location, calculating the hash and detecting the details on the way
+	tempLocation := tempLocation(file.ID)
+	hasher := sha256.New()
+	detector := detect.NewDetector()
+	if err := s.storage.SaveFile(ctx, tempLocation, io.TeeReader(content, io.MultiWriter(hasher, detector))); err != nil {
+		s.abortUpload(ctx, file.ID)
+		if limited != nil && limited.Err() != nil {
+			return "", limited.Err()
+		}
+		return "", fmt.Errorf("failed to save file content: %w", err)
 	}
+	file.Hash = hex.EncodeToString(hasher.Sum(nil))
+	file.Location = blobLocation(file.Hash)
+	file.Size = detector.Size()
+	file.MIMEType = detector.MIMEType()
+	file.Encoding = detector.Encoding()
 
-	// Generate a new file ID
-	fileID = uuid.New().String()
+	// Reject content the service does not accept
+	err := s.validator.Validate(validation.Upload{
+		FileName: file.Name,
+		Size:     file.Size,
+		MIMEType: file.MIMEType,
+		Encoding: file.Encoding,
+	})
+	if err != nil {
+		s.discardContent(ctx, tempLocation)
+		s.abortUpload(ctx, file.ID)
+		return "", err
+	}
 
-	// Define file location
-	location := fileID // Using fileID as location for simplicity
+	// Reference the blob before its content is put in place, so garbage collection cannot remove it meanwhile
+	if err := s.repo.PrepareFile(ctx, file); err != nil {
+		s.discardContent(ctx, tempLocation)
+		s.abortUpload(ctx, file.ID)
+		return "", fmt.Errorf("failed to save file metadata: %w", err)
+	}
 
-	// Save file content to storage
-	if err := s.storage.SaveFile(ctx, location, content); err != nil {
+	// Move the content to its content-addressed location; identical content may already be there
+	if err := s.storage.MoveFile(ctx, tempLocation, file.Location); err != nil {
+		s.discardContent(ctx, tempLocation)
+		s.abortUpload(ctx, file.ID)
 		return "", fmt.Errorf("failed to save file content: %w", err)
 	}
 
-	// Save file metadata to repository
-	if err := s.repo.SaveFile(ctx, fileID, fileName, hashStr, location); err != nil {
+	// Make the file visible
+	if err := s.repo.CommitFile(ctx, file.ID); err != nil {
+		s.abortUpload(ctx, file.ID)
 		return "", fmt.Errorf("failed to save file metadata: %w", err)
 	}
 
-	return fileID, nil
+	return file.ID, nil
 }
 
-// GetFile retrieves a file by its ID
-func (s *FileService) GetFile(ctx context.Context, fileID string) (string, []byte, error) {
+// GetFile retrieves a file by its ID.
+// The returned reader streams the content from storage and must be closed by the caller.
+func (s *FileService) GetFile(ctx context.Context, fileID string) (repository.FileInfo, io.ReadCloser, error) {
 	// Get file metadata from repository
-	fileName, location, err := s.repo.GetFileByID(ctx, fileID)
+	file, err := s.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return repository.FileInfo{}, nil, err
+	}
+
+	// Open file content in storage
+	content, err := s.storage.GetFile(ctx, file.Location)
+	if err != nil {
+		return repository.FileInfo{}, nil, fmt.Errorf("failed to get file content: %w", err)
+	}
+
+	return file, content, nil
+}
+
+// GetFileMetadata retrieves the metadata of a file without opening its content
+func (s *FileService) GetFileMetadata(ctx context.Context, fileID string) (repository.FileInfo, error) {
+	file, err := s.repo.GetFileByID(ctx, fileID)
+	if err != nil {
+		return repository.FileInfo{}, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+	return file, nil
+}
+
+// DeleteFile deletes a file by its ID.
+// The content is removed from storage as soon as no other file refers to it.
+func (s *FileService) DeleteFile(ctx context.Context, fileID string) error {
+	if err := s.repo.DeleteFile(ctx, fileID); err != nil {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+
+	// The file is gone at this point; leftover content is picked up by the next collection otherwise
+	if _, err := s.CollectGarbage(ctx); err != nil {
+		fmt.Printf("Failed to remove content of deleted file %s: %v\n", fileID, err)
+	}
+
+	return nil
+}
+
+// ListFiles returns a page of files matching the filters along with the token of the next page.
+// The token is empty on the last page.
+func (s *FileService) ListFiles(ctx context.Context, params repository.ListFilesParams, pageSize int, pageToken string) ([]repository.FileInfo, string, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	if pageToken != "" {
+		cursor, err := decodePageToken(pageToken, params.SortBy, params.Descending)
+		if err != nil {
+			return nil, "", err
+		}
+		params.After = cursor
+	}
+
+	// Fetch one extra file to find out whether there is a next page
+	params.Limit = pageSize + 1
+	files, err := s.repo.ListFiles(ctx, params)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get file metadata: %w", err)
+		return nil, "", fmt.Errorf("failed to list files: %w", err)
+	}
+
+	if len(files) <= pageSize {
+		return files, "", nil
 	}
 
-	// Get file content from storage
-	content, err := s.storage.GetFile(ctx, location)
+	files = files[:pageSize]
+	nextPageToken, err := encodePageToken(files[pageSize-1], params.SortBy, params.Descending)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get file content: %w", err)
+		return nil, "", err
 	}
+	return files, nextPageToken, nil
+}
 
-	return fileName, content, nil
-}
\ No newline at end of file
+// CollectGarbage removes the contents no file refers to anymore and returns how many were removed
+func (s *FileService) CollectGarbage(ctx context.Context) (int, error) {
+	deleted, err := s.repo.DeleteUnreferencedBlobs(ctx, func(location string) error {
+		return s.storage.DeleteFile(ctx, location)
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("failed to collect garbage: %w", err)
+	}
+	return deleted, nil
+}
+
+// discardContent removes content that was written to storage but will not be referenced
+func (s *FileService) discardContent(ctx context.Context, location string) {
+	if err := s.storage.DeleteFile(ctx, location); err != nil {
+		fmt.Printf("Failed to discard file content at %s: %v\n", location, err)
+	}
+}
+
+// abortUpload removes the record of a failed upload along with its blob reference, if any.
+// If this fails too, the pending record is left to Reconcile.
+func (s *FileService) abortUpload(ctx context.Context, fileID string) {
+	if err := s.repo.DeleteFile(ctx, fileID); err != nil {
+		fmt.Printf("Failed to remove record of failed upload %s: %v\n", fileID, err)
+	}
+}
+
+// tempDir is the storage directory uploads are written to before their hash is known
+const tempDir = "tmp"
+
+// tempLocation returns the temporary storage location of an upload
+func tempLocation(fileID string) string {
+	return path.Join(tempDir, fileID)
+}
+
+// blobLocation returns the content-addressed storage location for a hash, e.g. ab/cd/abcd...
+func blobLocation(hash string) string {
+	return path.Join(hash[:2], hash[2:4], hash)
+}
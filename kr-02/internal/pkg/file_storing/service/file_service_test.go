@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_storing/repository"
+	"kr-02/internal/pkg/file_storing/storage/local"
+	"kr-02/internal/pkg/file_storing/validation"
+)
+
+// fakeFileRepo is an in-memory FileRepository
+type fakeFileRepo struct {
+	files         map[string]repository.FileInfo
+	pending       map[string]bool
+	blobs         map[string]int // hash -> ref count
+	blobLocations map[string]string
+}
+
+func newFakeFileRepo() *fakeFileRepo {
+	return &fakeFileRepo{
+		files:         make(map[string]repository.FileInfo),
+		pending:       make(map[string]bool),
+		blobs:         make(map[string]int),
+		blobLocations: make(map[string]string),
+	}
+}
+
+func (r *fakeFileRepo) CreatePendingFile(ctx context.Context, file repository.FileInfo) error {
+	file.CreatedAt = time.Now()
+	r.files[file.ID] = file
+	r.pending[file.ID] = true
+	return nil
+}
+
+func (r *fakeFileRepo) PrepareFile(ctx context.Context, file repository.FileInfo) error {
+	if !r.pending[file.ID] || r.files[file.ID].Hash != "" {
+		return repository.ErrFileNotFound
+	}
+	file.CreatedAt = r.files[file.ID].CreatedAt
+	r.files[file.ID] = file
+	r.blobs[file.Hash]++
+	r.blobLocations[file.Hash] = file.Location
+	return nil
+}
+
+func (r *fakeFileRepo) CommitFile(ctx context.Context, id string) error {
+	if file, ok := r.files[id]; !ok || file.Hash == "" {
+		return repository.ErrFileNotFound
+	}
+	delete(r.pending, id)
+	return nil
+}
+
+func (r *fakeFileRepo) ListPendingFiles(ctx context.Context, createdBefore time.Time) ([]repository.FileInfo, error) {
+	var files []repository.FileInfo
+	for id := range r.pending {
+		if r.files[id].CreatedAt.Before(createdBefore) {
+			files = append(files, r.files[id])
+		}
+	}
+	return files, nil
+}
+
+func (r *fakeFileRepo) GetFileByID(ctx context.Context, id string) (repository.FileInfo, error) {
+	file, ok := r.files[id]
+	if !ok || r.pending[id] {
+		return repository.FileInfo{}, repository.ErrFileNotFound
+	}
+	return file, nil
+}
+
+func (r *fakeFileRepo) DeleteFile(ctx context.Context, id string) error {
+	file, ok := r.files[id]
+	if !ok {
+		return repository.ErrFileNotFound
+	}
+	if file.Hash != "" {
+		r.blobs[file.Hash]--
+	}
+	delete(r.files, id)
+	delete(r.pending, id)
+	return nil
+}
+
+func (r *fakeFileRepo) ListFiles(ctx context.Context, params repository.ListFilesParams) ([]repository.FileInfo, error) {
+	// Files are listed by ID, which is enough to check pagination
+	var ids []string
+	for id := range r.files {
+		if !r.pending[id] && (params.After == nil || id > params.After.ID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > params.Limit {
+		ids = ids[:params.Limit]
+	}
+
+	var files []repository.FileInfo
+	for _, id := range ids {
+		files = append(files, r.files[id])
+	}
+	return files, nil
+}
+
+func (r *fakeFileRepo) RepairBlobRefCounts(ctx context.Context) (int, error) {
+	counts := make(map[string]int)
+	for _, file := range r.files {
+		counts[file.Hash]++
+	}
+	repaired := 0
+	for hash, refs := range r.blobs {
+		if refs != counts[hash] {
+			r.blobs[hash] = counts[hash]
+			repaired++
+		}
+	}
+	return repaired, nil
+}
+
+func (r *fakeFileRepo) DeleteUnreferencedBlobs(ctx context.Context, deleteContent func(location string) error) (int, error) {
+	deleted := 0
+	for hash, refs := range r.blobs {
+		if refs > 0 {
+			continue
+		}
+		if err := deleteContent(r.blobLocations[hash]); err != nil {
+			return deleted, err
+		}
+		delete(r.blobs, hash)
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (r *fakeFileRepo) ListBlobLocations(ctx context.Context) ([]string, error) {
+	var locations []string
+	for _, location := range r.blobLocations {
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+func newTestFileService(t *testing.T) (*FileService, *fakeFileRepo, string) {
+	return newValidatingTestFileService(t, validation.Chain{})
+}
+
+func newValidatingTestFileService(t *testing.T, validator validation.Validator) (*FileService, *fakeFileRepo, string) {
+	dir := t.TempDir()
+	fileStorage, err := local.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	repo := newFakeFileRepo()
+	return NewFileService(repo, fileStorage, validator), repo, dir
+}
+
+func TestFileService_UploadFile_DeduplicatesContent(t *testing.T) {
+	fileService, repo, dir := newTestFileService(t)
+	ctx := context.Background()
+
+	firstID, err := fileService.UploadFile(ctx, "ivanov.txt", repository.FileTags{}, strings.NewReader("same report"))
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	secondID, err := fileService.UploadFile(ctx, "petrov.txt", repository.FileTags{}, strings.NewReader("same report"))
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+
+	// Every upload gets its own ID and name
+	if firstID == secondID {
+		t.Fatalf("identical uploads got the same ID %s", firstID)
+	}
+	for id, want := range map[string]string{firstID: "ivanov.txt", secondID: "petrov.txt"} {
+		file, content, err := fileService.GetFile(ctx, id)
+		if err != nil {
+			t.Fatalf("GetFile(%s) error = %v", id, err)
+		}
+		data, _ := io.ReadAll(content)
+		content.Close()
+		if file.Name != want || string(data) != "same report" {
+			t.Errorf("GetFile(%s) = %q, %q; want %q, %q", id, file.Name, data, want, "same report")
+		}
+	}
+
+	// The bytes are stored once, under their hash
+	hash := repo.files[firstID].Hash
+	if repo.blobs[hash] != 2 {
+		t.Errorf("blob ref count = %d, want 2", repo.blobs[hash])
+	}
+	if _, err := os.Stat(filepath.Join(dir, hash[:2], hash[2:4], hash)); err != nil {
+		t.Errorf("blob is not stored at its content-addressed location: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, tempDir)); len(entries) != 0 {
+		t.Errorf("temporary uploads left behind: %d", len(entries))
+	}
+}
+
+func TestFileService_CollectGarbage(t *testing.T) {
+	fileService, repo, dir := newTestFileService(t)
+	ctx := context.Background()
+
+	fileID, err := fileService.UploadFile(ctx, "report.txt", repository.FileTags{}, strings.NewReader("report"))
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	hash := repo.files[fileID].Hash
+
+	// Referenced blobs are kept
+	if deleted, err := fileService.CollectGarbage(ctx); err != nil || deleted != 0 {
+		t.Fatalf("CollectGarbage() = %d, %v; want 0, nil", deleted, err)
+	}
+
+	// Unreferenced blobs are removed from storage
+	repo.DeleteFile(ctx, fileID)
+	if deleted, err := fileService.CollectGarbage(ctx); err != nil || deleted != 1 {
+		t.Fatalf("CollectGarbage() = %d, %v; want 1, nil", deleted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, blobLocation(hash))); !os.IsNotExist(err) {
+		t.Errorf("unreferenced blob still exists: %v", err)
+	}
+}
+
+func TestFileService_ListFiles_Pagination(t *testing.T) {
+	fileService, _, _ := newTestFileService(t)
+	ctx := context.Background()
+
+	for _, content := range []string{"first", "second", "third"} {
+		if _, err := fileService.UploadFile(ctx, content+".txt", repository.FileTags{}, strings.NewReader(content)); err != nil {
+			t.Fatalf("UploadFile() error = %v", err)
+		}
+	}
+
+	params := repository.ListFilesParams{SortBy: repository.SortByName}
+	var seen []string
+	pageToken := ""
+	for page := 0; page < 3; page++ {
+		files, next, err := fileService.ListFiles(ctx, params, 2, pageToken)
+		if err != nil {
+			t.Fatalf("ListFiles() error = %v", err)
+		}
+		for _, file := range files {
+			seen = append(seen, file.ID)
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	if len(seen) != 3 {
+		t.Errorf("ListFiles() returned %d files over all pages, want 3", len(seen))
+	}
+
+	// A token cannot be reused with another sort order
+	params.SortBy = repository.SortBySize
+	if _, _, err := fileService.ListFiles(ctx, params, 2, pageToken); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("ListFiles() with changed sort order error = %v, want %v", err, ErrInvalidPageToken)
+	}
+	if _, _, err := fileService.ListFiles(ctx, params, 2, "not a token"); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("ListFiles() with malformed token error = %v, want %v", err, ErrInvalidPageToken)
+	}
+}
+
+func TestFileService_DeleteFile(t *testing.T) {
+	fileService, repo, dir := newTestFileService(t)
+	ctx := context.Background()
+
+	firstID, _ := fileService.UploadFile(ctx, "ivanov.txt", repository.FileTags{}, strings.NewReader("same report"))
+	secondID, _ := fileService.UploadFile(ctx, "petrov.txt", repository.FileTags{}, strings.NewReader("same report"))
+	location := filepath.Join(dir, blobLocation(repo.files[firstID].Hash))
+
+	// Content shared with another file is kept
+	if err := fileService.DeleteFile(ctx, firstID); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if _, err := os.Stat(location); err != nil {
+		t.Errorf("shared content was removed: %v", err)
+	}
+	if _, _, err := fileService.GetFile(ctx, firstID); !errors.Is(err, repository.ErrFileNotFound) {
+		t.Errorf("GetFile() of deleted file error = %v, want %v", err, repository.ErrFileNotFound)
+	}
+
+	// Content is removed with its last file
+	if err := fileService.DeleteFile(ctx, secondID); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if _, err := os.Stat(location); !os.IsNotExist(err) {
+		t.Errorf("unreferenced content still exists: %v", err)
+	}
+
+	if err := fileService.DeleteFile(ctx, secondID); !errors.Is(err, repository.ErrFileNotFound) {
+		t.Errorf("DeleteFile() of missing file error = %v, want %v", err, repository.ErrFileNotFound)
+	}
+}
+
+func TestFileService_UploadFile_RecordsDetails(t *testing.T) {
+	fileService, _, _ := newTestFileService(t)
+	ctx := context.Background()
+
+	tags := repository.FileTags{Uploader: "ivanov", Course: "kpo", Assignment: "kr-02"}
+	fileID, err := fileService.UploadFile(ctx, "report.txt", tags, strings.NewReader("Отчёт по контрольной работе"))
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+
+	file, err := fileService.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		t.Fatalf("GetFileMetadata() error = %v", err)
+	}
+	if file.Size != int64(len("Отчёт по контрольной работе")) {
+		t.Errorf("Size = %d, want %d", file.Size, len("Отчёт по контрольной работе"))
+	}
+	if file.MIMEType != "text/plain; charset=utf-8" || file.Encoding != "utf-8" {
+		t.Errorf("MIMEType, Encoding = %q, %q; want %q, %q", file.MIMEType, file.Encoding, "text/plain; charset=utf-8", "utf-8")
+	}
+	if file.Tags != tags {
+		t.Errorf("Tags = %+v, want %+v", file.Tags, tags)
+	}
+}
+
+func TestFileService_UploadFile_Validation(t *testing.T) {
+	validator := validation.Chain{
+		validation.NotEmpty{},
+		validation.MaxSize(16),
+		validation.AllowedExtensions{".txt"},
+		validation.AllowedEncodings{"utf-8"},
+	}
+
+	tests := []struct {
+		name       string
+		fileName   string
+		content    string
+		wantReason validation.Reason
+	}{
+		{"accepted", "report.txt", "short report", ""},
+		{"empty", "report.txt", "", validation.ReasonEmptyFile},
+		{"too large", "report.txt", strings.Repeat("a", 17), validation.ReasonFileTooLarge},
+		{"extension", "report.pdf", "short report", validation.ReasonUnsupportedFileType},
+		{"encoding", "report.txt", "\xcf\xf0\xe8\xe2\xe5\xf2 \xff\xfe", validation.ReasonInvalidEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileService, repo, dir := newValidatingTestFileService(t, validator)
+
+			_, err := fileService.UploadFile(context.Background(), tt.fileName, repository.FileTags{}, strings.NewReader(tt.content))
+			var validationErr *validation.Error
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("UploadFile() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &validationErr) || validationErr.Reason != tt.wantReason {
+				t.Fatalf("UploadFile() error = %v, want reason %s", err, tt.wantReason)
+			}
+
+			// Rejected content is not kept
+			if len(repo.files) != 0 || len(repo.blobs) != 0 {
+				t.Errorf("rejected upload was recorded")
+			}
+			if entries, _ := os.ReadDir(filepath.Join(dir, tempDir)); len(entries) != 0 {
+				t.Errorf("rejected upload left behind: %d", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"kr-02/internal/pkg/file_storing/repository"
+)
+
+const (
+	// DefaultPageSize is the number of files returned when no page size is requested
+	DefaultPageSize = 20
+
+	// MaxPageSize is the largest number of files returned in one page
+	MaxPageSize = 100
+)
+
+// ErrInvalidPageToken is returned when a page token is malformed or belongs to a listing with another sort order
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// timestampLayout formats timestamps the way PostgreSQL parses TIMESTAMP values, keeping microseconds
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// pageToken is the content of an opaque page token
+type pageToken struct {
+	SortBy     repository.SortField `json:"s"`
+	Descending bool                 `json:"d"`
+	Value      string               `json:"v"`
+	ID         string               `json:"i"`
+}
+
+// encodePageToken creates a token pointing right after the given file
+func encodePageToken(last repository.FileInfo, sortBy repository.SortField, descending bool) (string, error) {
+	token := pageToken{SortBy: sortBy, Descending: descending, ID: last.ID}
+	switch sortBy {
+	case repository.SortByCreatedAt:
+		token.Value = last.CreatedAt.Format(timestampLayout)
+	case repository.SortByName:
+		token.Value = last.Name
+	case repository.SortBySize:
+		token.Value = strconv.FormatInt(last.Size, 10)
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken parses a token and checks that it belongs to a listing with the same sort order
+func decodePageToken(s string, sortBy repository.SortField, descending bool) (*repository.FileCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var token pageToken
+	if err := json.Unmarshal(data, &token); err != nil || token.ID == "" {
+		return nil, ErrInvalidPageToken
+	}
+	if token.SortBy != sortBy || token.Descending != descending {
+		return nil, fmt.Errorf("%w: the sort order has changed", ErrInvalidPageToken)
+	}
+
+	return &repository.FileCursor{Value: token.Value, ID: token.ID}, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kr-02/internal/pkg/file_storing/storage"
+)
+
+// ReconcileReport summarizes what Reconcile found and repaired
+type ReconcileReport struct {
+	// Abandoned uploads were interrupted before their content was stored; their records were removed
+	Abandoned int
+	// Completed uploads were interrupted after their content was stored; they were committed
+	Completed int
+	// Lost uploads had their content neither at the blob nor the temporary location; their records were removed
+	Lost []string
+	// RefCountsRepaired is the number of blobs whose reference count did not match their files
+	RefCountsRepaired int
+	// MissingContent lists blob locations files refer to whose content is missing from storage.
+	// These cannot be repaired automatically. Only filled when the content is checked.
+	MissingContent []string
+}
+
+// String returns a one-line summary of the report
+func (r ReconcileReport) String() string {
+	return fmt.Sprintf("%d abandoned uploads removed, %d interrupted uploads completed, %d lost uploads removed, "+
+		"%d blob reference counts repaired, %d blobs missing from storage",
+		r.Abandoned, r.Completed, len(r.Lost), r.RefCountsRepaired, len(r.MissingContent))
+}
+
+// Reconcile repairs inconsistencies between file records and storage left by interrupted uploads.
+// Pending uploads younger than pendingTimeout may still be in progress and are left alone.
+// With checkContent, every blob is also checked to exist in storage, which reads from every blob.
+func (s *FileService) Reconcile(ctx context.Context, pendingTimeout time.Duration, checkContent bool) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	pending, err := s.repo.ListPendingFiles(ctx, time.Now().Add(-pendingTimeout))
+	if err != nil {
+		return report, fmt.Errorf("failed to reconcile files: %w", err)
+	}
+
+	for _, file := range pending {
+		tempLocation := tempLocation(file.ID)
+
+		// The content was not stored completely, or was rejected
+		if file.Hash == "" {
+			s.discardContent(ctx, tempLocation)
+			if err := s.repo.DeleteFile(ctx, file.ID); err != nil {
+				return report, fmt.Errorf("failed to remove abandoned upload %s: %w", file.ID, err)
+			}
+			report.Abandoned++
+			continue
+		}
+
+		// The content is referenced; finish moving it into place if needed
+		stored, err := s.contentExists(ctx, file.Location)
+		if err != nil {
+			return report, err
+		}
+		if !stored {
+			moved, err := s.contentExists(ctx, tempLocation)
+			if err != nil {
+				return report, err
+			}
+			if !moved {
+				if err := s.repo.DeleteFile(ctx, file.ID); err != nil {
+					return report, fmt.Errorf("failed to remove lost upload %s: %w", file.ID, err)
+				}
+				report.Lost = append(report.Lost, file.ID)
+				continue
+			}
+			if err := s.storage.MoveFile(ctx, tempLocation, file.Location); err != nil {
+				return report, fmt.Errorf("failed to complete upload %s: %w", file.ID, err)
+			}
+		} else {
+			s.discardContent(ctx, tempLocation)
+		}
+
+		if err := s.repo.CommitFile(ctx, file.ID); err != nil {
+			return report, fmt.Errorf("failed to complete upload %s: %w", file.ID, err)
+		}
+		report.Completed++
+	}
+
+	// Counts may be off from uploads interrupted before files and blobs were updated together
+	report.RefCountsRepaired, err = s.repo.RepairBlobRefCounts(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to reconcile blobs: %w", err)
+	}
+
+	if checkContent {
+		locations, err := s.repo.ListBlobLocations(ctx)
+		if err != nil {
+			return report, fmt.Errorf("failed to reconcile blobs: %w", err)
+		}
+		for _, location := range locations {
+			exists, err := s.contentExists(ctx, location)
+			if err != nil {
+				return report, err
+			}
+			if !exists {
+				report.MissingContent = append(report.MissingContent, location)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// contentExists reports whether there is content at a storage location.
+// Errors other than missing content are returned, so nothing is removed because storage is unreachable.
+func (s *FileService) contentExists(ctx context.Context, location string) (bool, error) {
+	content, err := s.storage.GetFile(ctx, location)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check content at %s: %w", location, err)
+	}
+	content.Close()
+	return true, nil
+}
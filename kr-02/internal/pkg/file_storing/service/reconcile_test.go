@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kr-02/internal/pkg/file_storing/repository"
+)
+
+func TestFileService_Reconcile(t *testing.T) {
+	fileService, repo, dir := newTestFileService(t)
+	ctx := context.Background()
+
+	committedID, err := fileService.UploadFile(ctx, "committed.txt", repository.FileTags{}, strings.NewReader("committed"))
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+
+	// Simulate uploads interrupted at every step
+	prepare := func(id, content string, moved bool) repository.FileInfo {
+		file := repository.FileInfo{ID: id, Name: id + ".txt"}
+		repo.CreatePendingFile(ctx, file)
+		if content == "" {
+			return file
+		}
+		if err := fileService.storage.SaveFile(ctx, tempLocation(id), strings.NewReader(content)); err != nil {
+			t.Fatalf("SaveFile() error = %v", err)
+		}
+		file.Hash = strings.Repeat(id[:1], 64)
+		file.Location = blobLocation(file.Hash)
+		repo.PrepareFile(ctx, file)
+		if moved {
+			fileService.storage.MoveFile(ctx, tempLocation(id), file.Location)
+		}
+		return file
+	}
+	repo.CreatePendingFile(ctx, repository.FileInfo{ID: "abandoned"})
+	fileService.storage.SaveFile(ctx, tempLocation("abandoned"), strings.NewReader("partial"))
+	prepare("before-move", "before move", false)
+	prepare("moved", "moved", true)
+	lost := prepare("lost", "lost", false)
+	fileService.storage.DeleteFile(ctx, tempLocation("lost"))
+
+	// A blob whose reference count leaked
+	repo.blobs["leaked"] = 1
+	repo.blobLocations["leaked"] = blobLocation(strings.Repeat("e", 64))
+
+	report, err := fileService.Reconcile(ctx, 0, true)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if report.Abandoned != 1 || report.Completed != 2 || len(report.Lost) != 1 || report.Lost[0] != "lost" {
+		t.Errorf("Reconcile() = %+v; want 1 abandoned, 2 completed, lost upload %q", report, "lost")
+	}
+	// The lost upload and the leaked blob have no references left; the leaked blob has no content either
+	if report.RefCountsRepaired != 1 || repo.blobs[lost.Hash] != 0 {
+		t.Errorf("RefCountsRepaired = %d, lost blob refs = %d; want 1, 0", report.RefCountsRepaired, repo.blobs[lost.Hash])
+	}
+	if len(report.MissingContent) != 2 {
+		t.Errorf("MissingContent = %v, want the lost and the leaked blob", report.MissingContent)
+	}
+
+	// Completed uploads are readable, the others are gone
+	for _, id := range []string{committedID, "before-move", "moved"} {
+		_, content, err := fileService.GetFile(ctx, id)
+		if err != nil {
+			t.Errorf("GetFile(%s) error = %v", id, err)
+			continue
+		}
+		content.Close()
+	}
+	for _, id := range []string{"abandoned", "lost"} {
+		if _, ok := repo.files[id]; ok {
+			t.Errorf("record of %s upload was kept", id)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, tempDir)); len(entries) != 0 {
+		t.Errorf("temporary uploads left behind: %d", len(entries))
+	}
+}
+
+func TestFileService_Reconcile_SkipsRecentUploads(t *testing.T) {
+	fileService, repo, _ := newTestFileService(t)
+	ctx := context.Background()
+
+	repo.CreatePendingFile(ctx, repository.FileInfo{ID: "in-progress"})
+
+	report, err := fileService.Reconcile(ctx, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Abandoned != 0 || !repo.pending["in-progress"] {
+		t.Errorf("Reconcile() removed an upload that may still be in progress: %+v", report)
+	}
+}
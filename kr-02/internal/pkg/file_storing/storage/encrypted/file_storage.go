@@ -0,0 +1,96 @@
+package encrypted
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"kr-02/internal/pkg/encryption"
+	"kr-02/internal/pkg/file_storing/storage"
+)
+
+// EncryptedStorage implements the FileStorage interface by encrypting contents before they reach another storage.
+// Contents stored before encryption was enabled are read as plaintext until they are re-encrypted.
+type EncryptedStorage struct {
+	storage storage.FileStorage
+	keyring *encryption.Keyring
+}
+
+// NewEncryptedStorage creates a new EncryptedStorage instance wrapping the given storage
+func NewEncryptedStorage(storage storage.FileStorage, keyring *encryption.Keyring) *EncryptedStorage {
+	return &EncryptedStorage{
+		storage: storage,
+		keyring: keyring,
+	}
+}
+
+// SaveFile encrypts file content while streaming it to the wrapped storage
+func (s *EncryptedStorage) SaveFile(ctx context.Context, location string, content io.Reader) error {
+	encrypted, err := s.keyring.Encrypt(content)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	return s.storage.SaveFile(ctx, location, encrypted)
+}
+
+// GetFile opens file content in the wrapped storage, decrypting it as it is read
+func (s *EncryptedStorage) GetFile(ctx context.Context, location string) (io.ReadCloser, error) {
+	content, err := s.storage.GetFile(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := s.keyring.Decrypt(content)
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{decrypted, content}, nil
+}
+
+// MoveFile moves file content within the wrapped storage; the content stays encrypted as is
+func (s *EncryptedStorage) MoveFile(ctx context.Context, from, to string) error {
+	return s.storage.MoveFile(ctx, from, to)
+}
+
+// DeleteFile removes file content from the wrapped storage
+func (s *EncryptedStorage) DeleteFile(ctx context.Context, location string) error {
+	return s.storage.DeleteFile(ctx, location)
+}
+
+// ReEncrypt rewrites file content with the primary key unless it is already encrypted with it.
+// The content is written next to the original and then moved over it.
+func (s *EncryptedStorage) ReEncrypt(ctx context.Context, location string) (bool, error) {
+	raw, err := s.storage.GetFile(ctx, location)
+	if err != nil {
+		return false, err
+	}
+	keyID, encrypted, err := encryption.KeyID(raw)
+	raw.Close()
+	if err != nil {
+		return false, err
+	}
+	if encrypted && keyID == s.keyring.PrimaryKeyID() {
+		return false, nil
+	}
+
+	content, err := s.GetFile(ctx, location)
+	if err != nil {
+		return false, err
+	}
+	defer content.Close()
+
+	tempLocation := location + ".reencrypt"
+	if err := s.SaveFile(ctx, tempLocation, content); err != nil {
+		return false, err
+	}
+	if err := s.storage.MoveFile(ctx, tempLocation, location); err != nil {
+		s.storage.DeleteFile(ctx, tempLocation)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,95 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kr-02/internal/pkg/encryption"
+	"kr-02/internal/pkg/file_storing/storage/local"
+)
+
+func newTestKeyring(t *testing.T, primaryID string, ids ...string) *encryption.Keyring {
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), encryption.KeySize)
+	}
+	keyring, err := encryption.NewKeyring(primaryID, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keyring
+}
+
+func readFile(t *testing.T, s *EncryptedStorage, location string) string {
+	content, err := s.GetFile(context.Background(), location)
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatalf("reading file error = %v", err)
+	}
+	return string(data)
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner, err := local.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+
+	// Content written before encryption was enabled
+	if err := inner.SaveFile(ctx, "old.txt", strings.NewReader("old report")); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	s := NewEncryptedStorage(inner, newTestKeyring(t, "a", "a"))
+	if err := s.SaveFile(ctx, "new.txt", strings.NewReader("new report")); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	// Only ciphertext reaches the disk, and both files read back as plaintext
+	raw, _ := os.ReadFile(filepath.Join(dir, "new.txt"))
+	if bytes.Contains(raw, []byte("new report")) {
+		t.Errorf("stored content contains the plaintext")
+	}
+	if got := readFile(t, s, "new.txt"); got != "new report" {
+		t.Errorf("GetFile() = %q, want %q", got, "new report")
+	}
+	if got := readFile(t, s, "old.txt"); got != "old report" {
+		t.Errorf("GetFile() of plaintext content = %q, want %q", got, "old report")
+	}
+
+	// After rotation, re-encryption rewrites everything not encrypted with the new primary key
+	s = NewEncryptedStorage(inner, newTestKeyring(t, "b", "a", "b"))
+	for _, location := range []string{"old.txt", "new.txt"} {
+		changed, err := s.ReEncrypt(ctx, location)
+		if err != nil || !changed {
+			t.Fatalf("ReEncrypt(%s) = %v, %v; want true, nil", location, changed, err)
+		}
+		raw, _ := os.ReadFile(filepath.Join(dir, location))
+		if keyID, encrypted, _ := encryption.KeyID(bytes.NewReader(raw)); !encrypted || keyID != "b" {
+			t.Errorf("ReEncrypt(%s) stored key %q, encrypted %v; want %q", location, keyID, encrypted, "b")
+		}
+		if changed, err := s.ReEncrypt(ctx, location); err != nil || changed {
+			t.Errorf("second ReEncrypt(%s) = %v, %v; want false, nil", location, changed, err)
+		}
+	}
+
+	// The old key is no longer needed
+	s = NewEncryptedStorage(inner, newTestKeyring(t, "b", "b"))
+	if got := readFile(t, s, "old.txt"); got != "old report" {
+		t.Errorf("GetFile() after re-encryption = %q, want %q", got, "old report")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("re-encryption left %d files behind, want 2", len(entries))
+	}
+}
@@ -2,13 +2,25 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"io"
 )
 
+// ErrNotFound is returned by GetFile when there is no content at the location
+var ErrNotFound = errors.New("file not found")
+
 // FileStorage defines the interface for file content operations
 type FileStorage interface {
-	// SaveFile saves file content to storage
-	SaveFile(ctx context.Context, location string, content []byte) error
-	
-	// GetFile retrieves file content from storage
-	GetFile(ctx context.Context, location string) ([]byte, error)
-}
\ No newline at end of file
+	// SaveFile streams file content from the reader to storage
+	SaveFile(ctx context.Context, location string, content io.Reader) error
+
+	// GetFile opens file content in storage for reading; the caller must close it.
+	// It returns ErrNotFound if there is no content at the location.
+	GetFile(ctx context.Context, location string) (io.ReadCloser, error)
+
+	// MoveFile moves file content to another location, replacing content already stored there
+	MoveFile(ctx context.Context, from, to string) error
+
+	// DeleteFile removes file content from storage
+	DeleteFile(ctx context.Context, location string) error
+}
@@ -3,8 +3,11 @@ package local
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"kr-02/internal/pkg/file_storing/storage"
 )
@@ -14,45 +17,136 @@ type LocalStorage struct {
 	basePath string
 }
 
-// NewLocalStorage creates a new LocalStorage instance
+// partialSuffix marks files that are still being written
+const partialSuffix = ".partial"
+
+// NewLocalStorage creates a new LocalStorage instance.
+// Partial files left by writes interrupted by a crash are removed.
 func NewLocalStorage(basePath string) (storage.FileStorage, error) {
 	// Create the base directory if it doesn't exist
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
+
+	if err := removePartialFiles(basePath); err != nil {
+		return nil, fmt.Errorf("failed to remove partial files: %w", err)
+	}
+
 	return &LocalStorage{basePath: basePath}, nil
 }
 
-// SaveFile saves file content to the local filesystem
-func (s *LocalStorage) SaveFile(ctx context.Context, location string, content []byte) error {
+// SaveFile streams file content to the local filesystem.
+// The content is written to a partial file that replaces the target only once it is complete and synced,
+// so the location holds either the previous or the new content even if the process crashes.
+func (s *LocalStorage) SaveFile(ctx context.Context, location string, content io.Reader) error {
 	fullPath := filepath.Join(s.basePath, location)
-	
+
 	// Create the directory if it doesn't exist
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
-	
-	// Write the file
-	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+
+	// Write the partial file
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*"+partialSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	tempPath := file.Name()
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to write file: %w", err)
 	}
-	
-	return nil
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	// Put it in place
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	return syncDir(dir)
 }
 
-// GetFile retrieves file content from the local filesystem
-func (s *LocalStorage) GetFile(ctx context.Context, location string) ([]byte, error) {
+// GetFile opens file content on the local filesystem for reading
+func (s *LocalStorage) GetFile(ctx context.Context, location string) (io.ReadCloser, error) {
 	fullPath := filepath.Join(s.basePath, location)
-	
-	// Read the file
-	content, err := os.ReadFile(fullPath)
+
+	// Open the file
+	file, err := os.Open(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found at location %s", location)
+			return nil, fmt.Errorf("%w at location %s", storage.ErrNotFound, location)
 		}
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	
-	return content, nil
-}
\ No newline at end of file
+
+	return file, nil
+}
+
+// MoveFile moves file content to another location on the local filesystem
+func (s *LocalStorage) MoveFile(ctx context.Context, from, to string) error {
+	toPath := filepath.Join(s.basePath, to)
+
+	// Create the directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(toPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(s.basePath, from), toPath); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	return syncDir(filepath.Dir(toPath))
+}
+
+// DeleteFile removes file content from the local filesystem
+func (s *LocalStorage) DeleteFile(ctx context.Context, location string) error {
+	fullPath := filepath.Join(s.basePath, location)
+
+	// Missing files are treated as already deleted
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+// syncDir flushes a directory, so a file renamed into it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// removePartialFiles removes the partial files under a directory
+func removePartialFiles(basePath string) error {
+	return filepath.WalkDir(basePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), partialSuffix) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
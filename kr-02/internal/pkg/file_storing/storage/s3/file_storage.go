@@ -0,0 +1,389 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kr-02/internal/pkg/file_storing/storage"
+)
+
+// DefaultPartSize is the smallest part size S3 accepts for multipart uploads
+const DefaultPartSize = 5 * 1024 * 1024
+
+// Config contains the settings of an S3-compatible object storage
+type Config struct {
+	// Endpoint is the base URL of the storage, e.g. http://minio:9000
+	Endpoint string
+
+	// Bucket is the bucket the files are stored in; it is created if missing
+	Bucket string
+
+	// Region is used for request signing; MinIO accepts any value
+	Region string
+
+	// AccessKey and SecretKey are the credentials used to sign requests
+	AccessKey string
+	SecretKey string
+
+	// PartSize is the size of the parts large files are uploaded in
+	// Files smaller than a single part are uploaded with one request
+	// Default is DefaultPartSize
+	PartSize int
+
+	// HTTPClient is used to send requests; http.DefaultClient is used if nil
+	HTTPClient *http.Client
+}
+
+// S3Storage implements the FileStorage interface using an S3-compatible object storage
+type S3Storage struct {
+	endpoint *url.URL
+	bucket   string
+	partSize int
+	client   *http.Client
+	signer   *signer
+}
+
+// NewS3Storage creates a new S3Storage instance
+func NewS3Storage(ctx context.Context, cfg Config) (storage.FileStorage, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("storage bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	s := &S3Storage{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		partSize: cfg.PartSize,
+		client:   cfg.HTTPClient,
+		signer: &signer{
+			accessKey: cfg.AccessKey,
+			secretKey: cfg.SecretKey,
+			region:    cfg.Region,
+		},
+	}
+
+	// Create the bucket if it doesn't exist
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create storage bucket: %w", err)
+	}
+
+	return s, nil
+}
+
+// SaveFile streams file content to the object storage.
+// Content that fits into one part is sent with a single PUT, larger content with a multipart upload.
+func (s *S3Storage) SaveFile(ctx context.Context, location string, content io.Reader) error {
+	// Read the first part to find out whether a multipart upload is needed
+	part := make([]byte, s.partSize)
+	n, err := io.ReadFull(content, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(ctx, location, part[:n])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file content: %w", err)
+	}
+
+	return s.multipartUpload(ctx, location, part, content)
+}
+
+// GetFile opens file content in the object storage for reading
+func (s *S3Storage) GetFile(ctx context.Context, location string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, location, nil, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w at location %s", storage.ErrNotFound, location)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get file: %w", readError(resp))
+	}
+
+	return resp.Body, nil
+}
+
+// MoveFile moves file content to another location with a server-side copy followed by a delete
+func (s *S3Storage) MoveFile(ctx context.Context, from, to string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", "/"+s.bucket+"/"+from)
+
+	resp, err := s.doWithHeader(ctx, http.MethodPut, to, nil, header, nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure with status 200 and an error document in the body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || bytes.Contains(respBody, []byte("<Error>")) {
+		return fmt.Errorf("failed to copy file: %w", parseError(resp.Status, respBody))
+	}
+
+	return s.DeleteFile(ctx, from)
+}
+
+// DeleteFile removes file content from the object storage
+func (s *S3Storage) DeleteFile(ctx context.Context, location string) error {
+	resp, err := s.do(ctx, http.MethodDelete, location, nil, nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	// Missing objects are treated as already deleted
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete file: %w", readError(resp))
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// ensureBucket creates the bucket unless it already exists
+func (s *S3Storage) ensureBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status checking bucket: %s", resp.Status)
+	}
+
+	resp, err = s.do(ctx, http.MethodPut, "", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// putObject uploads content with a single request
+func (s *S3Storage) putObject(ctx context.Context, location string, content []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, location, nil, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to write file: %w", readError(resp))
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// multipartUpload uploads the already read first part and the rest of the content part by part,
+// so at most one part is held in memory at a time
+func (s *S3Storage) multipartUpload(ctx context.Context, location string, firstPart []byte, rest io.Reader) error {
+	uploadID, err := s.createMultipartUpload(ctx, location)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	var parts []completedPart
+	part := firstPart
+	for partNumber := 1; ; partNumber++ {
+		etag, err := s.uploadPart(ctx, location, uploadID, partNumber, part)
+		if err != nil {
+			s.abortMultipartUpload(ctx, location, uploadID)
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+
+		// Read the next part; the buffer can be reused as the previous part has been sent
+		n, err := io.ReadFull(rest, part[:cap(part)])
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			s.abortMultipartUpload(ctx, location, uploadID)
+			return fmt.Errorf("failed to read file content: %w", err)
+		}
+		part = part[:n]
+	}
+
+	if err := s.completeMultipartUpload(ctx, location, uploadID, parts); err != nil {
+		s.abortMultipartUpload(ctx, location, uploadID)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// initiateMultipartUploadResult is the response of CreateMultipartUpload
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completedPart references an uploaded part when completing a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeMultipartUpload is the request body of CompleteMultipartUpload
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// createMultipartUpload starts a multipart upload and returns its ID
+func (s *S3Storage) createMultipartUpload(ctx context.Context, location string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, location, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	defer resp.Body.Close()
+
+	var result initiateMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("storage returned an empty upload ID")
+	}
+
+	return result.UploadID, nil
+}
+
+// uploadPart uploads a single part and returns its ETag
+func (s *S3Storage) uploadPart(ctx context.Context, location, uploadID string, partNumber int, content []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := s.do(ctx, http.MethodPut, location, query, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	resp.Body.Close()
+
+	return resp.Header.Get("ETag"), nil
+}
+
+// completeMultipartUpload assembles the uploaded parts into the object
+func (s *S3Storage) completeMultipartUpload(ctx context.Context, location, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, location, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure with status 200 and an error document in the body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || bytes.Contains(respBody, []byte("<Error>")) {
+		return parseError(resp.Status, respBody)
+	}
+
+	return nil
+}
+
+// abortMultipartUpload discards the parts of a failed multipart upload
+func (s *S3Storage) abortMultipartUpload(ctx context.Context, location, uploadID string) {
+	resp, err := s.do(ctx, http.MethodDelete, location, url.Values{"uploadId": {uploadID}}, nil, 0)
+	if err != nil {
+		fmt.Printf("Failed to abort multipart upload %s: %v\n", uploadID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// do sends a signed request for the object at location, or for the bucket if location is empty
+func (s *S3Storage) do(ctx context.Context, method, location string, query url.Values, body io.Reader, contentLength int64) (*http.Response, error) {
+	return s.doWithHeader(ctx, method, location, query, nil, body, contentLength)
+}
+
+// doWithHeader sends a signed request with additional headers
+func (s *S3Storage) doWithHeader(ctx context.Context, method, location string, query url.Values, header http.Header, body io.Reader, contentLength int64) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	if location != "" {
+		u.Path += "/" + location
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = contentLength
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.signer.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// errorResponse is the error document returned by S3
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// readError consumes and closes the response body and converts it to an error
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return parseError(resp.Status, body)
+}
+
+// parseError converts an S3 error document to an error
+func parseError(status string, body []byte) error {
+	var errResp errorResponse
+	if err := xml.Unmarshal(body, &errResp); err == nil && errResp.Code != "" {
+		return fmt.Errorf("storage returned %s: %s: %s", status, errResp.Code, errResp.Message)
+	}
+	return fmt.Errorf("storage returned %s", status)
+}
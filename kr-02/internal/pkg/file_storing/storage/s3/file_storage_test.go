@@ -0,0 +1,223 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server
+type fakeS3 struct {
+	mu       sync.Mutex
+	buckets  map[string]bool
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	nextID   int
+	requests []string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: make(map[string]bool),
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	query := r.URL.Query()
+	f.requests = append(f.requests, r.Method+" "+path)
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		}
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var req completeMultipartUpload
+		xml.Unmarshal(body, &req)
+		parts := f.uploads[query.Get("uploadId")]
+		var numbers []int
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var content []byte
+		for _, n := range numbers {
+			content = append(content, parts[n]...)
+		}
+		f.objects[path] = content
+		delete(f.uploads, query.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		if !strings.Contains(r.Header.Get("Authorization"), "x-amz-copy-source") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		source, ok := f.objects[strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.objects[path] = source
+		fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+	case r.Method == http.MethodPut:
+		f.objects[path] = body
+	case r.Method == http.MethodGet:
+		content, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Write(content)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestStorage(t *testing.T, fake *fakeS3, partSize int) *S3Storage {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(context.Background(), Config{
+		Endpoint:  server.URL,
+		Bucket:    "files",
+		AccessKey: "key",
+		SecretKey: "secret",
+		PartSize:  partSize,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage() error = %v", err)
+	}
+	return s.(*S3Storage)
+}
+
+func TestS3Storage_SaveAndGetFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   []byte
+		multipart bool
+	}{
+		{name: "Empty file", content: []byte{}},
+		{name: "Single part", content: []byte("short report")},
+		{name: "Multiple parts", content: bytes.Repeat([]byte("0123456789"), 25), multipart: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeS3()
+			s := newTestStorage(t, fake, 64)
+			ctx := context.Background()
+
+			if err := s.SaveFile(ctx, "report", bytes.NewReader(tt.content)); err != nil {
+				t.Fatalf("SaveFile() error = %v", err)
+			}
+
+			if got := fake.uploads; len(got) != 0 {
+				t.Errorf("unfinished multipart uploads left: %v", got)
+			}
+			if got := fake.nextID > 0; got != tt.multipart {
+				t.Errorf("multipart upload used = %v, want %v", got, tt.multipart)
+			}
+
+			reader, err := s.GetFile(ctx, "report")
+			if err != nil {
+				t.Fatalf("GetFile() error = %v", err)
+			}
+			defer reader.Close()
+
+			got, _ := io.ReadAll(reader)
+			if !bytes.Equal(got, tt.content) {
+				t.Errorf("GetFile() content length = %d, want %d", len(got), len(tt.content))
+			}
+		})
+	}
+}
+
+func TestS3Storage_CreatesBucket(t *testing.T) {
+	fake := newFakeS3()
+	newTestStorage(t, fake, 0)
+
+	if !fake.buckets["files"] {
+		t.Errorf("bucket was not created, requests: %v", fake.requests)
+	}
+}
+
+func TestS3Storage_DeleteFile(t *testing.T) {
+	fake := newFakeS3()
+	s := newTestStorage(t, fake, 0)
+	ctx := context.Background()
+
+	if err := s.SaveFile(ctx, "report", strings.NewReader("content")); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	if err := s.DeleteFile(ctx, "report"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if _, err := s.GetFile(ctx, "report"); err == nil {
+		t.Errorf("GetFile() after delete returned no error")
+	}
+
+	// Deleting a missing file is not an error
+	if err := s.DeleteFile(ctx, "missing"); err != nil {
+		t.Errorf("DeleteFile() of missing file error = %v", err)
+	}
+}
+
+func TestS3Storage_MoveFile(t *testing.T) {
+	fake := newFakeS3()
+	s := newTestStorage(t, fake, 0)
+	ctx := context.Background()
+
+	if err := s.SaveFile(ctx, "tmp/upload", strings.NewReader("content")); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	if err := s.MoveFile(ctx, "tmp/upload", "ab/cd/abcd"); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+
+	if _, ok := fake.objects["files/tmp/upload"]; ok {
+		t.Errorf("source object still exists after move")
+	}
+	if got := string(fake.objects["files/ab/cd/abcd"]); got != "content" {
+		t.Errorf("moved object content = %q, want %q", got, "content")
+	}
+}
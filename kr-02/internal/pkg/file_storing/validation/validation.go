@@ -0,0 +1,200 @@
+package validation
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Reason is the machine-readable cause of a rejected upload
+type Reason string
+
+const (
+	ReasonEmptyFile           Reason = "EMPTY_FILE"
+	ReasonFileTooLarge        Reason = "FILE_TOO_LARGE"
+	ReasonUnsupportedFileType Reason = "UNSUPPORTED_FILE_TYPE"
+	ReasonInvalidEncoding     Reason = "INVALID_ENCODING"
+)
+
+// Error is returned for uploads rejected by a validator
+type Error struct {
+	Reason  Reason
+	Message string
+}
+
+// Error returns the message of the rejection
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Upload describes an uploaded file once its content has been read
+type Upload struct {
+	FileName string
+	Size     int64
+	MIMEType string
+	Encoding string
+}
+
+// Validator checks an upload and returns an *Error if it must be rejected
+type Validator interface {
+	Validate(upload Upload) error
+}
+
+// SizeLimiter is implemented by validators that limit the upload size.
+// The limit is enforced while the content is read, so oversized uploads are aborted early.
+type SizeLimiter interface {
+	SizeLimit() int64
+}
+
+// Chain runs validators in order and returns the first rejection
+type Chain []Validator
+
+// Validate runs all validators of the chain
+func (c Chain) Validate(upload Upload) error {
+	for _, validator := range c {
+		if err := validator.Validate(upload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SizeLimit returns the smallest size limit of the chain, or 0 if there is none
+func (c Chain) SizeLimit() int64 {
+	var limit int64
+	for _, validator := range c {
+		if limiter, ok := validator.(SizeLimiter); ok {
+			if l := limiter.SizeLimit(); l > 0 && (limit == 0 || l < limit) {
+				limit = l
+			}
+		}
+	}
+	return limit
+}
+
+// MaxSize rejects uploads larger than the given number of bytes
+type MaxSize int64
+
+// Validate checks the size of the upload
+func (m MaxSize) Validate(upload Upload) error {
+	if upload.Size > int64(m) {
+		return tooLarge(int64(m))
+	}
+	return nil
+}
+
+// SizeLimit returns the maximum size
+func (m MaxSize) SizeLimit() int64 {
+	return int64(m)
+}
+
+// NotEmpty rejects uploads without content
+type NotEmpty struct{}
+
+// Validate checks that the upload has content
+func (NotEmpty) Validate(upload Upload) error {
+	if upload.Size == 0 {
+		return &Error{Reason: ReasonEmptyFile, Message: "file is empty"}
+	}
+	return nil
+}
+
+// AllowedExtensions rejects uploads whose file name has another extension, compared case-insensitively
+type AllowedExtensions []string
+
+// Validate checks the extension of the file name
+func (a AllowedExtensions) Validate(upload Upload) error {
+	ext := path.Ext(upload.FileName)
+	for _, allowed := range a {
+		if strings.EqualFold(ext, allowed) {
+			return nil
+		}
+	}
+	return &Error{
+		Reason:  ReasonUnsupportedFileType,
+		Message: fmt.Sprintf("file extension %q is not allowed, expected one of: %s", ext, strings.Join(a, ", ")),
+	}
+}
+
+// AllowedMIMETypes rejects uploads whose detected media type is another one; parameters such as charset are ignored
+type AllowedMIMETypes []string
+
+// Validate checks the detected media type of the content
+func (a AllowedMIMETypes) Validate(upload Upload) error {
+	mediaType, _, _ := strings.Cut(upload.MIMEType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, allowed := range a {
+		if strings.EqualFold(mediaType, allowed) {
+			return nil
+		}
+	}
+	return &Error{
+		Reason:  ReasonUnsupportedFileType,
+		Message: fmt.Sprintf("content type %q is not allowed, expected one of: %s", mediaType, strings.Join(a, ", ")),
+	}
+}
+
+// AllowedEncodings rejects text in other encodings, e.g. content that is not valid UTF-8
+type AllowedEncodings []string
+
+// Validate checks the detected encoding of the content
+func (a AllowedEncodings) Validate(upload Upload) error {
+	for _, allowed := range a {
+		if strings.EqualFold(upload.Encoding, allowed) {
+			return nil
+		}
+	}
+	encoding := upload.Encoding
+	if encoding == "" {
+		encoding = "binary"
+	}
+	return &Error{
+		Reason:  ReasonInvalidEncoding,
+		Message: fmt.Sprintf("text encoding %q is not allowed, expected one of: %s", encoding, strings.Join(a, ", ")),
+	}
+}
+
+// LimitedReader reads from R and fails once more than N bytes have been read
+type LimitedReader struct {
+	R        io.Reader
+	N        int64
+	read     int64
+	exceeded bool
+}
+
+// Read reads from the underlying reader, failing with an *Error once the limit is exceeded
+func (r *LimitedReader) Read(p []byte) (int, error) {
+	if r.exceeded {
+		return 0, tooLarge(r.N)
+	}
+
+	// Read at most one byte past the limit to find out whether it is exceeded
+	if remaining := r.N - r.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.R.Read(p)
+	r.read += int64(n)
+	if r.read > r.N {
+		r.exceeded = true
+		return n - int(r.read-r.N), tooLarge(r.N)
+	}
+	return n, err
+}
+
+// Err returns the *Error of an exceeded limit, or nil.
+// Storage backends may wrap or replace read errors, so callers should check it after the content is consumed.
+func (r *LimitedReader) Err() error {
+	if r.exceeded {
+		return tooLarge(r.N)
+	}
+	return nil
+}
+
+// tooLarge returns the rejection of an upload exceeding the limit
+func tooLarge(limit int64) error {
+	return &Error{
+		Reason:  ReasonFileTooLarge,
+		Message: fmt.Sprintf("file exceeds the maximum size of %d bytes", limit),
+	}
+}
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChain_Validate(t *testing.T) {
+	chain := Chain{
+		NotEmpty{},
+		MaxSize(1024),
+		AllowedExtensions{".txt"},
+		AllowedMIMETypes{"text/plain"},
+		AllowedEncodings{"utf-8"},
+	}
+	valid := Upload{FileName: "report.txt", Size: 100, MIMEType: "text/plain; charset=utf-8", Encoding: "utf-8"}
+
+	tests := []struct {
+		name       string
+		modify     func(u *Upload)
+		wantReason Reason
+	}{
+		{"valid", func(u *Upload) {}, ""},
+		{"upper case extension", func(u *Upload) { u.FileName = "REPORT.TXT" }, ""},
+		{"empty", func(u *Upload) { u.Size = 0 }, ReasonEmptyFile},
+		{"too large", func(u *Upload) { u.Size = 1025 }, ReasonFileTooLarge},
+		{"extension", func(u *Upload) { u.FileName = "report.docx" }, ReasonUnsupportedFileType},
+		{"no extension", func(u *Upload) { u.FileName = "report" }, ReasonUnsupportedFileType},
+		{"mime type", func(u *Upload) { u.MIMEType = "application/pdf" }, ReasonUnsupportedFileType},
+		{"encoding", func(u *Upload) { u.Encoding = "windows-1251" }, ReasonInvalidEncoding},
+		{"binary", func(u *Upload) { u.Encoding = "" }, ReasonInvalidEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload := valid
+			tt.modify(&upload)
+
+			err := chain.Validate(upload)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			var validationErr *Error
+			if !errors.As(err, &validationErr) || validationErr.Reason != tt.wantReason {
+				t.Errorf("Validate() error = %v, want reason %s", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestChain_SizeLimit(t *testing.T) {
+	if limit := (Chain{NotEmpty{}}).SizeLimit(); limit != 0 {
+		t.Errorf("SizeLimit() without limits = %d, want 0", limit)
+	}
+	if limit := (Chain{MaxSize(100), NotEmpty{}, MaxSize(10)}).SizeLimit(); limit != 10 {
+		t.Errorf("SizeLimit() = %d, want 10", limit)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	// Content up to the limit is read in full
+	r := &LimitedReader{R: strings.NewReader("12345"), N: 5}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "12345" || r.Err() != nil {
+		t.Errorf("ReadAll() = %q, %v; Err() = %v; want %q, nil, nil", data, err, r.Err(), "12345")
+	}
+
+	// Reading past the limit fails without returning the extra bytes
+	r = &LimitedReader{R: strings.NewReader("123456"), N: 5}
+	data, err = io.ReadAll(r)
+	var validationErr *Error
+	if !errors.As(err, &validationErr) || validationErr.Reason != ReasonFileTooLarge {
+		t.Errorf("ReadAll() error = %v, want reason %s", err, ReasonFileTooLarge)
+	}
+	if string(data) != "12345" {
+		t.Errorf("ReadAll() = %q, want %q", data, "12345")
+	}
+	if r.Err() == nil {
+		t.Errorf("Err() = nil after exceeding the limit")
+	}
+}
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Usage describes the arguments of the migrate subcommand
+const Usage = `usage: migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    roll back the last N applied migrations (default 1)
+  status      list migrations and whether they have been applied`
+
+// RunCommand executes the migrate subcommand with the given arguments, writing progress to out
+func RunCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %s", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Rolled back %d migrations\n", rolledBack)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], Usage)
+	}
+
+	return nil
+}
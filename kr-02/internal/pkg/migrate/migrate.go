@@ -0,0 +1,246 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// fileNamePattern matches migration files such as 0001_create_files.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads migrations from the root of fsys.
+// Every version must have both an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file in migrations: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has different names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, keeping track of them in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	component  string
+}
+
+// NewMigrator creates a new Migrator for the migrations in fsys.
+// component names the owner of the migrations, so several services can keep their schemas in one database.
+func NewMigrator(db *sql.DB, fsys fs.FS, component string) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, component: component}, nil
+}
+
+// Up applies all pending migrations in order and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int]bool) error {
+		for _, migration := range m.migrations {
+			if done[migration.Version] {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the given number of the most recently applied migrations and returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int]bool) error {
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if !done[migration.Version] {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status returns all known migrations and whether they have been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn, done map[int]bool) error {
+		for _, migration := range m.migrations {
+			statuses = append(statuses, MigrationStatus{
+				Migration: migration,
+				Applied:   done[migration.Version],
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection holding the advisory lock of the component,
+// passing the set of versions that have been applied.
+// The lock keeps concurrently starting instances from migrating at the same time.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, done map[int]bool) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, m.component); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, m.component)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			component TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (component, version)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	done, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, done)
+}
+
+// apply runs the up or down script of a migration and records the result in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("failed to migrate %s %d_%s: %w", direction, migration.Version, migration.Name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (component, version, name) VALUES ($1, $2, $3)`,
+			m.component, migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE component = $1 AND version = $2`,
+			m.component, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// appliedVersions returns the set of migration versions of the component recorded in schema_migrations
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations WHERE component = $1`, m.component)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		done[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over applied migrations: %w", err)
+	}
+
+	return done, nil
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_size.up.sql":       {Data: []byte("ALTER TABLE files ADD COLUMN size BIGINT;")},
+		"0002_add_size.down.sql":     {Data: []byte("ALTER TABLE files DROP COLUMN size;")},
+		"0001_create_files.up.sql":   {Data: []byte("CREATE TABLE files (id TEXT);")},
+		"0001_create_files.down.sql": {Data: []byte("DROP TABLE files;")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("Load() returned %d migrations, want 2", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_files" {
+		t.Errorf("first migration = %d_%s, want 1_create_files", migrations[0].Version, migrations[0].Name)
+	}
+	if migrations[1].Version != 2 || migrations[1].Down != "ALTER TABLE files DROP COLUMN size;" {
+		t.Errorf("second migration = %+v", migrations[1])
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Missing down script",
+			fsys: fstest.MapFS{
+				"0001_create_files.up.sql": {Data: []byte("CREATE TABLE files (id TEXT);")},
+			},
+		},
+		{
+			name: "Unexpected file",
+			fsys: fstest.MapFS{
+				"README.md": {Data: []byte("migrations")},
+			},
+		},
+		{
+			name: "Different names for one version",
+			fsys: fstest.MapFS{
+				"0001_create_files.up.sql":   {Data: []byte("CREATE TABLE files (id TEXT);")},
+				"0001_create_blobs.down.sql": {Data: []byte("DROP TABLE blobs;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Errorf("Load() returned no error")
+			}
+		})
+	}
+}